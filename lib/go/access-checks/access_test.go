@@ -3,15 +3,17 @@ package access
 import (
 	"testing"
 
-	"github.com/bjartek/go-with-the-flow/gwtf"
 	util "github.com/flow-hydraulics/onchain-multisig"
+	"github.com/flow-hydraulics/onchain-multisig/vault"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestOwnerCannotUpdateStore(t *testing.T) {
-	g := gwtf.NewGoWithTheFlow("../../../flow.json")
-	ownerAcct := "vaulted-account"
-	vaultAcct := "vaulted-account"
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := vault.NewTestFixture(t, g)
+	ownerAcct := f.Vault
+	vaultAcct := f.Vault
 
 	_, err := MultiSig_PubUpdateStore(g, 11, ownerAcct, vaultAcct)
 	// error: cannot assign to `multiSigManager`: field has public access
@@ -29,9 +31,11 @@ func TestOwnerCannotUpdateStore(t *testing.T) {
 }
 
 func TestPubCannotUpdateTxIndex(t *testing.T) {
-	g := gwtf.NewGoWithTheFlow("../../../flow.json")
-	pubAcct := "w-1000"
-	vaultAcct := "vaulted-account"
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := vault.NewTestFixture(t, g)
+	pubAcct := f.Signers[0]
+	vaultAcct := f.Vault
 
 	_, err := MultiSig_PubUpdateTxIndex(g, 11, pubAcct, vaultAcct)
 	// error: cannot assign to `txIndex`: field has public access
@@ -43,9 +47,11 @@ func TestPubCannotUpdateTxIndex(t *testing.T) {
 }
 
 func TestOwnerCannotUpdateTxIndex(t *testing.T) {
-	g := gwtf.NewGoWithTheFlow("../../../flow.json")
-	ownerAcct := "vaulted-account"
-	vaultAcct := "vaulted-account"
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := vault.NewTestFixture(t, g)
+	ownerAcct := f.Vault
+	vaultAcct := f.Vault
 
 	_, err := MultiSig_OwnerUpdateTxIndex(g, 11, ownerAcct, vaultAcct)
 	// error: cannot assign to `txIndex`: field has public access
@@ -57,11 +63,13 @@ func TestOwnerCannotUpdateTxIndex(t *testing.T) {
 }
 
 func TestPubCannotUpdateKeyList(t *testing.T) {
-	g := gwtf.NewGoWithTheFlow("../../../flow.json")
-	pubAcct := "w-1000"
-	vaultAcct := "vaulted-account"
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := vault.NewTestFixture(t, g)
+	pubAcct := f.Signers[0]
+	vaultAcct := f.Vault
 
-	initKeys, err := util.GetStoreKeys(g, "vaulted-account")
+	initKeys, err := util.GetStoreKeys(g, f.Vault)
 	assert.NoError(t, err)
 
 	_, err = MultiSig_PubUpdateKeyList(g, pubAcct, vaultAcct)
@@ -71,18 +79,20 @@ func TestPubCannotUpdateKeyList(t *testing.T) {
 	//18 |         vaultRef.multiSigManager.keyList.insert(key: "1aa4", pka)
 	//  |         ^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^
 	assert.Error(t, err)
-	postKeys, err := util.GetStoreKeys(g, "vaulted-account")
+	postKeys, err := util.GetStoreKeys(g, f.Vault)
 	assert.NoError(t, err)
 	assert.Equal(t, initKeys, postKeys)
 }
 
 // Owner must use the `addKeys` function in the Vault
 func TestOwnerCannotUpdateKeyListDirectly(t *testing.T) {
-	g := gwtf.NewGoWithTheFlow("../../../flow.json")
-	ownerAcct := "vaulted-account"
-	vaultAcct := "vaulted-account"
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := vault.NewTestFixture(t, g)
+	ownerAcct := f.Vault
+	vaultAcct := f.Vault
 
-	initKeys, err := util.GetStoreKeys(g, "vaulted-account")
+	initKeys, err := util.GetStoreKeys(g, f.Vault)
 	assert.NoError(t, err)
 
 	_, err = MultiSig_OwnerUpdateKeyList(g, ownerAcct, vaultAcct)
@@ -94,7 +104,7 @@ func TestOwnerCannotUpdateKeyListDirectly(t *testing.T) {
 	//  |         ^^^^^^^^^^^^^^^^^^^^^^^^^
 	assert.Error(t, err)
 
-	postKeys, err := util.GetStoreKeys(g, "vaulted-account")
+	postKeys, err := util.GetStoreKeys(g, f.Vault)
 	assert.NoError(t, err)
 	assert.Equal(t, initKeys, postKeys)
 }
@@ -0,0 +1,63 @@
+// Package bindings has a Go function for each transaction and script of the repository, with parameters and
+// results of the Go types of their Cadence types, so that a change of a signature breaks the build
+// instead of the transactions. The functions are generated by `go generate ./bindings`,
+// and `TestBindingsAreUpToDate` fails if a transaction or script changes without generating them again.
+//
+// Transactions are sent with `util.SendTransaction` and return the events of the transaction.
+// Parameters of types with no Go type, such as structs and `AnyStruct`, are `cadence.Value`s,
+// and so are the results of scripts returning them.
+package bindings
+
+//go:generate go run ../scripts/generate-bindings -root ../../.. -out bindings_gen.go
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/bjartek/go-with-the-flow/gwtf"
+	util "github.com/flow-hydraulics/onchain-multisig"
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+)
+
+// Client sends the transactions and runs the scripts of the repository at Root with G
+type Client struct {
+	G *gwtf.GoWithTheFlow
+	// Root is the path to the root of the repository, where the transactions and scripts are
+	Root string
+}
+
+// New returns a client of the transactions and scripts of the repository at `root`, which is "../../.."
+// in the test packages and "../.." for the commands in lib/go/scripts
+func New(g *gwtf.GoWithTheFlow, root string) *Client {
+	return &Client{G: g, Root: root}
+}
+
+func (c *Client) send(roles util.TxRoles, file string, args ...cadence.Value) ([]flow.Event, error) {
+	filename := filepath.Join(c.Root, file)
+	return util.SendTransaction(c.G, roles, filename, util.ParseCadenceTemplate(filename), args...)
+}
+
+func (c *Client) run(file string, args ...cadence.Value) (cadence.Value, error) {
+	filename := filepath.Join(c.Root, file)
+	script := c.G.ScriptFromFile(filename, util.ParseCadenceTemplate(filename))
+	for _, arg := range args {
+		script = script.Argument(arg)
+	}
+	return script.RunReturns()
+}
+
+func encodeArray(n int, element func(i int) cadence.Value) cadence.Value {
+	values := make([]cadence.Value, n)
+	for i := range values {
+		values[i] = element(i)
+	}
+	return cadence.NewArray(values)
+}
+
+// recoverDecode returns the panic of decoding the result of a script that is not of its declared type as `err`
+func recoverDecode(file string, err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("cannot decode the result of %s: %v", file, r)
+	}
+}
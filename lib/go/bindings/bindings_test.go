@@ -0,0 +1,92 @@
+package bindings
+
+import (
+	"io/ioutil"
+	"testing"
+
+	util "github.com/flow-hydraulics/onchain-multisig"
+	"github.com/flow-hydraulics/onchain-multisig/bindings/generator"
+	"github.com/flow-hydraulics/onchain-multisig/encoder"
+	"github.com/flow-hydraulics/onchain-multisig/signer"
+	"github.com/onflow/cadence"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBindingsAreUpToDate(t *testing.T) {
+	generated, err := generator.Generate("../../..")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	code, err := ioutil.ReadFile("bindings_gen.go")
+	assert.NoError(t, err)
+	assert.Equal(t, string(generated), string(code),
+		"the bindings of the transactions and scripts are stale, run `go generate ./bindings` in lib/go")
+}
+
+func TestBindings(t *testing.T) {
+	g := emu.GoWithTheFlow()
+	c := New(g, "../../..")
+	acct := "bindings-vault"
+	assert.NoError(t, util.NewAccount(g, acct))
+	address := g.Accounts[acct].Address
+	path := util.DefaultVaultPaths.Signer
+	pk := signer.PublicKeyHex(util.GetSigner(g, acct))
+
+	_, err := c.CreateVault(util.AccountRoles(acct), []string{pk}, []cadence.UFix64{1000_0000_0000}, []uint8{1})
+	assert.NoError(t, err)
+
+	weights, err := c.GetSignerWeights(address, path)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]cadence.UFix64{pk: 1000_0000_0000}, weights)
+	keys, err := c.GetStoreKeys(address, path)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{pk}, keys)
+	sigAlgo, err := c.GetKeySigAlgo(address, path, pk)
+	assert.NoError(t, err)
+	if assert.NotNil(t, sigAlgo) {
+		assert.Equal(t, uint8(1), *sigAlgo)
+	}
+	sigAlgo, err = c.GetKeySigAlgo(address, path, "1234")
+	assert.NoError(t, err)
+	assert.Nil(t, sigAlgo)
+	limit, err := c.GetSpendingLimit(address, path)
+	assert.NoError(t, err)
+	assert.Equal(t, cadence.NewOptional(nil), limit)
+	recipients, err := c.GetAllowedRecipients(address, path)
+	assert.NoError(t, err)
+	assert.Empty(t, recipients)
+
+	domain, err := util.GetSignableDomain(g, acct)
+	assert.NoError(t, err)
+	calculated, err := c.CalcSignableDomain(address, path)
+	assert.NoError(t, err)
+	assert.Equal(t, domain, calculated)
+	data, err := c.CalcSignableData(cadence.String("abcd"))
+	assert.NoError(t, err)
+	encoded, err := encoder.EncodeValue(cadence.String("abcd"))
+	assert.NoError(t, err)
+	assert.Equal(t, encoded, data)
+
+	height, err := c.GetBlockHeight()
+	assert.NoError(t, err)
+	_, err = c.AdvanceBlock(util.AccountRoles(acct))
+	assert.NoError(t, err)
+	next, err := c.GetBlockHeight()
+	assert.NoError(t, err)
+	assert.Less(t, height, next)
+}
+
+func TestResultOfAnotherTypeIsAnError(t *testing.T) {
+	decode := func(value cadence.Value) (result cadence.UFix64, err error) {
+		defer recoverDecode("scripts/get_balance.cdc", &err)
+		result = value.(cadence.UFix64)
+		return
+	}
+	_, err := decode(cadence.String("1.0"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot decode the result of scripts/get_balance.cdc")
+	}
+	result, err := decode(cadence.UFix64(1_0000_0000))
+	assert.NoError(t, err)
+	assert.Equal(t, cadence.UFix64(1_0000_0000), result)
+}
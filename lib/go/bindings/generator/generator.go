@@ -0,0 +1,373 @@
+// Package generator generates the typed Go bindings of the `bindings` package from the parameters and return types
+// of the Cadence transactions and scripts, which it parses with the Cadence parser.
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/onflow/cadence/runtime/ast"
+	"github.com/onflow/cadence/runtime/parser2"
+)
+
+// Header is the first line of the generated code
+const Header = "// Code generated by scripts/generate-bindings from the transactions and scripts. DO NOT EDIT."
+
+// Dirs are the directories of the transactions and scripts relative to the root of the repository
+var Dirs = []string{"transactions", "scripts"}
+
+// placeholder matches the placeholders of addresses in the templates, such as `{{.OnChainMultiSig}}`
+var placeholder = regexp.MustCompile(`{{\s*\.\w+\s*}}`)
+
+// Param is a parameter of a transaction or script
+type Param struct {
+	Name string
+	Type ast.Type
+	// Doc is the description of the parameter in the comments of the file, if any
+	Doc string
+}
+
+// Binding is the signature of a transaction or of the main function of a script
+type Binding struct {
+	// Name is the name of the Go function of the file
+	Name string
+	// File is the path of the file relative to the root of the repository
+	File string
+	// Doc are the lines of the comments at the top of the file
+	Doc    []string
+	Params []Param
+	// Signers are the names of the accounts authorizing a transaction, nil for scripts
+	Signers []string
+	// Returns is the return type of a script, nil for transactions
+	Returns ast.Type
+}
+
+// IsScript returns true if the binding runs a script
+func (b Binding) IsScript() bool {
+	return strings.HasPrefix(b.File, "scripts/")
+}
+
+// Parse parses the signatures of the transactions and scripts in the `Dirs` of `root`, sorted by file
+func Parse(root string) (bindings []Binding, err error) {
+	names := map[string]string{}
+	for _, dir := range Dirs {
+		files, err := filepath.Glob(filepath.Join(root, dir, "*.cdc"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+		for _, path := range files {
+			b, err := ParseFile(root, filepath.ToSlash(filepath.Join(dir, filepath.Base(path))))
+			if err != nil {
+				return nil, err
+			}
+			if other, ok := names[b.Name]; ok {
+				return nil, fmt.Errorf("%s and %s are both bound to %s", other, b.File, b.Name)
+			}
+			names[b.Name] = b.File
+			bindings = append(bindings, b)
+		}
+	}
+	return
+}
+
+// ParseFile parses the signature of the transaction or script at `file` relative to `root`
+func ParseFile(root string, file string) (b Binding, err error) {
+	path := filepath.Join(root, file)
+	code, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	// The addresses of the imports do not change the signatures
+	program, err := parser2.ParseProgram(placeholder.ReplaceAllString(string(code), "0000000000000000"))
+	if err != nil {
+		return b, fmt.Errorf("%s: %w", file, err)
+	}
+
+	b = Binding{Name: goName(strings.TrimSuffix(filepath.Base(file), ".cdc")), File: file, Doc: leadingComments(code)}
+	lines := strings.Split(string(code), "\n")
+	var params *ast.ParameterList
+	if b.IsScript() {
+		var main *ast.FunctionDeclaration
+		for _, f := range program.FunctionDeclarations() {
+			if f.Identifier.Identifier == "main" {
+				main = f
+			}
+		}
+		if main == nil {
+			return b, fmt.Errorf("%s: no main function", file)
+		}
+		params = main.ParameterList
+		b.Returns = main.ReturnTypeAnnotation.Type
+	} else {
+		tx := program.SoleTransactionDeclaration()
+		if tx == nil {
+			return b, fmt.Errorf("%s: not a single transaction", file)
+		}
+		params = tx.ParameterList
+		b.Signers = []string{}
+		if tx.Prepare != nil && tx.Prepare.FunctionDeclaration.ParameterList != nil {
+			for _, p := range tx.Prepare.FunctionDeclaration.ParameterList.Parameters {
+				b.Signers = append(b.Signers, p.Identifier.Identifier)
+			}
+		}
+	}
+	if params != nil {
+		for _, p := range params.Parameters {
+			b.Params = append(b.Params, Param{
+				Name: p.Identifier.Identifier,
+				Type: p.TypeAnnotation.Type,
+				Doc:  paramDoc(b.Doc, lines, params, p),
+			})
+		}
+	}
+	return
+}
+
+// Generate returns the formatted Go code of the bindings of the transactions and scripts of `root`
+func Generate(root string) ([]byte, error) {
+	bindings, err := Parse(root)
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "%s\n\npackage bindings\n\n", Header)
+	fmt.Fprintf(buf, "import (\n\t\"github.com/onflow/cadence\"\n\t\"github.com/onflow/flow-go-sdk\"\n\tutil %q\n)\n",
+		"github.com/flow-hydraulics/onchain-multisig")
+	for _, b := range bindings {
+		writeBinding(buf, b)
+	}
+	code, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated code does not compile: %w\n%s", err, buf.Bytes())
+	}
+	return code, nil
+}
+
+func writeBinding(buf *bytes.Buffer, b Binding) {
+	verb := "sends the transaction"
+	if b.IsScript() {
+		verb = "runs the script"
+	}
+	fmt.Fprintf(buf, "\n// %s %s %s", b.Name, verb, b.File)
+	if len(b.Doc) > 0 {
+		fmt.Fprint(buf, ":")
+		for _, line := range b.Doc {
+			fmt.Fprintf(buf, "\n// %s", line)
+		}
+	}
+	fmt.Fprint(buf, "\n")
+
+	params := []string{}
+	args := []string{}
+	if !b.IsScript() {
+		params = append(params, "roles util.TxRoles")
+	}
+	for _, p := range b.Params {
+		name := paramName(p.Name)
+		params = append(params, fmt.Sprintf("%s %s", name, goType(p.Type)))
+		args = append(args, encode(p.Type, name, 0))
+	}
+	callArgs := strings.Join(append([]string{fmt.Sprintf("%q", b.File)}, args...), ", ")
+
+	if !b.IsScript() {
+		fmt.Fprintf(buf, "func (c *Client) %s(%s) ([]flow.Event, error) {\n", b.Name, strings.Join(params, ", "))
+		fmt.Fprintf(buf, "\treturn c.send(roles, %s)\n}\n", callArgs)
+		return
+	}
+	fmt.Fprintf(buf, "func (c *Client) %s(%s) (result %s, err error) {\n", b.Name, strings.Join(params, ", "), goType(b.Returns))
+	fmt.Fprintf(buf, "\tvalue, err := c.run(%s)\n", callArgs)
+	fmt.Fprint(buf, "\tif err != nil {\n\t\treturn\n\t}\n")
+	fmt.Fprintf(buf, "\tdefer recoverDecode(%q, &err)\n", b.File)
+	fmt.Fprintf(buf, "\tresult = %s\n\treturn\n}\n", decode(b.Returns, "value", 0))
+}
+
+// leadingComments returns the lines of the line comments at the top of `code`, before any code
+func leadingComments(code []byte) (doc []string) {
+	for _, line := range strings.Split(string(code), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "//") {
+			break
+		}
+		doc = append(doc, strings.TrimSpace(strings.TrimPrefix(line, "//")))
+	}
+	for len(doc) > 0 && doc[len(doc)-1] == "" {
+		doc = doc[:len(doc)-1]
+	}
+	return
+}
+
+// paramDoc returns the description of the parameter `p` of `params`: the lines of the doc of the file starting with
+// its name in backquotes, or the comments above it or after it on its line when it is on a line of its own in the list
+func paramDoc(doc []string, lines []string, params *ast.ParameterList, p *ast.Parameter) string {
+	name := p.Identifier.Identifier
+	for _, line := range doc {
+		if strings.HasPrefix(line, "`"+name+"`") {
+			return line
+		}
+	}
+	line := p.StartPos.Line
+	if line == params.StartPos.Line || line == params.EndPos.Line {
+		return ""
+	}
+	for _, other := range params.Parameters {
+		if other != p && other.StartPos.Line == line {
+			return ""
+		}
+	}
+	comments := []string{}
+	for i := line - 2; i >= 0 && strings.HasPrefix(strings.TrimSpace(lines[i]), "//"); i-- {
+		comments = append([]string{strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(lines[i]), "//"))}, comments...)
+	}
+	if i := strings.Index(lines[line-1], "//"); i >= 0 {
+		comments = append(comments, strings.TrimSpace(lines[line-1][i+2:]))
+	}
+	return strings.Join(comments, " ")
+}
+
+// initialisms are the parts of file names that are all upper case in Go names
+var initialisms = map[string]string{"uuid": "UUID", "id": "ID"}
+
+// goName returns the exported Go name of a file name in snake or camel case
+func goName(file string) string {
+	name := ""
+	for _, part := range strings.Split(file, "_") {
+		if initialism, ok := initialisms[part]; ok {
+			name += initialism
+		} else if part != "" {
+			name += strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return name
+}
+
+// reserved are the names used in the generated functions
+var reserved = map[string]bool{
+	"c": true, "roles": true, "result": true, "value": true, "err": true,
+	"cadence": true, "flow": true, "util": true,
+}
+
+// paramName returns the name of a parameter in Go
+func paramName(name string) string {
+	if reserved[name] || token.IsKeyword(name) {
+		return "arg" + strings.ToUpper(name[:1]) + name[1:]
+	}
+	return name
+}
+
+// primitive is the Go type of a Cadence type and the conversions between them
+type primitive struct {
+	goType string
+	// encode and decode format the expression converting a Go value or a `cadence.Value`
+	encode string
+	decode string
+}
+
+var primitives = map[string]primitive{
+	"String":      {"string", "cadence.String(%s)", "string(%s.(cadence.String))"},
+	"Bool":        {"bool", "cadence.NewBool(%s)", "bool(%s.(cadence.Bool))"},
+	"UInt8":       {"uint8", "cadence.UInt8(%s)", "uint8(%s.(cadence.UInt8))"},
+	"UInt64":      {"uint64", "cadence.UInt64(%s)", "uint64(%s.(cadence.UInt64))"},
+	"UFix64":      {"cadence.UFix64", "%s", "%s.(cadence.UFix64)"},
+	"Address":     {"flow.Address", "cadence.BytesToAddress(%s.Bytes())", "flow.BytesToAddress(%s.(cadence.Address).Bytes())"},
+	"StoragePath": {"cadence.Path", "%s", "%s.(cadence.Path)"},
+	"PublicPath":  {"cadence.Path", "%s", "%s.(cadence.Path)"},
+}
+
+func nominal(t ast.Type) (primitive, bool) {
+	n, ok := t.(*ast.NominalType)
+	if !ok || len(n.NestedIdentifiers) > 0 {
+		return primitive{}, false
+	}
+	p, ok := primitives[n.Identifier.Identifier]
+	return p, ok
+}
+
+// typed returns true if `t` has a Go type other than `cadence.Value`
+func typed(t ast.Type) bool {
+	switch t := t.(type) {
+	case *ast.VariableSizedType:
+		return true
+	case *ast.OptionalType:
+		return typed(t.Type)
+	case *ast.DictionaryType:
+		_, ok := nominal(t.KeyType)
+		return ok
+	}
+	_, ok := nominal(t)
+	return ok
+}
+
+// goType returns the Go type of a Cadence type, `cadence.Value` if it has no Go type
+func goType(t ast.Type) string {
+	if !typed(t) {
+		return "cadence.Value"
+	}
+	switch t := t.(type) {
+	case *ast.VariableSizedType:
+		return "[]" + goType(t.Type)
+	case *ast.OptionalType:
+		return "*" + goType(t.Type)
+	case *ast.DictionaryType:
+		return fmt.Sprintf("map[%s]%s", goType(t.KeyType), goType(t.ValueType))
+	}
+	p, _ := nominal(t)
+	return p.goType
+}
+
+// encode returns the expression converting `expr` of the Go type of `t` to a `cadence.Value`,
+// `depth` makes the names of the variables of nested conversions unique
+func encode(t ast.Type, expr string, depth int) string {
+	if !typed(t) {
+		if _, ok := t.(*ast.OptionalType); ok {
+			return fmt.Sprintf("cadence.NewOptional(%s)", expr)
+		}
+		return expr
+	}
+	x := fmt.Sprintf("x%d", depth)
+	switch t := t.(type) {
+	case *ast.VariableSizedType:
+		return fmt.Sprintf("encodeArray(len(%s), func(i%d int) cadence.Value { return %s })",
+			expr, depth, encode(t.Type, fmt.Sprintf("%s[i%d]", expr, depth), depth+1))
+	case *ast.OptionalType:
+		return fmt.Sprintf("func() cadence.Value { if %s == nil { return cadence.NewOptional(nil) }; %s := *%s; return cadence.NewOptional(%s) }()",
+			expr, x, expr, encode(t.Type, x, depth+1))
+	case *ast.DictionaryType:
+		return fmt.Sprintf("func() cadence.Value { pairs := []cadence.KeyValuePair{}; for k%d, %s := range %s { pairs = append(pairs, cadence.KeyValuePair{Key: %s, Value: %s}) }; return cadence.NewDictionary(pairs) }()",
+			depth, x, expr, encode(t.KeyType, fmt.Sprintf("k%d", depth), depth+1), encode(t.ValueType, x, depth+1))
+	}
+	p, _ := nominal(t)
+	return fmt.Sprintf(p.encode, expr)
+}
+
+// decode returns the expression converting `expr` of type `cadence.Value` to the Go type of `t`,
+// it panics if `expr` does not have the type `t`
+func decode(t ast.Type, expr string, depth int) string {
+	if !typed(t) {
+		return expr
+	}
+	x := fmt.Sprintf("x%d", depth)
+	switch t := t.(type) {
+	case *ast.VariableSizedType:
+		return fmt.Sprintf("func() %s { r := %s{}; for _, %s := range %s.(cadence.Array).Values { r = append(r, %s) }; return r }()",
+			goType(t), goType(t), x, expr, decode(t.Type, x, depth+1))
+	case *ast.OptionalType:
+		return fmt.Sprintf("func() %s { %s := %s.(cadence.Optional).Value; if %s == nil { return nil }; r := %s; return &r }()",
+			goType(t), x, expr, x, decode(t.Type, x, depth+1))
+	case *ast.DictionaryType:
+		return fmt.Sprintf("func() %s { r := %s{}; for _, %s := range %s.(cadence.Dictionary).Pairs { r[%s] = %s }; return r }()",
+			goType(t), goType(t), x, expr, decode(t.KeyType, x+".Key", depth+1), decode(t.ValueType, x+".Value", depth+1))
+	}
+	p, _ := nominal(t)
+	return fmt.Sprintf(p.decode, expr)
+}
@@ -0,0 +1,146 @@
+package generator
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newRoot returns a repository with the transactions and scripts in `files` by path
+func newRoot(t *testing.T, files map[string]string) string {
+	root, err := ioutil.TempDir("", "bindings")
+	assert.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(root) })
+	for _, dir := range Dirs {
+		assert.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+	}
+	for path, code := range files {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(root, path), []byte(code), 0644))
+	}
+	return root
+}
+
+const tx = `// This transaction sets a policy
+//
+// The owner authorizes it
+import OnChainMultiSig from 0x{{.OnChainMultiSig}}
+
+transaction(publicKey: String, allowedMethods: [String]?, methodWeights: {String: UFix64}, type: UInt8, v: AnyStruct) {
+    prepare(owner: AuthAccount) {}
+}
+`
+
+const multiline = `transaction(
+    // the name of the contract
+    contractName: String,
+    code: String, // its Cadence code
+    a: Int, b: Int, // not the doc of a or b
+    c: Int) { // not the doc of c
+    prepare(owner: AuthAccount, payer: AuthAccount) {}
+}
+`
+
+const script = `import MultiSigFlowToken from 0x{{.MultiSigFlowToken}}
+
+pub fun main(account: Address, txIndex: UInt64): {UInt64: [Address?]} {
+    return {}
+}
+`
+
+func TestParseFile(t *testing.T) {
+	root := newRoot(t, map[string]string{"transactions/set_key_policy.cdc": tx, "scripts/get_recipients.cdc": script})
+
+	b, err := ParseFile(root, "transactions/set_key_policy.cdc")
+	assert.NoError(t, err)
+	assert.Equal(t, "SetKeyPolicy", b.Name)
+	assert.False(t, b.IsScript())
+	assert.Equal(t, []string{"This transaction sets a policy", "", "The owner authorizes it"}, b.Doc)
+	assert.Nil(t, b.Returns)
+	assert.Equal(t, []string{"owner"}, b.Signers)
+	types := []string{}
+	for _, p := range b.Params {
+		types = append(types, paramName(p.Name)+" "+goType(p.Type))
+	}
+	assert.Equal(t, []string{
+		"publicKey string",
+		"allowedMethods *[]string",
+		"methodWeights map[string]cadence.UFix64",
+		"argType uint8",
+		"v cadence.Value",
+	}, types)
+
+	b, err = ParseFile(root, "scripts/get_recipients.cdc")
+	assert.NoError(t, err)
+	assert.Equal(t, "GetRecipients", b.Name)
+	assert.True(t, b.IsScript())
+	assert.Empty(t, b.Doc)
+	assert.Len(t, b.Params, 2)
+	assert.Nil(t, b.Signers)
+	assert.Equal(t, "map[uint64][]*flow.Address", goType(b.Returns))
+}
+
+func TestParamDocs(t *testing.T) {
+	root := newRoot(t, map[string]string{
+		"transactions/set_key_policy.cdc": strings.Replace(tx, "// The owner authorizes it",
+			"// The owner authorizes it\n// `allowedMethods` of nil allows all methods", 1),
+		"transactions/deploy.cdc": multiline,
+	})
+
+	b, err := ParseFile(root, "transactions/set_key_policy.cdc")
+	assert.NoError(t, err)
+	docs := map[string]string{}
+	for _, p := range b.Params {
+		docs[p.Name] = p.Doc
+	}
+	assert.Equal(t, map[string]string{"publicKey": "", "allowedMethods": "`allowedMethods` of nil allows all methods",
+		"methodWeights": "", "type": "", "v": ""}, docs)
+
+	b, err = ParseFile(root, "transactions/deploy.cdc")
+	assert.NoError(t, err)
+	docs = map[string]string{}
+	for _, p := range b.Params {
+		docs[p.Name] = p.Doc
+	}
+	assert.Equal(t, map[string]string{"contractName": "the name of the contract", "code": "its Cadence code",
+		"a": "", "b": "", "c": ""}, docs)
+	assert.Equal(t, []string{"owner", "payer"}, b.Signers)
+}
+
+func TestGenerate(t *testing.T) {
+	root := newRoot(t, map[string]string{"transactions/set_key_policy.cdc": tx, "scripts/get_recipients.cdc": script})
+	code, err := Generate(root)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	s := string(code)
+	assert.True(t, strings.HasPrefix(s, Header+"\n"))
+	assert.Contains(t, s, "func (c *Client) SetKeyPolicy(roles util.TxRoles, publicKey string, allowedMethods *[]string, "+
+		"methodWeights map[string]cadence.UFix64, argType uint8, v cadence.Value) ([]flow.Event, error) {")
+	assert.Contains(t, s, "func (c *Client) GetRecipients(account flow.Address, txIndex uint64) "+
+		"(result map[uint64][]*flow.Address, err error) {")
+	assert.Contains(t, s, "// SetKeyPolicy sends the transaction transactions/set_key_policy.cdc:\n// This transaction sets a policy\n")
+}
+
+func TestGoNames(t *testing.T) {
+	assert.Equal(t, "GetVaultUUID", goName("get_vault_uuid"))
+	assert.Equal(t, "ExecuteTx", goName("executeTx"))
+	assert.Equal(t, "OwnerUpdateKeyList", goName("ownerUpdateKeyList"))
+}
+
+func TestFilesBoundToTheSameNameError(t *testing.T) {
+	root := newRoot(t, map[string]string{"scripts/get_recipients.cdc": script, "scripts/getRecipients.cdc": script})
+	_, err := Generate(root)
+	assert.Error(t, err)
+}
+
+func TestInvalidCadenceErrors(t *testing.T) {
+	root := newRoot(t, map[string]string{"transactions/broken.cdc": "transaction(a: ) {}"})
+	_, err := Generate(root)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "transactions/broken.cdc")
+	}
+}
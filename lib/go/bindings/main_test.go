@@ -0,0 +1,15 @@
+package bindings
+
+import (
+	"os"
+	"testing"
+
+	"github.com/flow-hydraulics/onchain-multisig/emulator"
+)
+
+var emu *emulator.Emulator
+
+func TestMain(m *testing.M) {
+	emu = emulator.MustStart("../../..")
+	os.Exit(emu.Run(m))
+}
@@ -0,0 +1,280 @@
+package checker
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strconv"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// The Go module of the repository, relative to its root
+const Module = "lib/go"
+
+const (
+	utilPath    = "github.com/flow-hydraulics/onchain-multisig"
+	cadencePath = "github.com/onflow/cadence"
+)
+
+// builder is a util function building a payload, with the index of its method param and of its args
+type builder struct {
+	method   int
+	args     int
+	variadic bool
+}
+
+var builders = map[string]builder{
+	"GetSignableDataFromScript": {method: 3, args: 4, variadic: true},
+	"GetSignableDataAt":         {method: 4, args: 5, variadic: true},
+	"MultiSig_VaultNewPayload":  {method: 3, args: 4},
+	"SubmitNewPayload":          {method: 4, args: 5},
+}
+
+// FindCalls returns the calls of the util functions building payloads in the Go module, tests excluded.
+// Calls whose method or args are not known statically are skipped
+func FindCalls(root string) (calls []Call, err error) {
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedTypesSizes,
+		Dir:  filepath.Join(root, Module),
+	}
+	pkgs, err := packages.Load(cfg, "./...")
+	if err != nil {
+		return
+	}
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) > 0 {
+			return nil, fmt.Errorf("%s: %s", pkg.PkgPath, pkg.Errors[0])
+		}
+		for _, file := range pkg.Syntax {
+			calls = append(calls, findCalls(root, pkg, file)...)
+		}
+	}
+	return
+}
+
+func findCalls(root string, pkg *packages.Package, file *ast.File) (calls []Call) {
+	for _, decl := range file.Decls {
+		f, ok := decl.(*ast.FuncDecl)
+		if !ok || f.Body == nil {
+			continue
+		}
+		s := newScope(pkg.TypesInfo, f.Body)
+		ast.Inspect(f.Body, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			callee := calledFunc(pkg.TypesInfo, call)
+			if callee == nil || callee.Pkg() == nil || callee.Pkg().Path() != utilPath {
+				return true
+			}
+			b, ok := builders[callee.Name()]
+			if !ok || len(call.Args) <= b.args {
+				return true
+			}
+			c := Call{Func: pkg.Name + "." + f.Name.Name, Callee: "util." + callee.Name(), Pos: position(root, pkg.Fset, call.Pos())}
+			if c.Method, ok = s.stringValue(call.Args[b.method]); !ok {
+				return true
+			}
+			args := call.Args[b.args:]
+			if !b.variadic || call.Ellipsis.IsValid() {
+				lit, ok := s.resolve(call.Args[b.args]).(*ast.CompositeLit)
+				if !ok {
+					return true
+				}
+				args = lit.Elts
+			}
+			c.Args = []string{}
+			for _, arg := range args {
+				c.Args = append(c.Args, cadenceType(pkg.TypesInfo.TypeOf(arg)))
+			}
+			calls = append(calls, c)
+			return true
+		})
+	}
+	return
+}
+
+// scope holds the values of the variables of a function assigned only where they are declared
+type scope struct {
+	info *types.Info
+	defs map[types.Object]ast.Expr
+}
+
+func newScope(info *types.Info, body *ast.BlockStmt) scope {
+	defs := map[types.Object]ast.Expr{}
+	reassigned := map[types.Object]bool{}
+	ast.Inspect(body, func(n ast.Node) bool {
+		assign, ok := n.(*ast.AssignStmt)
+		if !ok {
+			return true
+		}
+		for i, lhs := range assign.Lhs {
+			id, ok := lhs.(*ast.Ident)
+			if !ok {
+				continue
+			}
+			if obj := info.Defs[id]; obj != nil && len(assign.Lhs) == len(assign.Rhs) {
+				defs[obj] = assign.Rhs[i]
+			} else if obj := info.Uses[id]; obj != nil {
+				reassigned[obj] = true
+			}
+		}
+		return true
+	})
+	for obj := range reassigned {
+		delete(defs, obj)
+	}
+	return scope{info, defs}
+}
+
+// resolve returns the value of `e` if it is a variable of the scope
+func (s scope) resolve(e ast.Expr) ast.Expr {
+	if id, ok := e.(*ast.Ident); ok {
+		if def, ok := s.defs[s.info.Uses[id]]; ok {
+			return def
+		}
+	}
+	return e
+}
+
+// stringValue returns the value of a string constant or of a variable of the scope assigned one
+func (s scope) stringValue(e ast.Expr) (string, bool) {
+	if tv, ok := s.info.Types[s.resolve(e)]; ok && tv.Value != nil && tv.Value.Kind() == constant.String {
+		return constant.StringVal(tv.Value), true
+	}
+	return "", false
+}
+
+func calledFunc(info *types.Info, call *ast.CallExpr) *types.Func {
+	var id *ast.Ident
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	default:
+		return nil
+	}
+	f, _ := info.Uses[id].(*types.Func)
+	return f
+}
+
+// cadenceType returns the Cadence type of a value of the Go type `t`, or the Go type in parentheses if it is not
+// a type of the cadence package
+func cadenceType(t types.Type) string {
+	if named, ok := t.(*types.Named); ok {
+		obj := named.Obj()
+		if obj.Pkg() != nil && obj.Pkg().Path() == cadencePath {
+			return obj.Name()
+		}
+	}
+	return "(" + types.TypeString(t, nil) + ")"
+}
+
+func position(root string, fset *token.FileSet, pos token.Pos) string {
+	p := fset.Position(pos)
+	if abs, err := filepath.Abs(root); err == nil {
+		if rel, err := filepath.Rel(abs, p.Filename); err == nil {
+			p.Filename = rel
+		}
+	}
+	return p.Filename + ":" + strconv.Itoa(p.Line)
+}
+
+// The file of the model of the vault, relative to the root of the repository
+const ModelFile = Module + "/model/vault.go"
+
+// argFuncs are the functions the model gets the args of payloads with, by the Cadence types they return
+var argFuncs = map[string]string{
+	"stringArg":  "String",
+	"ufix64Arg":  "UFix64",
+	"uint64Arg":  "UInt64",
+	"uint8Arg":   "UInt8",
+	"addressArg": "Address",
+}
+
+// FindModelMethods returns the methods handled by `Vault.executeTx` in the model
+func FindModelMethods(root string) (methods []Method, err error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filepath.Join(root, ModelFile), nil, 0)
+	if err != nil {
+		return
+	}
+	var sw *ast.SwitchStmt
+	for _, decl := range file.Decls {
+		f, ok := decl.(*ast.FuncDecl)
+		if !ok || f.Recv == nil || f.Name.Name != "executeTx" || f.Body == nil {
+			continue
+		}
+		for _, s := range f.Body.List {
+			if s, ok := s.(*ast.SwitchStmt); ok && isSelector(s.Tag, "p", "Method") {
+				sw = s
+			}
+		}
+	}
+	if sw == nil {
+		return nil, fmt.Errorf("%s: executeTx does not switch on p.Method", ModelFile)
+	}
+	for _, s := range sw.Body.List {
+		c := s.(*ast.CaseClause)
+		if len(c.List) != 1 {
+			return nil, fmt.Errorf("%s: a case of executeTx is not a single method name", position(root, fset, c.Pos()))
+		}
+		lit, ok := c.List[0].(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			return nil, fmt.Errorf("%s: a case of executeTx is not a method name", position(root, fset, c.Pos()))
+		}
+		name, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			return nil, err
+		}
+		m := Method{Name: name, Pos: position(root, fset, c.Pos())}
+		for _, s := range c.Body {
+			ast.Inspect(s, func(n ast.Node) bool {
+				if isSelector(n, "p", "Resource") {
+					m.Resource = true
+				}
+				call, ok := n.(*ast.CallExpr)
+				if !ok || len(call.Args) < 2 {
+					return true
+				}
+				fun, ok := call.Fun.(*ast.Ident)
+				if !ok {
+					return true
+				}
+				t, ok := argFuncs[fun.Name]
+				index, isInt := call.Args[1].(*ast.BasicLit)
+				if !ok || !isInt || index.Kind != token.INT {
+					return true
+				}
+				i, err := strconv.Atoi(index.Value)
+				if err != nil {
+					return true
+				}
+				for len(m.Args) <= i {
+					m.Args = append(m.Args, "")
+				}
+				m.Args[i] = t
+				return true
+			})
+		}
+		methods = append(methods, m)
+	}
+	return
+}
+
+// isSelector returns true if `n` is `x.sel`
+func isSelector(n ast.Node, x string, sel string) bool {
+	s, ok := n.(*ast.SelectorExpr)
+	if !ok || s.Sel.Name != sel {
+		return false
+	}
+	id, ok := s.X.(*ast.Ident)
+	return ok && id.Name == x
+}
@@ -0,0 +1,425 @@
+// Package checker checks that the Go code building payloads agrees with `MultiSigFlowToken.Vault.executeTx`.
+//
+// The methods `executeTx` handles and the types it casts their args to are parsed from the contract with the Cadence
+// parser. The payloads built in Go are found by type checking the Go packages: the method and args passed to
+// `util.GetSignableDataFromScript`, `util.GetSignableDataAt`, `util.MultiSig_VaultNewPayload` and `util.SubmitNewPayload`
+// must be those of a method of `executeTx`, and the `model` package must handle the same methods with the same arg types.
+// The methods of `OwnerMethods` are parsed from the functions of the Vault that execute them instead of `executeTx`.
+// The arg types must also be encoded by `OnChainMultiSig.encodeSignableValue`, which `scripts/calc_signable_data.cdc`
+// calls, and by the `encoder` package.
+package checker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/flow-hydraulics/onchain-multisig/encoder"
+	"github.com/onflow/cadence"
+	"github.com/onflow/cadence/runtime/ast"
+	"github.com/onflow/cadence/runtime/parser2"
+)
+
+// The files the checker parses, relative to the root of the repository
+const (
+	VaultContract      = "contracts/MultiSigFlowToken.cdc"
+	MultiSigContract   = "contracts/OnChainMultiSig.cdc"
+	SignableDataScript = "scripts/calc_signable_data.cdc"
+)
+
+// OwnerMethods are the methods whose payloads are executed by the owner of the Vault with the function of the same name,
+// rather than by `executeTx`, which rejects them. The model does not handle them
+var OwnerMethods = []string{"migrate"}
+
+// Method is a method handled by `executeTx`, or by the function of the Vault of the same name if `Owner` is true
+type Method struct {
+	Name string
+	// Owner is true for the methods of `OwnerMethods`
+	Owner bool
+	// Args are the Cadence types `executeTx` casts the args of the method to by index,
+	// an empty type if the arg at the index is not cast
+	Args []string
+	// Resource is true if the method moves the resource of the payload, whose balance `PayloadDetails` requires
+	// as its first arg and the address it is returned to if the payload is removed as its second
+	Resource bool
+	// Pos is the file and line of the case or the function of the method
+	Pos string
+}
+
+// Expected returns the types of the args of a payload of the method
+func (m Method) Expected() []string {
+	if m.Resource {
+		return append([]string{"UFix64", "Address"}, m.Args...)
+	}
+	return m.Args
+}
+
+// Call is a call building a payload in Go
+type Call struct {
+	// Func is the function making the call and Callee the function it calls
+	Func   string
+	Callee string
+	// Pos is the file and line of the call
+	Pos    string
+	Method string
+	// Args are the Cadence types of the args of the call, or their Go types in parentheses if they have none
+	Args []string
+}
+
+// Drift is a disagreement between the contract and the Go code or the scripts
+type Drift struct {
+	Pos     string
+	Message string
+}
+
+func (d Drift) String() string {
+	return fmt.Sprintf("%s: %s", d.Pos, d.Message)
+}
+
+// Report is the result of a check
+type Report struct {
+	Methods []Method
+	Calls   []Call
+	Drifts  []Drift
+}
+
+// OK returns true if nothing drifted
+func (r Report) OK() bool {
+	return len(r.Drifts) == 0
+}
+
+func (r Report) String() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%d methods of executeTx, %d payloads built in Go\n", len(r.Methods), len(r.Calls))
+	for _, d := range r.Drifts {
+		fmt.Fprintln(b, d)
+	}
+	if r.OK() {
+		fmt.Fprintln(b, "no drift")
+	}
+	return b.String()
+}
+
+// Check checks the repository at `root`
+func Check(root string) (r Report, err error) {
+	r.Methods, err = ParseExecuteTx(root)
+	if err != nil {
+		return
+	}
+	supported, err := ParseSignableTypes(root)
+	if err != nil {
+		return
+	}
+	r.Drifts = append(r.Drifts, checkSignableDataScript(root)...)
+	r.Drifts = append(r.Drifts, CheckEncoding(r.Methods, supported)...)
+
+	r.Calls, err = FindCalls(root)
+	if err != nil {
+		return
+	}
+	r.Drifts = append(r.Drifts, CheckCalls(r.Methods, r.Calls)...)
+
+	model, err := FindModelMethods(root)
+	if err != nil {
+		return
+	}
+	r.Drifts = append(r.Drifts, CheckModel(r.Methods, model)...)
+	return
+}
+
+// CheckEncoding checks that the arg types of `methods` are in the types `supported` by `encodeSignableValue`
+// and that the `encoder` package encodes them
+func CheckEncoding(methods []Method, supported map[string]bool) (drifts []Drift) {
+	for _, m := range methods {
+		for i, t := range m.Expected() {
+			if t == "" {
+				continue
+			}
+			if !supported[t] {
+				drifts = append(drifts, Drift{m.Pos, fmt.Sprintf(
+					"%s arg %d is %s, which OnChainMultiSig.encodeSignableValue does not encode", m.Name, i, t)})
+			}
+			v, ok := zeroValues[t]
+			if !ok {
+				drifts = append(drifts, Drift{m.Pos, fmt.Sprintf(
+					"%s arg %d is %s, which the encoder package does not encode", m.Name, i, t)})
+				continue
+			}
+			if _, err := encoder.EncodeValue(v); err != nil {
+				drifts = append(drifts, Drift{m.Pos, fmt.Sprintf(
+					"%s arg %d is %s, which the encoder package does not encode: %s", m.Name, i, t, err)})
+			}
+		}
+	}
+	return
+}
+
+// zeroValues are values of the Cadence types of args
+var zeroValues = map[string]cadence.Value{
+	"String":  cadence.String(""),
+	"Bool":    cadence.NewBool(false),
+	"Int":     cadence.NewInt(0),
+	"Int64":   cadence.NewInt64(0),
+	"UInt8":   cadence.UInt8(0),
+	"UInt32":  cadence.UInt32(0),
+	"UInt64":  cadence.UInt64(0),
+	"Fix64":   cadence.Fix64(0),
+	"UFix64":  cadence.UFix64(0),
+	"Address": cadence.Address{},
+}
+
+// CheckCalls checks that the payloads built by `calls` are of `methods` and have args of the types of the method
+func CheckCalls(methods []Method, calls []Call) (drifts []Drift) {
+	byName := map[string]Method{}
+	for _, m := range methods {
+		byName[m.Name] = m
+	}
+	for _, c := range calls {
+		m, ok := byName[c.Method]
+		if !ok {
+			drifts = append(drifts, Drift{c.Pos, fmt.Sprintf("%s builds a %s payload, which executeTx does not handle", c.Func, c.Method)})
+			continue
+		}
+		expected := m.Expected()
+		if len(c.Args) != len(expected) {
+			drifts = append(drifts, Drift{c.Pos, fmt.Sprintf("%s passes %d args of %s to %s, executeTx takes %d (%s)",
+				c.Func, len(c.Args), c.Method, c.Callee, len(expected), m.Pos)})
+			continue
+		}
+		for i, t := range c.Args {
+			if expected[i] != "" && t != expected[i] {
+				drifts = append(drifts, Drift{c.Pos, fmt.Sprintf("%s passes %s arg %d of type %s to %s, executeTx casts it to %s (%s)",
+					c.Func, c.Method, i, t, c.Callee, expected[i], m.Pos)})
+			}
+		}
+	}
+	return
+}
+
+// CheckModel checks that the model handles `methods` with args of the same types
+func CheckModel(methods []Method, model []Method) (drifts []Drift) {
+	byName := map[string]Method{}
+	for _, m := range model {
+		byName[m.Name] = m
+	}
+	for _, m := range methods {
+		if m.Owner {
+			continue
+		}
+		mm, ok := byName[m.Name]
+		if !ok {
+			drifts = append(drifts, Drift{m.Pos, fmt.Sprintf("the model does not handle %s", m.Name)})
+			continue
+		}
+		delete(byName, m.Name)
+		if strings.Join(mm.Args, ",") != strings.Join(m.Args, ",") || mm.Resource != m.Resource {
+			drifts = append(drifts, Drift{mm.Pos, fmt.Sprintf("the model handles %s with args %s and resource %t, executeTx with args %s and resource %t (%s)",
+				m.Name, formatTypes(mm.Args), mm.Resource, formatTypes(m.Args), m.Resource, m.Pos)})
+		}
+	}
+	for _, mm := range byName {
+		drifts = append(drifts, Drift{mm.Pos, fmt.Sprintf("the model handles %s, which executeTx does not handle", mm.Name)})
+	}
+	sort.Slice(drifts, func(i, j int) bool { return drifts[i].Pos < drifts[j].Pos })
+	return
+}
+
+func formatTypes(types []string) string {
+	return "[" + strings.Join(types, ", ") + "]"
+}
+
+// placeholder matches the placeholders of the addresses of imports in the Cadence templates
+var placeholder = regexp.MustCompile(`{{\s*\.\w+\s*}}`)
+
+func parseCadence(root string, file string) (*ast.Program, error) {
+	code, err := ioutil.ReadFile(filepath.Join(root, file))
+	if err != nil {
+		return nil, err
+	}
+	program, err := parser2.ParseProgram(placeholder.ReplaceAllString(string(code), "0000000000000000"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return program, nil
+}
+
+// contractFunction returns the function `name` of the contract of `program`, or of its resource `resource`
+func contractFunction(program *ast.Program, resource string, name string) *ast.FunctionDeclaration {
+	contract := program.SoleContractDeclaration()
+	if contract == nil {
+		return nil
+	}
+	members := contract.Members
+	if resource != "" {
+		r, ok := members.CompositesByIdentifier()[resource]
+		if !ok {
+			return nil
+		}
+		members = r.Members
+	}
+	return members.FunctionsByIdentifier()[name]
+}
+
+// ParseExecuteTx returns the methods handled by `MultiSigFlowToken.Vault.executeTx` in their order in the contract
+func ParseExecuteTx(root string) (methods []Method, err error) {
+	program, err := parseCadence(root, VaultContract)
+	if err != nil {
+		return
+	}
+	executeTx := contractFunction(program, "Vault", "executeTx")
+	if executeTx == nil || executeTx.FunctionBlock == nil {
+		return nil, fmt.Errorf("%s: no Vault.executeTx", VaultContract)
+	}
+	var sw *ast.SwitchStatement
+	for _, s := range executeTx.FunctionBlock.Block.Statements {
+		if s, ok := s.(*ast.SwitchStatement); ok && isMember(s.Expression, "p", "method") {
+			sw = s
+		}
+	}
+	if sw == nil {
+		return nil, fmt.Errorf("%s: executeTx does not switch on p.method", VaultContract)
+	}
+	for _, c := range sw.Cases {
+		name, ok := c.Expression.(*ast.StringExpression)
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: the case of executeTx is not a method name", VaultContract, c.StartPos.Line)
+		}
+		m := Method{Name: name.Value, Pos: fmt.Sprintf("%s:%d", VaultContract, c.StartPos.Line)}
+		parseMethodStatements(&m, c.Statements)
+		methods = append(methods, m)
+	}
+	for _, name := range OwnerMethods {
+		f := contractFunction(program, "Vault", name)
+		if f == nil || f.FunctionBlock == nil {
+			return nil, fmt.Errorf("%s: no Vault.%s", VaultContract, name)
+		}
+		m := Method{Name: name, Owner: true, Pos: fmt.Sprintf("%s:%d", VaultContract, f.StartPos.Line)}
+		parseMethodStatements(&m, f.FunctionBlock.Block.Statements)
+		methods = append(methods, m)
+	}
+	return
+}
+
+// parseMethodStatements sets the args and the resource of `m` from the statements executing its payload `p`
+func parseMethodStatements(m *Method, statements []ast.Statement) {
+	for _, s := range statements {
+		switch s := s.(type) {
+		case *ast.VariableDeclaration:
+			i, t, ok := argCast(s.Value)
+			if !ok {
+				continue
+			}
+			for len(m.Args) <= i {
+				m.Args = append(m.Args, "")
+			}
+			m.Args[i] = t
+		case *ast.SwapStatement:
+			if isMember(s.Left, "p", "rsc") || isMember(s.Right, "p", "rsc") {
+				m.Resource = true
+			}
+		}
+	}
+}
+
+// isMember returns true if `e` is `object.member`
+func isMember(e ast.Expression, object string, member string) bool {
+	m, ok := e.(*ast.MemberExpression)
+	if !ok || m.Identifier.Identifier != member {
+		return false
+	}
+	id, ok := m.Expression.(*ast.IdentifierExpression)
+	return ok && id.Identifier.Identifier == object
+}
+
+// argCast returns the index and type of an arg cast as `p.getArg(i: i)! as? T ?? panic(...)`
+func argCast(e ast.Expression) (i int, t string, ok bool) {
+	if b, isBinary := e.(*ast.BinaryExpression); isBinary && b.Operation == ast.OperationNilCoalesce {
+		e = b.Left
+	}
+	cast, ok := e.(*ast.CastingExpression)
+	if !ok {
+		return
+	}
+	force, ok := cast.Expression.(*ast.ForceExpression)
+	if !ok {
+		return
+	}
+	invocation, ok := force.Expression.(*ast.InvocationExpression)
+	if !ok || !isMember(invocation.InvokedExpression, "p", "getArg") || len(invocation.Arguments) != 1 {
+		return 0, "", false
+	}
+	index, ok := invocation.Arguments[0].Expression.(*ast.IntegerExpression)
+	if !ok {
+		return
+	}
+	return int(index.Value.Int64()), cast.TypeAnnotation.Type.String(), true
+}
+
+// ParseSignableTypes returns the types `OnChainMultiSig.encodeSignableValue` encodes
+func ParseSignableTypes(root string) (types map[string]bool, err error) {
+	program, err := parseCadence(root, MultiSigContract)
+	if err != nil {
+		return
+	}
+	encode := contractFunction(program, "", "encodeSignableValue")
+	if encode == nil || encode.FunctionBlock == nil {
+		return nil, fmt.Errorf("%s: no encodeSignableValue", MultiSigContract)
+	}
+	types = map[string]bool{}
+	for _, s := range encode.FunctionBlock.Block.Statements {
+		sw, ok := s.(*ast.SwitchStatement)
+		if !ok {
+			continue
+		}
+		for _, c := range sw.Cases {
+			// case Type<T>():
+			invocation, ok := c.Expression.(*ast.InvocationExpression)
+			if !ok || len(invocation.TypeArguments) != 1 {
+				continue
+			}
+			types[invocation.TypeArguments[0].Type.String()] = true
+		}
+	}
+	return
+}
+
+// checkSignableDataScript checks that `scripts/calc_signable_data.cdc` takes any value and encodes it with
+// `OnChainMultiSig.encodeSignableValue`
+func checkSignableDataScript(root string) (drifts []Drift) {
+	program, err := parseCadence(root, SignableDataScript)
+	if err != nil {
+		return []Drift{{SignableDataScript, err.Error()}}
+	}
+	var main *ast.FunctionDeclaration
+	for _, f := range program.FunctionDeclarations() {
+		if f.Identifier.Identifier == "main" {
+			main = f
+		}
+	}
+	if main == nil || main.FunctionBlock == nil {
+		return []Drift{{SignableDataScript, "no main function"}}
+	}
+	pos := fmt.Sprintf("%s:%d", SignableDataScript, main.StartPos.Line)
+	params := main.ParameterList.Parameters
+	if len(params) != 1 || params[0].TypeAnnotation.Type.String() != "AnyStruct?" {
+		drifts = append(drifts, Drift{pos, "main does not take a single AnyStruct? arg"})
+	}
+	encodes := false
+	for _, s := range main.FunctionBlock.Block.Statements {
+		r, ok := s.(*ast.ReturnStatement)
+		if !ok {
+			continue
+		}
+		if invocation, ok := r.Expression.(*ast.InvocationExpression); ok &&
+			isMember(invocation.InvokedExpression, "OnChainMultiSig", "encodeSignableValue") {
+			encodes = true
+		}
+	}
+	if !encodes {
+		drifts = append(drifts, Drift{pos, "main does not return OnChainMultiSig.encodeSignableValue of its arg"})
+	}
+	return
+}
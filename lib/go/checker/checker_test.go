@@ -0,0 +1,103 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const root = "../../.."
+
+func TestRepositoryIsConsistent(t *testing.T) {
+	report, err := Check(root)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.True(t, report.OK(), report.String())
+}
+
+func TestParseExecuteTx(t *testing.T) {
+	methods, err := ParseExecuteTx(root)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	byName := map[string]Method{}
+	for _, m := range methods {
+		byName[m.Name] = m
+	}
+	assert.Equal(t, []string{"String", "UFix64", "UInt8"}, byName["configureKey"].Args)
+	assert.Equal(t, []string{"UFix64", "Address"}, byName["transfer"].Expected())
+	assert.False(t, byName["removePayload"].Resource)
+	assert.True(t, byName["deposit"].Resource)
+	assert.Equal(t, []string{"UFix64", "Address"}, byName["deposit"].Expected())
+	assert.Empty(t, byName["removeSpendingLimit"].Args)
+	assert.True(t, strings.HasPrefix(byName["withdraw"].Pos, VaultContract+":"))
+	assert.True(t, byName["migrate"].Owner)
+	assert.Equal(t, []string{"UInt64"}, byName["migrate"].Args)
+}
+
+func TestFindCalls(t *testing.T) {
+	calls, err := FindCalls(root)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	found := []Call{}
+	for _, c := range calls {
+		if c.Func == "vault.MultiSig_Transfer" {
+			c.Pos = ""
+			found = append(found, c)
+		}
+	}
+	assert.ElementsMatch(t, []Call{
+		{Func: "vault.MultiSig_Transfer", Callee: "util.GetSignableDataFromScript", Method: "transfer", Args: []string{"UFix64", "Address"}},
+		{Func: "vault.MultiSig_Transfer", Callee: "util.MultiSig_VaultNewPayload", Method: "transfer", Args: []string{"UFix64", "Address"}},
+	}, found)
+}
+
+var methods = []Method{
+	{Name: "removeKey", Args: []string{"String"}, Pos: "contract:1"},
+	{Name: "deposit", Resource: true, Pos: "contract:2"},
+	{Name: "transfer", Args: []string{"UFix64", "Address"}, Pos: "contract:3"},
+}
+
+func TestCheckCalls(t *testing.T) {
+	drifts := CheckCalls(methods, []Call{
+		{Func: "f", Callee: "g", Pos: "a.go:1", Method: "removeKey", Args: []string{"String"}},
+		{Func: "f", Callee: "g", Pos: "a.go:2", Method: "deposit", Args: []string{"UFix64", "Address"}},
+		{Func: "f", Callee: "g", Pos: "a.go:3", Method: "configureKey", Args: []string{"String"}},
+		{Func: "f", Callee: "g", Pos: "a.go:4", Method: "deposit", Args: []string{}},
+		{Func: "f", Callee: "g", Pos: "a.go:5", Method: "transfer", Args: []string{"UFix64", "(string)"}},
+	})
+	assert.Equal(t, []Drift{
+		{"a.go:3", "f builds a configureKey payload, which executeTx does not handle"},
+		{"a.go:4", "f passes 0 args of deposit to g, executeTx takes 2 (contract:2)"},
+		{"a.go:5", "f passes transfer arg 1 of type (string) to g, executeTx casts it to Address (contract:3)"},
+	}, drifts)
+}
+
+func TestCheckModel(t *testing.T) {
+	drifts := CheckModel(methods, []Method{
+		{Name: "removeKey", Args: []string{"String"}, Pos: "model:1"},
+		{Name: "deposit", Pos: "model:2"},
+		{Name: "withdraw", Args: []string{"UFix64"}, Pos: "model:4"},
+	})
+	assert.Equal(t, []Drift{
+		{"contract:3", "the model does not handle transfer"},
+		{"model:2", "the model handles deposit with args [] and resource false, executeTx with args [] and resource true (contract:2)"},
+		{"model:4", "the model handles withdraw, which executeTx does not handle"},
+	}, drifts)
+}
+
+func TestCheckEncoding(t *testing.T) {
+	drifts := CheckEncoding(methods, map[string]bool{"String": true, "UFix64": true})
+	assert.Equal(t, []Drift{
+		{"contract:2", "deposit arg 1 is Address, which OnChainMultiSig.encodeSignableValue does not encode"},
+		{"contract:3", "transfer arg 1 is Address, which OnChainMultiSig.encodeSignableValue does not encode"},
+	}, drifts)
+
+	supported, err := ParseSignableTypes(root)
+	assert.NoError(t, err)
+	assert.Empty(t, CheckEncoding([]Method{{Name: "f", Args: []string{"Int64", "Bool", "Fix64"}}}, supported))
+	assert.Len(t, CheckEncoding([]Method{{Name: "f", Args: []string{"UInt16"}}}, supported), 2)
+}
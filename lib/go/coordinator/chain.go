@@ -0,0 +1,173 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"time"
+
+	"github.com/bjartek/go-with-the-flow/gwtf"
+	util "github.com/flow-hydraulics/onchain-multisig"
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/crypto"
+)
+
+// Chain is the view of the multisig vaults the coordinator reads and submits payloads to
+type Chain interface {
+	GetTxIndex(vault flow.Address) (uint64, error)
+	GetSignableDomain(vault flow.Address) ([]byte, error)
+	// GetSignerSigAlgo returns the signature algorithm of `publicKey`, `ok` is false if it is not a signer of the vault
+	GetSignerSigAlgo(vault flow.Address, publicKey string) (sigAlgo crypto.SignatureAlgorithm, ok bool, err error)
+	GetPendingPayloads(vault flow.Address) ([]PendingPayload, error)
+	AddNewPayload(vault flow.Address, txIndex uint64, method string, args []cadence.Value, publicKey string, sig string) error
+	AddPayloadSignature(vault flow.Address, txIndex uint64, publicKey string, sig string) error
+}
+
+// PendingPayload is the part of `OnChainMultiSig.PayloadInfo` the coordinator reconciles its proposals with
+type PendingPayload struct {
+	TxIndex uint64
+	Method  string
+	// Signers are the keys whose signatures count towards the approval weight of the payload
+	Signers []string
+}
+
+// FlowChain submits payloads with the transaction templates of this repository,
+// the transactions are paid for by `payerAcct`, which relays the signatures of the signers
+type FlowChain struct {
+	g         *gwtf.GoWithTheFlow
+	payerAcct string
+	root      string
+	// TxTimeout is the time a submission waits for its transaction to be sealed before it fails
+	TxTimeout time.Duration
+}
+
+// NewFlowChain returns a Chain for the network of `g`,
+// `root` is the path to the root of this repository, where the scripts and transactions are
+func NewFlowChain(g *gwtf.GoWithTheFlow, payerAcct string, root string) *FlowChain {
+	return &FlowChain{g: g, payerAcct: payerAcct, root: root, TxTimeout: util.DefaultTxTimeout}
+}
+
+func (c *FlowChain) GetTxIndex(vault flow.Address) (result uint64, err error) {
+	value, err := c.script("get_store_tx_index.cdc", vault).RunReturns()
+	if err != nil {
+		return
+	}
+	result = value.ToGoValue().(uint64)
+	return
+}
+
+func (c *FlowChain) GetSignableDomain(vault flow.Address) (result []byte, err error) {
+	value, err := c.script("calc_signable_domain.cdc", vault).RunReturns()
+	if err != nil {
+		return
+	}
+	result = util.ConvertCadenceByteArray(value)
+	return
+}
+
+func (c *FlowChain) GetSignerSigAlgo(vault flow.Address, publicKey string) (sigAlgo crypto.SignatureAlgorithm, ok bool, err error) {
+	value, err := c.script("get_key_sig_algo.cdc", vault).
+		StringArgument(publicKey).
+		RunReturns()
+	if err != nil {
+		return
+	}
+	optional, isOptional := value.(cadence.Optional)
+	if !isOptional {
+		err = errors.New("returned not optional")
+		return
+	}
+	if optional.Value == nil {
+		return
+	}
+	sigAlgo, ok = sigAlgoFromRawValue(optional.Value.ToGoValue().(uint8))
+	return
+}
+
+func (c *FlowChain) GetPendingPayloads(vault flow.Address) ([]PendingPayload, error) {
+	value, err := c.script("get_pending_payloads.cdc", vault).RunReturns()
+	if err != nil {
+		return nil, err
+	}
+	payloads := []PendingPayload{}
+	for _, v := range value.(cadence.Array).Values {
+		s := v.(cadence.Struct)
+		fields := map[string]cadence.Value{}
+		for i, f := range s.StructType.Fields {
+			fields[f.Identifier] = s.Fields[i]
+		}
+		p := PendingPayload{
+			TxIndex: uint64(fields["txIndex"].(cadence.UInt64)),
+			Method:  string(fields["method"].(cadence.String)),
+			Signers: []string{},
+		}
+		for _, signer := range fields["signers"].(cadence.Array).Values {
+			p.Signers = append(p.Signers, string(signer.(cadence.String)))
+		}
+		payloads = append(payloads, p)
+	}
+	return payloads, nil
+}
+
+// AddNewPayload adds the payload with `publicKey` and `sig`. No tokens are withdrawn from the payer account
+// for the payload, so payloads of methods that are not Relayable cannot be added
+func (c *FlowChain) AddNewPayload(vault flow.Address, txIndex uint64, method string, args []cadence.Value, publicKey string, sig string) error {
+	return c.transaction("add_new_payload.cdc",
+		cadence.String(sig),
+		cadence.UInt64(txIndex),
+		cadence.String(method),
+		cadence.NewArray(args),
+		cadence.String(publicKey),
+		cadenceAddress(vault),
+		util.DefaultVaultPaths.Signer,
+		cadence.UFix64(0))
+}
+
+// Relayable returns false for the methods whose payloads hold tokens withdrawn from the account adding them,
+// `deposit`. A payer adding them for their proposer would pay the tokens, so they must be added by the proposer
+func Relayable(method string) bool {
+	return method != "deposit"
+}
+
+func (c *FlowChain) AddPayloadSignature(vault flow.Address, txIndex uint64, publicKey string, sig string) error {
+	return c.transaction("add_payload_signature.cdc",
+		cadence.String(sig),
+		cadence.UInt64(txIndex),
+		cadence.String(publicKey),
+		cadenceAddress(vault),
+		util.DefaultVaultPaths.Signer)
+}
+
+// transaction sends the transaction `name` with `args`, paid for by the payer,
+// and fails if it is not sealed within the TxTimeout
+func (c *FlowChain) transaction(name string, args ...cadence.Value) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.TxTimeout)
+	defer cancel()
+	filename := filepath.Join(c.root, "transactions", name)
+	_, err := util.SendTransactionWithContext(ctx, c.g, util.AccountRoles(c.payerAcct), filename, util.ParseCadenceTemplate(filename), args...)
+	return err
+}
+
+// script returns the script `name` with the arguments of the vault at the default paths of `vault`
+func (c *FlowChain) script(name string, vault flow.Address) gwtf.FlowScriptBuilder {
+	filename := filepath.Join(c.root, "scripts", name)
+	return c.g.ScriptFromFile(filename, util.ParseCadenceTemplate(filename)).
+		Argument(cadenceAddress(vault)).
+		Argument(util.DefaultVaultPaths.Signer)
+}
+
+func cadenceAddress(address flow.Address) cadence.Address {
+	return cadence.BytesToAddress(address.Bytes())
+}
+
+// sigAlgoFromRawValue maps the raw values of the Cadence `SignatureAlgorithm` enum
+func sigAlgoFromRawValue(rawValue uint8) (crypto.SignatureAlgorithm, bool) {
+	switch rawValue {
+	case 1:
+		return crypto.ECDSA_P256, true
+	case 2:
+		return crypto.ECDSA_secp256k1, true
+	}
+	return crypto.UnknownSignatureAlgorithm, false
+}
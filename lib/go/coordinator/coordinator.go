@@ -0,0 +1,502 @@
+// Package coordinator collects the signatures of multisig key holders off chain
+// and relays them to the multisig vaults.
+//
+// A proposal is a payload at the next free txIndex of a vault, signed by its proposer.
+// Other key holders fetch its signable data, sign it wherever their keys are and post the detached signatures back.
+// The coordinator verifies the signatures, including that of the proposer, against the key list of the vault
+// before storing them, then submits `add_new_payload.cdc` and `add_payload_signature.cdc` with its own payer account,
+// so key holders need neither chain access nor an account with a balance.
+// Payloads holding tokens of the account adding them, deposits, are not relayed, see Relayable.
+// Proposals that are not submitted within the ProposalTTL of the coordinator expire and free their txIndex.
+package coordinator
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	util "github.com/flow-hydraulics/onchain-multisig"
+	"github.com/flow-hydraulics/onchain-multisig/encoder"
+	"github.com/onflow/cadence"
+	jsoncdc "github.com/onflow/cadence/encoding/json"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/crypto"
+)
+
+type Status string
+
+const (
+	// StatusPending proposals are collecting signatures and are not on chain yet
+	StatusPending Status = "pending"
+	// StatusSubmitted proposals have been added to the vault, later signatures are added to the payload
+	StatusSubmitted Status = "submitted"
+	// StatusConflict proposals lost their txIndex to a payload added to the vault by someone else
+	StatusConflict Status = "conflict"
+	// StatusExpired proposals were not submitted within the ProposalTTL of the coordinator,
+	// or are after an expired proposal so that their txIndex cannot be reached
+	StatusExpired Status = "expired"
+)
+
+// DefaultProposalTTL is the time a proposal can stay pending before it expires
+const DefaultProposalTTL = 24 * time.Hour
+
+var ErrBadRequest = errors.New("bad request")
+
+type Signature struct {
+	PublicKey string `json:"publicKey"`
+	Signature string `json:"signature"`
+	Submitted bool   `json:"submitted"`
+}
+
+type Proposal struct {
+	ID      string `json:"id"`
+	Vault   string `json:"vault"`
+	TxIndex uint64 `json:"txIndex"`
+	// Generation counts the proposals for the txIndex before this one, which conflicted or expired
+	Generation uint64 `json:"generation"`
+	Method     string `json:"method"`
+	// Args are JSON-Cadence encoded
+	Args []json.RawMessage `json:"args"`
+	// Signable is the hex encoded signable data signers sign
+	Signable   string      `json:"signable"`
+	Status     Status      `json:"status"`
+	Signatures []Signature `json:"signatures"`
+	// Error is the error of the last failed submission of the proposal
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// closed returns true if the proposal can no longer be submitted and does not hold its txIndex
+func (p *Proposal) closed() bool {
+	return p.Status == StatusConflict || p.Status == StatusExpired
+}
+
+func (p *Proposal) hasSigner(publicKey string) bool {
+	for _, s := range p.Signatures {
+		if s.PublicKey == publicKey {
+			return true
+		}
+	}
+	return false
+}
+
+// addedAs returns true if `payload` on chain is the proposal, a payload of its method added with the signature of its proposer,
+// and marks the signatures of the proposal among its signers as submitted
+func (p *Proposal) addedAs(payload PendingPayload) bool {
+	if payload.Method != p.Method || len(p.Signatures) == 0 {
+		return false
+	}
+	signers := map[string]bool{}
+	for _, publicKey := range payload.Signers {
+		signers[publicKey] = true
+	}
+	if !signers[p.Signatures[0].PublicKey] {
+		return false
+	}
+	for i, s := range p.Signatures {
+		if signers[s.PublicKey] {
+			p.Signatures[i].Submitted = true
+		}
+	}
+	return true
+}
+
+func (p *Proposal) cadenceArgs() ([]cadence.Value, error) {
+	args := make([]cadence.Value, len(p.Args))
+	for i, arg := range p.Args {
+		v, err := jsoncdc.Decode(arg)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	return args, nil
+}
+
+// Coordinator keeps the proposals in the store in step with the vaults on chain
+type Coordinator struct {
+	// mu serialises the assignment of txIndexes and the submissions to the chain
+	mu    sync.Mutex
+	store *Store
+	chain Chain
+	// ProposalTTL is the time a proposal can stay pending before it expires, it never expires if it is 0
+	ProposalTTL time.Duration
+}
+
+func NewCoordinator(store *Store, chain Chain) *Coordinator {
+	return &Coordinator{store: store, chain: chain, ProposalTTL: DefaultProposalTTL}
+}
+
+// NextTxIndex returns the next txIndex of `vault` that is neither on chain nor taken by another proposal,
+// the txIndex of the next proposal
+func (c *Coordinator) NextTxIndex(vault flow.Address) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.nextTxIndex(vault)
+}
+
+func (c *Coordinator) nextTxIndex(vault flow.Address) (uint64, error) {
+	txIndex, err := c.chain.GetTxIndex(vault)
+	if err != nil {
+		return 0, err
+	}
+	proposals, err := c.store.List(vault)
+	if err != nil {
+		return 0, err
+	}
+	if err := c.updateStatuses(vault, txIndex, proposals); err != nil {
+		return 0, err
+	}
+	for _, p := range proposals {
+		if !p.closed() && p.TxIndex > txIndex {
+			txIndex = p.TxIndex
+		}
+	}
+	return txIndex + 1, nil
+}
+
+// updateStatuses marks the pending `proposals` of `vault` whose txIndex is on chain as submitted
+// if the pending payload at their txIndex was added for them, by a coordinator that stopped before recording it,
+// and as conflicts otherwise. Those pending for longer than the ProposalTTL are marked as expired,
+// with the pending proposals after them
+func (c *Coordinator) updateStatuses(vault flow.Address, txIndex uint64, proposals []*Proposal) error {
+	now := time.Now().UTC()
+	expired := false
+	var onChain map[uint64]PendingPayload
+	for _, p := range proposals {
+		if p.Status != StatusPending {
+			continue
+		}
+		switch {
+		case p.TxIndex <= txIndex:
+			if onChain == nil {
+				var err error
+				if onChain, err = c.pendingPayloads(vault); err != nil {
+					return err
+				}
+			}
+			if payload, ok := onChain[p.TxIndex]; ok && p.addedAs(payload) {
+				p.Status = StatusSubmitted
+			} else {
+				p.Status = StatusConflict
+			}
+		case expired || (c.ProposalTTL > 0 && now.Sub(p.CreatedAt) > c.ProposalTTL):
+			p.Status = StatusExpired
+			expired = true
+		default:
+			continue
+		}
+		if err := c.store.Put(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Coordinator) pendingPayloads(vault flow.Address) (map[uint64]PendingPayload, error) {
+	payloads, err := c.chain.GetPendingPayloads(vault)
+	if err != nil {
+		return nil, err
+	}
+	byTxIndex := map[uint64]PendingPayload{}
+	for _, payload := range payloads {
+		byTxIndex[payload.TxIndex] = payload
+	}
+	return byTxIndex, nil
+}
+
+// Reconcile records the submissions the coordinator stopped before recording, so that they are not submitted again
+// after a restart: pending proposals whose payloads are on chain are marked as submitted,
+// and so are the signatures of submitted proposals that were added to their payloads
+func (c *Coordinator) Reconcile() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	proposals, err := c.store.List(flow.EmptyAddress)
+	if err != nil {
+		return err
+	}
+	for _, vault := range openVaults(proposals) {
+		txIndex, err := c.chain.GetTxIndex(vault)
+		if err != nil {
+			return err
+		}
+		proposals, err := c.store.List(vault)
+		if err != nil {
+			return err
+		}
+		if err := c.updateStatuses(vault, txIndex, proposals); err != nil {
+			return err
+		}
+		onChain, err := c.pendingPayloads(vault)
+		if err != nil {
+			return err
+		}
+		for _, p := range proposals {
+			payload, ok := onChain[p.TxIndex]
+			if p.Status != StatusSubmitted || !ok || !p.addedAs(payload) {
+				continue
+			}
+			if err := c.store.Put(p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Propose stores a proposal for `method` with `args` at `txIndex` of `vault`, which must be its NextTxIndex,
+// signed by `publicKey` with `sig`. The signature of the proposer is verified as other signatures are,
+// and the proposal is submitted as soon as the txIndex before it is on chain
+func (c *Coordinator) Propose(vault flow.Address, txIndex uint64, method string, args []cadence.Value, publicKey string, sig string) (*Proposal, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if method == "" {
+		return nil, fmt.Errorf("%w: method is required", ErrBadRequest)
+	}
+	if !Relayable(method) {
+		return nil, fmt.Errorf("%w: %s payloads hold tokens of the account adding them and must be added by their proposer", ErrBadRequest, method)
+	}
+	next, err := c.nextTxIndex(vault)
+	if err != nil {
+		return nil, err
+	}
+	if txIndex != next {
+		return nil, fmt.Errorf("%w: the next txIndex of vault %s is %d", ErrBadRequest, vault.Hex(), next)
+	}
+
+	domain, err := c.chain.GetSignableDomain(vault)
+	if err != nil {
+		return nil, err
+	}
+	signable, err := encoder.Encode(domain, txIndex, method, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrBadRequest, err)
+	}
+	encodedArgs := make([]json.RawMessage, len(args))
+	for i, arg := range args {
+		encodedArgs[i], err = jsoncdc.Encode(arg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	generation, err := c.store.NextGeneration(vault, txIndex)
+	if err != nil {
+		return nil, err
+	}
+	p := &Proposal{
+		ID:         ProposalID(vault, txIndex, generation),
+		Vault:      vault.Hex(),
+		TxIndex:    txIndex,
+		Generation: generation,
+		Method:     method,
+		Args:       encodedArgs,
+		Signable:   hex.EncodeToString(signable),
+		Status:     StatusPending,
+		Signatures: []Signature{},
+		CreatedAt:  time.Now().UTC(),
+	}
+	publicKey = strings.TrimPrefix(publicKey, "0x")
+	if err := c.verify(vault, p, publicKey, sig); err != nil {
+		return nil, err
+	}
+	p.Signatures = append(p.Signatures, Signature{PublicKey: publicKey, Signature: sig})
+	if err := c.store.Put(p); err != nil {
+		return nil, err
+	}
+	_ = c.flush(vault)
+	return c.store.Get(p.ID)
+}
+
+// Get returns the proposal with `id`, or ErrNotFound
+func (c *Coordinator) Get(id string) (*Proposal, error) {
+	return c.store.Get(id)
+}
+
+// List returns the proposals of `vault`, or of all vaults for the empty address,
+// with `status` if it is not empty
+func (c *Coordinator) List(vault flow.Address, status Status) ([]*Proposal, error) {
+	proposals, err := c.store.List(vault)
+	if err != nil || status == "" {
+		return proposals, err
+	}
+	filtered := []*Proposal{}
+	for _, p := range proposals {
+		if p.Status == status {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered, nil
+}
+
+// AddSignature verifies and stores the signature of `publicKey` on the proposal with `id`,
+// then submits whatever the vault of the proposal is ready for.
+// Errors submitting are recorded on the proposals and retried by the next flush
+func (c *Coordinator) AddSignature(id string, publicKey string, sig string) (*Proposal, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p, err := c.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if p.Status == StatusConflict {
+		return nil, fmt.Errorf("%w: proposal %s conflicts with a payload on chain", ErrBadRequest, id)
+	}
+	if p.Status == StatusExpired {
+		return nil, fmt.Errorf("%w: proposal %s has expired", ErrBadRequest, id)
+	}
+	publicKey = strings.TrimPrefix(publicKey, "0x")
+	if p.hasSigner(publicKey) {
+		return nil, fmt.Errorf("%w: proposal %s is already signed by %s", ErrBadRequest, id, publicKey)
+	}
+	vault := flow.HexToAddress(p.Vault)
+	if err := c.verify(vault, p, publicKey, sig); err != nil {
+		return nil, err
+	}
+
+	p.Signatures = append(p.Signatures, Signature{PublicKey: publicKey, Signature: sig})
+	if err := c.store.Put(p); err != nil {
+		return nil, err
+	}
+	_ = c.flush(vault)
+	return c.store.Get(id)
+}
+
+func (c *Coordinator) verify(vault flow.Address, p *Proposal, publicKey string, sig string) error {
+	sigAlgo, ok, err := c.chain.GetSignerSigAlgo(vault, publicKey)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s is not a signer of vault %s", ErrBadRequest, publicKey, p.Vault)
+	}
+	pk, err := crypto.DecodePublicKeyHex(sigAlgo, publicKey)
+	if err != nil {
+		return fmt.Errorf("%w: invalid public key: %s", ErrBadRequest, err)
+	}
+	sigBytes, err := hex.DecodeString(sig)
+	if err != nil {
+		return fmt.Errorf("%w: signature must be hex encoded", ErrBadRequest)
+	}
+	signable, err := hex.DecodeString(p.Signable)
+	if err != nil {
+		return err
+	}
+	valid, err := util.VerifyPayloadSignature(pk, sigBytes, signable)
+	if err != nil || !valid {
+		return fmt.Errorf("%w: invalid signature for proposal %s", ErrBadRequest, p.ID)
+	}
+	return nil
+}
+
+// Flush submits the signatures of all vaults with open proposals that have not been submitted yet
+func (c *Coordinator) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	proposals, err := c.store.List(flow.EmptyAddress)
+	if err != nil {
+		return err
+	}
+	for _, vault := range openVaults(proposals) {
+		if flushErr := c.flush(vault); flushErr != nil && err == nil {
+			err = flushErr
+		}
+	}
+	return err
+}
+
+// openVaults returns the vaults of the `proposals` that are not closed
+func openVaults(proposals []*Proposal) []flow.Address {
+	vaults := []flow.Address{}
+	seen := map[flow.Address]bool{}
+	for _, p := range proposals {
+		vault := flow.HexToAddress(p.Vault)
+		if !p.closed() && !seen[vault] {
+			seen[vault] = true
+			vaults = append(vaults, vault)
+		}
+	}
+	return vaults
+}
+
+// flush submits the proposals of `vault` in txIndex order.
+// A pending proposal is added as a new payload with its first signature once it is at the next txIndex of the vault,
+// the other signatures of submitted proposals are added to their payloads.
+// Proposals without a signature are skipped, they hold their txIndex until they expire
+func (c *Coordinator) flush(vault flow.Address) error {
+	txIndex, err := c.chain.GetTxIndex(vault)
+	if err != nil {
+		return err
+	}
+	proposals, err := c.store.List(vault)
+	if err != nil {
+		return err
+	}
+
+	var submitErr error
+	fail := func(p *Proposal, err error) error {
+		p.Error = err.Error()
+		if submitErr == nil {
+			submitErr = err
+		}
+		return c.store.Put(p)
+	}
+
+	if err := c.updateStatuses(vault, txIndex, proposals); err != nil {
+		return err
+	}
+	for _, p := range proposals {
+		if p.Status == StatusPending {
+			if p.TxIndex != txIndex+1 || len(p.Signatures) == 0 {
+				// later proposals wait for the one at the next txIndex to be submitted or to expire
+				continue
+			}
+			args, err := p.cadenceArgs()
+			if err != nil {
+				return err
+			}
+			s := p.Signatures[0]
+			if err := c.chain.AddNewPayload(vault, p.TxIndex, p.Method, args, s.PublicKey, s.Signature); err != nil {
+				if err := fail(p, err); err != nil {
+					return err
+				}
+				continue
+			}
+			txIndex = p.TxIndex
+			p.Status = StatusSubmitted
+			p.Signatures[0].Submitted = true
+			p.Error = ""
+			if err := c.store.Put(p); err != nil {
+				return err
+			}
+		}
+		if p.Status != StatusSubmitted {
+			continue
+		}
+		for i, s := range p.Signatures {
+			if s.Submitted {
+				continue
+			}
+			if err := c.chain.AddPayloadSignature(vault, p.TxIndex, s.PublicKey, s.Signature); err != nil {
+				if err := fail(p, err); err != nil {
+					return err
+				}
+				break
+			}
+			p.Signatures[i].Submitted = true
+			p.Error = ""
+			if err := c.store.Put(p); err != nil {
+				return err
+			}
+		}
+	}
+	return submitErr
+}
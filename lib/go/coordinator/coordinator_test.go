@@ -0,0 +1,401 @@
+package coordinator
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bjartek/go-with-the-flow/gwtf"
+	util "github.com/flow-hydraulics/onchain-multisig"
+	"github.com/flow-hydraulics/onchain-multisig/signer"
+	"github.com/flow-hydraulics/onchain-multisig/vault"
+	"github.com/onflow/cadence"
+	jsoncdc "github.com/onflow/cadence/encoding/json"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+const vaultAcct = "vaulted-account"
+const payerAcct = "owner"
+
+func newTestServer(t *testing.T, g *gwtf.GoWithTheFlow, dbPath string) (*Store, *Coordinator, *httptest.Server) {
+	store, err := OpenStore(dbPath)
+	assert.NoError(t, err)
+	c := NewCoordinator(store, NewFlowChain(g, payerAcct, "../../.."))
+	return store, c, httptest.NewServer(NewHandler(c))
+}
+
+func doJSON(t *testing.T, method string, url string, body interface{}, result interface{}) int {
+	b, err := json.Marshal(body)
+	assert.NoError(t, err)
+	req, err := http.NewRequest(method, url, bytes.NewReader(b))
+	assert.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 && result != nil {
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(result))
+	} else if resp.StatusCode > 299 {
+		var msg bytes.Buffer
+		_, _ = msg.ReadFrom(resp.Body)
+		t.Log(msg.String())
+	}
+	return resp.StatusCode
+}
+
+// transferRequest returns the request of `proposer` for a transfer at the next txIndex of the vault on `server`
+func transferRequest(t *testing.T, g *gwtf.GoWithTheFlow, server *httptest.Server, amount string, to string, proposer signer.Signer) ProposeRequest {
+	var next NextTxIndexResponse
+	status := doJSON(t, http.MethodGet, server.URL+ProposalsEndpoint+"/next?vault="+util.GetAccountAddr(g, vaultAcct), nil, &next)
+	assert.Equal(t, http.StatusOK, status)
+
+	ufix64, err := cadence.NewUFix64(amount)
+	assert.NoError(t, err)
+	toAddr := cadence.BytesToAddress(g.Accounts[to].Address.Bytes())
+	args := []json.RawMessage{}
+	for _, arg := range []cadence.Value{ufix64, toAddr} {
+		b, err := jsoncdc.Encode(arg)
+		assert.NoError(t, err)
+		args = append(args, b)
+	}
+	signable, err := util.GetSignableDataFromScript(g, vaultAcct, next.TxIndex, "transfer", ufix64, toAddr)
+	assert.NoError(t, err)
+	sig := signProposal(t, proposer, Proposal{Signable: hex.EncodeToString(signable)})
+	return ProposeRequest{
+		Vault:     util.GetAccountAddr(g, vaultAcct),
+		TxIndex:   next.TxIndex,
+		Method:    "transfer",
+		Args:      args,
+		PublicKey: sig.PublicKey,
+		Signature: sig.Signature,
+	}
+}
+
+func signProposal(t *testing.T, s signer.Signer, p Proposal) SignatureRequest {
+	signable, err := hex.DecodeString(p.Signable)
+	assert.NoError(t, err)
+	sig, err := util.SignPayload(s, signable)
+	assert.NoError(t, err)
+	return SignatureRequest{PublicKey: signer.PublicKeyHex(s), Signature: sig}
+}
+
+func TestProposalSignedThroughCoordinatorIsExecuted(t *testing.T) {
+	g := emu.GoWithTheFlow()
+	dbPath := filepath.Join(t.TempDir(), "coordinator.db")
+	store, _, server := newTestServer(t, g, dbPath)
+
+	txIndex, err := util.GetTxIndex(g, vaultAcct)
+	assert.NoError(t, err)
+	initFromBalance, err := util.GetBalance(g, vaultAcct)
+	assert.NoError(t, err)
+
+	// The proposal is submitted with the signature of its proposer
+	var p Proposal
+	status := doJSON(t, http.MethodPost, server.URL+ProposalsEndpoint, transferRequest(t, g, server, "1.0", payerAcct, util.GetSigner(g, vault.Acct500_2)), &p)
+	assert.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, txIndex+1, p.TxIndex)
+	assert.Equal(t, StatusSubmitted, p.Status)
+	assert.Empty(t, p.Error)
+
+	// The signable data is what the signers would sign with the contract
+	ufix64, _ := cadence.NewUFix64("1.0")
+	signable, err := util.GetSignableDataFromScript(g, vaultAcct, p.TxIndex, "transfer", ufix64, cadence.BytesToAddress(g.Accounts[payerAcct].Address.Bytes()))
+	assert.NoError(t, err)
+	assert.Equal(t, hex.EncodeToString(signable), p.Signable)
+
+	var submitted []Proposal
+	status = doJSON(t, http.MethodGet, server.URL+ProposalsEndpoint+"?status=submitted&vault="+p.Vault, nil, &submitted)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, submitted, 1)
+
+	chainTxIndex, err := util.GetTxIndex(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, p.TxIndex, chainTxIndex)
+
+	// The proposals survive a restart of the coordinator
+	server.Close()
+	assert.NoError(t, store.Close())
+	store, _, server = newTestServer(t, g, dbPath)
+	defer store.Close()
+	defer server.Close()
+
+	status = doJSON(t, http.MethodGet, server.URL+ProposalsEndpoint+"/"+p.ID, nil, &p)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, p.Signatures, 1)
+
+	status = doJSON(t, http.MethodPost, server.URL+ProposalsEndpoint+"/"+p.ID+"/signatures", signProposal(t, util.GetSigner(g, vault.Acct1000), p), &p)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, p.Signatures, 2)
+	assert.True(t, p.Signatures[1].Submitted)
+	assert.Empty(t, p.Error)
+
+	_, err = vault.MultiSig_VaultExecuteTx(g, p.TxIndex, payerAcct, vaultAcct)
+	assert.NoError(t, err)
+
+	postFromBalance, err := util.GetBalance(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.00000000", (initFromBalance - postFromBalance).String())
+}
+
+func TestInvalidSignaturesAreRejected(t *testing.T) {
+	g := emu.GoWithTheFlow()
+	store, _, server := newTestServer(t, g, filepath.Join(t.TempDir(), "coordinator.db"))
+	defer store.Close()
+	defer server.Close()
+
+	// A key that is not in the key list of the vault
+	seed := make([]byte, crypto.MinSeedLength)
+	_, err := rand.Read(seed)
+	assert.NoError(t, err)
+	unregistered, err := crypto.GeneratePrivateKey(crypto.ECDSA_P256, seed)
+	assert.NoError(t, err)
+	unregisteredSigner := signer.NewInMemorySigner(unregistered, crypto.SHA3_256)
+
+	// Proposers are authenticated as the other signers are
+	req := transferRequest(t, g, server, "1.0", payerAcct, unregisteredSigner)
+	status := doJSON(t, http.MethodPost, server.URL+ProposalsEndpoint, req, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+	req = transferRequest(t, g, server, "1.0", payerAcct, util.GetSigner(g, vault.Acct500_1))
+	req.Args = req.Args[:1]
+	status = doJSON(t, http.MethodPost, server.URL+ProposalsEndpoint, req, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+	req = transferRequest(t, g, server, "1.0", payerAcct, util.GetSigner(g, vault.Acct500_1))
+	req.TxIndex++
+	status = doJSON(t, http.MethodPost, server.URL+ProposalsEndpoint, req, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	var p Proposal
+	status = doJSON(t, http.MethodPost, server.URL+ProposalsEndpoint, transferRequest(t, g, server, "1.0", payerAcct, util.GetSigner(g, vault.Acct500_1)), &p)
+	assert.Equal(t, http.StatusCreated, status)
+	signaturesURL := server.URL + ProposalsEndpoint + "/" + p.ID + "/signatures"
+
+	status = doJSON(t, http.MethodPost, signaturesURL, signProposal(t, unregisteredSigner, p), nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// A signer of the vault signing other data
+	other := p
+	other.Signable = hex.EncodeToString([]byte("other data"))
+	status = doJSON(t, http.MethodPost, signaturesURL, signProposal(t, util.GetSigner(g, vault.Acct1000), other), nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status = doJSON(t, http.MethodGet, server.URL+ProposalsEndpoint+"/"+p.ID, nil, &p)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, p.Signatures, 1)
+	assert.Equal(t, StatusSubmitted, p.Status)
+
+	status = doJSON(t, http.MethodGet, server.URL+ProposalsEndpoint+"/"+ProposalID(flow.HexToAddress(p.Vault), p.TxIndex+100, 0), nil, nil)
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestProposalConflictsWithPayloadAddedOnChain(t *testing.T) {
+	g := emu.GoWithTheFlow()
+	store, c, server := newTestServer(t, g, filepath.Join(t.TempDir(), "coordinator.db"))
+	defer store.Close()
+	defer server.Close()
+
+	// A proposal that could not be submitted yet, as one stored before proposers signed their proposals
+	txIndex, err := c.NextTxIndex(flow.HexToAddress(util.GetAccountAddr(g, vaultAcct)))
+	assert.NoError(t, err)
+	p := Proposal{
+		ID:         ProposalID(flow.HexToAddress(util.GetAccountAddr(g, vaultAcct)), txIndex, 0),
+		Vault:      flow.HexToAddress(util.GetAccountAddr(g, vaultAcct)).Hex(),
+		TxIndex:    txIndex,
+		Method:     "transfer",
+		Status:     StatusPending,
+		Signatures: []Signature{},
+		CreatedAt:  time.Now().UTC(),
+	}
+	assert.NoError(t, store.Put(&p))
+
+	// Someone adds another payload at the txIndex of the proposal without the coordinator
+	_, err = vault.MultiSig_Transfer(g, "2.0", payerAcct, p.TxIndex, vault.Acct1000, vaultAcct, true)
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.Flush())
+	status := doJSON(t, http.MethodGet, server.URL+ProposalsEndpoint+"/"+p.ID, nil, &p)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, StatusConflict, p.Status)
+
+	status = doJSON(t, http.MethodPost, server.URL+ProposalsEndpoint+"/"+p.ID+"/signatures", signProposal(t, util.GetSigner(g, vault.Acct1000), p), nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// The next proposal is after the payload on chain
+	var next Proposal
+	status = doJSON(t, http.MethodPost, server.URL+ProposalsEndpoint, transferRequest(t, g, server, "1.0", payerAcct, util.GetSigner(g, vault.Acct1000)), &next)
+	assert.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, p.TxIndex+1, next.TxIndex)
+
+	_, err = vault.MultiSig_VaultExecuteTx(g, p.TxIndex, payerAcct, vaultAcct)
+	assert.NoError(t, err)
+}
+
+func TestStaleProposalsExpire(t *testing.T) {
+	g := emu.GoWithTheFlow()
+	store, c, server := newTestServer(t, g, filepath.Join(t.TempDir(), "coordinator.db"))
+	defer store.Close()
+	defer server.Close()
+	vaultAddr := flow.HexToAddress(util.GetAccountAddr(g, vaultAcct))
+
+	// Unsigned proposals at the next txIndexes, as stored before proposers signed their proposals
+	txIndex, err := c.NextTxIndex(vaultAddr)
+	assert.NoError(t, err)
+	for i := uint64(0); i < 2; i++ {
+		assert.NoError(t, store.Put(&Proposal{
+			ID:         ProposalID(vaultAddr, txIndex+i, 0),
+			Vault:      vaultAddr.Hex(),
+			TxIndex:    txIndex + i,
+			Method:     "transfer",
+			Status:     StatusPending,
+			Signatures: []Signature{},
+			CreatedAt:  time.Now().UTC().Add(-time.Duration(2-i) * time.Hour),
+		}))
+	}
+
+	// They are skipped by flush and hold their txIndexes until they expire
+	assert.NoError(t, c.Flush())
+	next, err := c.NextTxIndex(vaultAddr)
+	assert.NoError(t, err)
+	assert.Equal(t, txIndex+2, next)
+
+	// The first expires, and the second with it as its txIndex can no longer be reached
+	c.ProposalTTL = 90 * time.Minute
+	next, err = c.NextTxIndex(vaultAddr)
+	assert.NoError(t, err)
+	assert.Equal(t, txIndex, next)
+	expired, err := c.List(vaultAddr, StatusExpired)
+	assert.NoError(t, err)
+	assert.Len(t, expired, 2)
+
+	var p Proposal
+	status := doJSON(t, http.MethodPost, server.URL+ProposalsEndpoint+"/"+ProposalID(vaultAddr, txIndex, 0)+"/signatures", signProposal(t, util.GetSigner(g, vault.Acct1000), *expired[0]), nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// Their txIndexes are proposed again
+	status = doJSON(t, http.MethodPost, server.URL+ProposalsEndpoint, transferRequest(t, g, server, "1.0", payerAcct, util.GetSigner(g, vault.Acct1000)), &p)
+	assert.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, txIndex, p.TxIndex)
+	assert.Equal(t, uint64(1), p.Generation)
+	assert.Equal(t, StatusSubmitted, p.Status)
+
+	// The expired proposal is kept alongside the new one
+	first, err := c.Get(ProposalID(vaultAddr, txIndex, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, StatusExpired, first.Status)
+
+	_, err = vault.MultiSig_VaultExecuteTx(g, p.TxIndex, payerAcct, vaultAcct)
+	assert.NoError(t, err)
+}
+
+func TestReconcileRecordsSubmissionsOfStoppedCoordinator(t *testing.T) {
+	g := emu.GoWithTheFlow()
+	dbPath := filepath.Join(t.TempDir(), "coordinator.db")
+	store, c, server := newTestServer(t, g, dbPath)
+	vaultAddr := flow.HexToAddress(util.GetAccountAddr(g, vaultAcct))
+
+	req := transferRequest(t, g, server, "1.0", payerAcct, util.GetSigner(g, vault.Acct500_1))
+	server.Close()
+	args := []cadence.Value{}
+	for _, arg := range req.Args {
+		v, err := jsoncdc.Decode(arg)
+		assert.NoError(t, err)
+		args = append(args, v)
+	}
+	signable, err := util.GetSignableDataFromScript(g, vaultAcct, req.TxIndex, req.Method, args...)
+	assert.NoError(t, err)
+	p := Proposal{
+		ID:         ProposalID(vaultAddr, req.TxIndex, 0),
+		Vault:      vaultAddr.Hex(),
+		TxIndex:    req.TxIndex,
+		Method:     req.Method,
+		Args:       req.Args,
+		Signable:   hex.EncodeToString(signable),
+		Status:     StatusPending,
+		Signatures: []Signature{{PublicKey: req.PublicKey, Signature: req.Signature}},
+		CreatedAt:  time.Now().UTC(),
+	}
+	second := signProposal(t, util.GetSigner(g, vault.Acct500_2), p)
+	p.Signatures = append(p.Signatures, Signature{PublicKey: second.PublicKey, Signature: second.Signature})
+	assert.NoError(t, store.Put(&p))
+
+	// The coordinator stops after adding the payload and the second signature, before recording them
+	chain := NewFlowChain(g, payerAcct, "../../..")
+	assert.NoError(t, chain.AddNewPayload(vaultAddr, p.TxIndex, p.Method, args, req.PublicKey, req.Signature))
+	assert.NoError(t, chain.AddPayloadSignature(vaultAddr, p.TxIndex, second.PublicKey, second.Signature))
+	assert.NoError(t, store.Close())
+
+	store, c, server = newTestServer(t, g, dbPath)
+	defer store.Close()
+	defer server.Close()
+	assert.NoError(t, c.Reconcile())
+
+	got, err := c.Get(p.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusSubmitted, got.Status)
+	assert.True(t, got.Signatures[0].Submitted)
+	assert.True(t, got.Signatures[1].Submitted)
+
+	// Nothing is submitted again
+	assert.NoError(t, c.Flush())
+	got, err = c.Get(p.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, got.Error)
+
+	_, err = vault.MultiSig_VaultExecuteTx(g, p.TxIndex, payerAcct, vaultAcct)
+	assert.NoError(t, err)
+}
+
+func TestSubmissionsFailWhenNotSealedInTime(t *testing.T) {
+	g := emu.GoWithTheFlow()
+	chain := NewFlowChain(g, payerAcct, "../../..")
+	chain.TxTimeout = time.Nanosecond
+	vaultAddr := flow.HexToAddress(util.GetAccountAddr(g, vaultAcct))
+
+	txIndex, err := chain.GetTxIndex(vaultAddr)
+	assert.NoError(t, err)
+	err = chain.AddPayloadSignature(vaultAddr, txIndex, signer.PublicKeyHex(util.GetSigner(g, vault.Acct1000)), "00")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestDepositProposalsAreRejected(t *testing.T) {
+	g := emu.GoWithTheFlow()
+	store, c, server := newTestServer(t, g, filepath.Join(t.TempDir(), "coordinator.db"))
+	defer store.Close()
+	defer server.Close()
+	vaultAddr := flow.HexToAddress(util.GetAccountAddr(g, vaultAcct))
+
+	payerBalance, err := util.GetBalance(g, payerAcct)
+	assert.NoError(t, err)
+	chainTxIndex, err := util.GetTxIndex(g, vaultAcct)
+	assert.NoError(t, err)
+
+	// The tokens of a deposit would be withdrawn from the payer of the coordinator
+	amount, err := cadence.NewUFix64("2.0")
+	assert.NoError(t, err)
+	payerAddr := cadence.BytesToAddress(g.Accounts[payerAcct].Address.Bytes())
+	txIndex, err := c.NextTxIndex(vaultAddr)
+	assert.NoError(t, err)
+	signable, err := util.GetSignableDataFromScript(g, vaultAcct, txIndex, "deposit", amount, payerAddr)
+	assert.NoError(t, err)
+	sig, err := util.SignPayload(util.GetSigner(g, vault.Acct1000), signable)
+	assert.NoError(t, err)
+	_, err = c.Propose(vaultAddr, txIndex, "deposit", []cadence.Value{amount, payerAddr}, signer.PublicKeyHex(util.GetSigner(g, vault.Acct1000)), sig)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	proposals, err := c.List(vaultAddr, "")
+	assert.NoError(t, err)
+	assert.Empty(t, proposals)
+	postPayerBalance, err := util.GetBalance(g, payerAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, payerBalance, postPayerBalance)
+	postChainTxIndex, err := util.GetTxIndex(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, chainTxIndex, postChainTxIndex)
+}
@@ -0,0 +1,21 @@
+package coordinator
+
+import (
+	"log"
+	"os"
+	"testing"
+
+	"github.com/flow-hydraulics/onchain-multisig/emulator"
+	"github.com/flow-hydraulics/onchain-multisig/vault"
+)
+
+var emu *emulator.Emulator
+
+func TestMain(m *testing.M) {
+	emu = emulator.MustStart("../../..")
+	if err := vault.SetupVault(emu.GoWithTheFlow(), "vaulted-account", "100.0"); err != nil {
+		emu.Stop()
+		log.Fatalf("Cannot set up the vault: %s", err)
+	}
+	os.Exit(emu.Run(m))
+}
@@ -0,0 +1,191 @@
+package coordinator
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/onflow/cadence"
+	jsoncdc "github.com/onflow/cadence/encoding/json"
+	"github.com/onflow/flow-go-sdk"
+)
+
+// REST API
+//
+// GET  {url}/proposals/next?vault= returns the NextTxIndex of a vault, the txIndex to sign a new proposal for
+// POST {url}/proposals with a ProposeRequest creates a proposal
+// GET  {url}/proposals?vault=&status= lists proposals, optionally of one vault and with one status
+// GET  {url}/proposals/{id} returns a proposal
+// POST {url}/proposals/{id}/signatures with a SignatureRequest adds a signature to a proposal
+//
+// Proposals are returned as JSON, errors with a non 2xx status and the error message as the body
+const ProposalsEndpoint = "/proposals"
+
+// ProposeRequest is signed by its proposer as any signature of the proposal, see SignatureRequest
+type ProposeRequest struct {
+	Vault string `json:"vault"`
+	// TxIndex must be the NextTxIndex of the vault
+	TxIndex uint64 `json:"txIndex"`
+	Method  string `json:"method"`
+	// Args are JSON-Cadence encoded
+	Args      []json.RawMessage `json:"args"`
+	PublicKey string            `json:"publicKey"`
+	Signature string            `json:"signature"`
+}
+
+type NextTxIndexResponse struct {
+	Vault   string `json:"vault"`
+	TxIndex uint64 `json:"txIndex"`
+}
+
+type SignatureRequest struct {
+	PublicKey string `json:"publicKey"`
+	// Signature is the hex encoded signature of the signable data of the proposal,
+	// prefixed with the user domain tag as `util.SignPayload` does
+	Signature string `json:"signature"`
+}
+
+// NewHandler serves the REST API of `c`
+func NewHandler(c *Coordinator) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(ProposalsEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			listProposals(c, w, r)
+		case http.MethodPost:
+			propose(c, w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc(ProposalsEndpoint+"/", func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, ProposalsEndpoint+"/")
+		if id := strings.TrimSuffix(path, "/signatures"); id != path {
+			if r.Method != http.MethodPost {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			addSignature(c, id, w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if path == "next" {
+			nextTxIndex(c, w, r)
+			return
+		}
+		p, err := c.Get(path)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, p)
+	})
+	return mux
+}
+
+func listProposals(c *Coordinator, w http.ResponseWriter, r *http.Request) {
+	vault := flow.EmptyAddress
+	if v := r.URL.Query().Get("vault"); v != "" {
+		var err error
+		vault, err = parseAddress(v)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	proposals, err := c.List(vault, Status(r.URL.Query().Get("status")))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if proposals == nil {
+		proposals = []*Proposal{}
+	}
+	writeJSON(w, http.StatusOK, proposals)
+}
+
+func nextTxIndex(c *Coordinator, w http.ResponseWriter, r *http.Request) {
+	vault, err := parseAddress(r.URL.Query().Get("vault"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	txIndex, err := c.NextTxIndex(vault)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, NextTxIndexResponse{Vault: vault.Hex(), TxIndex: txIndex})
+}
+
+func propose(c *Coordinator, w http.ResponseWriter, r *http.Request) {
+	var req ProposeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid propose request", http.StatusBadRequest)
+		return
+	}
+	vault, err := parseAddress(req.Vault)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	args := make([]cadence.Value, len(req.Args))
+	for i, arg := range req.Args {
+		args[i], err = jsoncdc.Decode(arg)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("arg %d is not JSON-Cadence: %s", i, err), http.StatusBadRequest)
+			return
+		}
+	}
+	p, err := c.Propose(vault, req.TxIndex, req.Method, args, req.PublicKey, req.Signature)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, p)
+}
+
+func addSignature(c *Coordinator, id string, w http.ResponseWriter, r *http.Request) {
+	var req SignatureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid signature request", http.StatusBadRequest)
+		return
+	}
+	p, err := c.AddSignature(id, req.PublicKey, req.Signature)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+func parseAddress(s string) (flow.Address, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil || len(b) != flow.AddressLength {
+		return flow.EmptyAddress, fmt.Errorf("%w: invalid address %s", ErrBadRequest, s)
+	}
+	return flow.BytesToAddress(b), nil
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrBadRequest):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,138 @@
+package coordinator
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/onflow/flow-go-sdk"
+	bolt "go.etcd.io/bbolt"
+)
+
+var proposalsBucket = []byte("proposals")
+
+var ErrNotFound = errors.New("proposal not found")
+
+// Store persists proposals in a bolt database,
+// ordered by vault, txIndex and generation so that they are submitted in order.
+// Proposals are never overwritten by later proposals at the same txIndex, those get the next generation
+type Store struct {
+	db *bolt.DB
+}
+
+// OpenStore opens the store at `path`, creating it if it does not exist
+func OpenStore(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(proposalsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) Put(p *Proposal) error {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(proposalsBucket).Put(proposalKey(flow.HexToAddress(p.Vault), p.TxIndex, p.Generation), b)
+	})
+}
+
+// Get returns the proposal with `id`, or ErrNotFound
+func (s *Store) Get(id string) (*Proposal, error) {
+	vault, txIndex, generation, err := ParseProposalID(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	var p *Proposal
+	err = s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(proposalsBucket).Get(proposalKey(vault, txIndex, generation))
+		if b == nil {
+			return ErrNotFound
+		}
+		p = &Proposal{}
+		return json.Unmarshal(b, p)
+	})
+	return p, err
+}
+
+// List returns the proposals for `vault` in txIndex order, or of all vaults if `vault` is the empty address
+func (s *Store) List(vault flow.Address) (proposals []*Proposal, err error) {
+	var prefix []byte
+	if vault != flow.EmptyAddress {
+		prefix = vault.Bytes()
+	}
+	err = s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(proposalsBucket).Cursor()
+		for k, v := c.Seek(prefix); k != nil && strings.HasPrefix(string(k), string(prefix)); k, v = c.Next() {
+			p := &Proposal{}
+			if err := json.Unmarshal(v, p); err != nil {
+				return err
+			}
+			proposals = append(proposals, p)
+		}
+		return nil
+	})
+	return
+}
+
+// NextGeneration returns the generation of the next proposal for `txIndex` of `vault`,
+// the number of proposals stored for it
+func (s *Store) NextGeneration(vault flow.Address, txIndex uint64) (generation uint64, err error) {
+	prefix := proposalKey(vault, txIndex, 0)[:flow.AddressLength+8]
+	err = s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(proposalsBucket).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && strings.HasPrefix(string(k), string(prefix)); k, _ = c.Next() {
+			generation = binary.BigEndian.Uint64(k[len(prefix):]) + 1
+		}
+		return nil
+	})
+	return
+}
+
+func proposalKey(vault flow.Address, txIndex uint64, generation uint64) []byte {
+	key := make([]byte, flow.AddressLength+16)
+	copy(key, vault.Bytes())
+	binary.BigEndian.PutUint64(key[flow.AddressLength:], txIndex)
+	binary.BigEndian.PutUint64(key[flow.AddressLength+8:], generation)
+	return key
+}
+
+// ProposalID returns the id of the proposal of `generation` for `txIndex` of `vault`
+func ProposalID(vault flow.Address, txIndex uint64, generation uint64) string {
+	return fmt.Sprintf("%s-%d-%d", vault.Hex(), txIndex, generation)
+}
+
+func ParseProposalID(id string) (vault flow.Address, txIndex uint64, generation uint64, err error) {
+	parts := strings.Split(id, "-")
+	if len(parts) != 3 {
+		err = fmt.Errorf("invalid proposal id %s", id)
+		return
+	}
+	vault, err = parseAddress(parts[0])
+	if err != nil {
+		return
+	}
+	txIndex, err = strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return
+	}
+	generation, err = strconv.ParseUint(parts[2], 10, 64)
+	return
+}
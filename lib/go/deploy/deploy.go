@@ -0,0 +1,431 @@
+// Package deploy deploys the contracts of this repository to a network, as described by the manifest deploy.json.
+//
+// A deployment is idempotent: the accounts which exist are not created again, and the contracts whose code is
+// deployed already are not sent again. The contracts are deployed, or updated if their code changed, in the order of
+// their imports, and the hash of the code on chain is checked against the code sent. The addresses the accounts and
+// contracts resolved to are written to an addresses file, which util.ParseCadenceTemplate renders the templates with
+// when util.AddressesEnv is set.
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"text/template"
+
+	"github.com/bjartek/go-with-the-flow/gwtf"
+	util "github.com/flow-hydraulics/onchain-multisig"
+	"github.com/onflow/cadence"
+	"github.com/onflow/cadence/runtime/ast"
+	"github.com/onflow/cadence/runtime/parser2"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/client"
+	"github.com/onflow/flow-go-sdk/templates"
+	"golang.org/x/crypto/sha3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ManifestFile is the manifest, relative to the root of the repository
+const ManifestFile = "deploy.json"
+
+// Manifest is what is deployed on each network, by the name of the network
+type Manifest map[string]Network
+
+// Network is what is deployed on a network
+type Network struct {
+	// Host is the access API of the network
+	Host string `json:"host"`
+	// Accounts are the accounts in flow.json created if they do not exist, in this order
+	Accounts []Account `json:"accounts"`
+	// Contracts are the contracts by name
+	Contracts map[string]Contract `json:"contracts"`
+}
+
+// Account is an account in flow.json
+type Account struct {
+	Name string `json:"name"`
+	// Fund is the amount of FLOW the service account sends to the account when it is created,
+	// with transactions/transfer_flow_tokens_emulator.cdc, so only on the emulator
+	Fund string `json:"fund,omitempty"`
+}
+
+// Contract is either deployed from `Source` to `Account`, or deployed by others at `Address`
+type Contract struct {
+	// Source is the path of the code of the contract, relative to the root of the repository
+	Source string `json:"source,omitempty"`
+	// Account is the account in flow.json the contract is deployed to
+	Account string `json:"account,omitempty"`
+	// Address is the address of a contract which is not deployed but only checked to exist
+	Address string `json:"address,omitempty"`
+}
+
+// Deployment is the addresses file written after a deployment, the addresses are hex without a prefix
+type Deployment struct {
+	Network   string            `json:"network"`
+	Accounts  map[string]string `json:"accounts"`
+	Contracts map[string]string `json:"contracts"`
+	// Hashes are the SHA3-256 hashes of the code of the contracts deployed from their source
+	Hashes map[string]string `json:"hashes"`
+}
+
+// LoadManifest reads the manifest at `path`
+func LoadManifest(path string) (m Manifest, err error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return
+}
+
+// LoadDeployment reads the addresses file at `path`, it returns nil if there is none
+func LoadDeployment(path string) (*Deployment, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	d := &Deployment{}
+	if err := json.Unmarshal(b, d); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return d, nil
+}
+
+// Write writes the addresses file to `path`
+func (d *Deployment) Write(path string) error {
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// Deployer deploys the contracts in `Root` with the accounts of `G`, whose address is the access API deployed to
+type Deployer struct {
+	G    *gwtf.GoWithTheFlow
+	Root string
+	// Logf logs what is done, if set
+	Logf func(format string, args ...interface{})
+	// CheckUpdate checks that the code of a deployed contract can be updated, if set,
+	// as the emulator does not validate updates the way the other networks do
+	CheckUpdate func(name string, oldCode []byte, newCode []byte) error
+}
+
+func (d *Deployer) logf(format string, args ...interface{}) {
+	if d.Logf != nil {
+		d.Logf(format, args...)
+	}
+}
+
+// Deploy deploys `n` to the network `network`. The accounts at their address in `previous`, the addresses file of an
+// earlier deployment to the network if not nil, or else at their address in flow.json are not created again.
+// The accounts of G are moved to the addresses they are created at
+func (d *Deployer) Deploy(network string, n Network, previous *Deployment) (*Deployment, error) {
+	c, err := client.New(d.G.Address, grpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	result := &Deployment{
+		Network:   network,
+		Accounts:  map[string]string{},
+		Contracts: map[string]string{},
+		Hashes:    map[string]string{},
+	}
+	if previous != nil && previous.Network != network {
+		return nil, fmt.Errorf("the previous deployment is to %s, not %s", previous.Network, network)
+	}
+
+	accounts := n.Accounts
+	for _, name := range sortedNames(n.Contracts) {
+		if acct := n.Contracts[name].Account; acct != "" && !hasAccount(accounts, acct) {
+			accounts = append(accounts, Account{Name: acct})
+		}
+	}
+	for _, acct := range accounts {
+		address, err := d.ensureAccount(c, acct, previous)
+		if err != nil {
+			return nil, err
+		}
+		result.Accounts[acct.Name] = address.Hex()
+	}
+
+	order, err := d.order(n)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range order {
+		contract := n.Contracts[name]
+		if contract.Source == "" {
+			if err := d.checkDeployed(c, name, flow.HexToAddress(contract.Address)); err != nil {
+				return nil, err
+			}
+			result.Contracts[name] = flow.HexToAddress(contract.Address).Hex()
+			continue
+		}
+		code, err := d.render(contract.Source, result.Contracts)
+		if err != nil {
+			return nil, err
+		}
+		hash, err := d.deployContract(c, name, contract.Account, code)
+		if err != nil {
+			return nil, err
+		}
+		result.Contracts[name] = result.Accounts[contract.Account]
+		result.Hashes[name] = hash
+	}
+	return result, nil
+}
+
+func hasAccount(accounts []Account, name string) bool {
+	for _, acct := range accounts {
+		if acct.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedNames(contracts map[string]Contract) []string {
+	names := []string{}
+	for name := range contracts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ensureAccount creates `acct` if it does not exist, and returns its address
+func (d *Deployer) ensureAccount(c *client.Client, acct Account, previous *Deployment) (flow.Address, error) {
+	account, ok := d.G.Accounts[acct.Name]
+	if !ok {
+		return flow.EmptyAddress, fmt.Errorf("unknown account %s", acct.Name)
+	}
+	if previous != nil && previous.Accounts[acct.Name] != "" {
+		account.Address = flow.HexToAddress(previous.Accounts[acct.Name])
+		d.G.Accounts[acct.Name] = account
+	}
+
+	exists, err := accountExists(c, account.Address)
+	if err != nil || exists {
+		return account.Address, err
+	}
+	created, err := util.CreateAccount(d.G, acct.Name)
+	if err != nil {
+		return flow.EmptyAddress, fmt.Errorf("could not create %s: %w", acct.Name, err)
+	}
+	d.logf("created %s at %s", acct.Name, created.Hex())
+	account.Address = created
+	d.G.Accounts[acct.Name] = account
+
+	if acct.Fund != "" {
+		txFilename := filepath.Join(d.Root, "transactions", "transfer_flow_tokens_emulator.cdc")
+		_, err = d.G.TransactionFromFile(txFilename, util.ParseCadenceTemplate(txFilename)).
+			SignProposeAndPayAsService().
+			UFix64Argument(acct.Fund).
+			AccountArgument(acct.Name).
+			Run()
+		if err != nil {
+			return flow.EmptyAddress, fmt.Errorf("could not fund %s: %w", acct.Name, err)
+		}
+		d.logf("funded %s with %s FLOW", acct.Name, acct.Fund)
+	}
+	return created, nil
+}
+
+func accountExists(c *client.Client, address flow.Address) (bool, error) {
+	_, err := c.GetAccount(context.Background(), address)
+	if status.Code(err) == codes.NotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// imports matches the imports of contracts
+var imports = regexp.MustCompile(`(?m)^\s*import\s+(\w+)\s+from\s+(\S+)`)
+
+// order returns the contracts of `n` in the order they are deployed in, each after the contracts it imports
+func (d *Deployer) order(n Network) ([]string, error) {
+	deps := map[string][]string{}
+	for name, contract := range n.Contracts {
+		if contract.Source == "" {
+			continue
+		}
+		code, err := ioutil.ReadFile(filepath.Join(d.Root, contract.Source))
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range imports.FindAllStringSubmatch(string(code), -1) {
+			if _, ok := n.Contracts[match[1]]; !ok {
+				return nil, fmt.Errorf("%s imports %s, which is not in the manifest", name, match[1])
+			}
+			deps[name] = append(deps[name], match[1])
+		}
+	}
+
+	order := []string{}
+	state := map[string]int{} // 1 while the imports of the contract are visited, 2 once it is ordered
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case 1:
+			return fmt.Errorf("the imports of the contracts are cyclic: %v", append(path, name))
+		case 2:
+			return nil
+		}
+		state[name] = 1
+		for _, dep := range deps[name] {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+		order = append(order, name)
+		return nil
+	}
+	for _, name := range sortedNames(n.Contracts) {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// fileImport matches the imports of contracts by path, as resolved by `flow project deploy`
+var fileImport = regexp.MustCompile(`(?m)^(\s*import\s+(\w+)\s+from\s+)"[^"]*"`)
+
+// render returns the code of the contract at `source` with the addresses of the contracts it imports
+func (d *Deployer) render(source string, contracts map[string]string) ([]byte, error) {
+	code, err := ioutil.ReadFile(filepath.Join(d.Root, source))
+	if err != nil {
+		return nil, err
+	}
+	code = fileImport.ReplaceAllFunc(code, func(match []byte) []byte {
+		groups := fileImport.FindSubmatch(match)
+		return []byte(fmt.Sprintf("%s0x%s", groups[1], contracts[string(groups[2])]))
+	})
+	tmpl, err := template.New(source).Option("missingkey=error").Parse(string(code))
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, contracts); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Hash returns the hex of the SHA3-256 hash of `code`
+func Hash(code []byte) string {
+	hash := sha3.Sum256(code)
+	return hex.EncodeToString(hash[:])
+}
+
+// deployContract deploys `code` as the contract `name` of `acct`, or updates it if its code is not `code`,
+// and returns the hash of its code once it is checked
+func (d *Deployer) deployContract(c *client.Client, name string, acct string, code []byte) (string, error) {
+	address := d.G.Accounts[acct].Address
+	account, err := c.GetAccount(context.Background(), address)
+	if err != nil {
+		return "", err
+	}
+	deployed, exists := account.Contracts[name]
+	if exists && bytes.Equal(deployed, code) {
+		d.logf("%s is up to date at %s", name, address.Hex())
+		return Hash(code), nil
+	}
+
+	contract := templates.Contract{Name: name, Source: string(code)}
+	var filename string
+	var script []byte
+	switch {
+	case exists:
+		if d.CheckUpdate != nil {
+			if err := d.CheckUpdate(name, deployed, code); err != nil {
+				return "", fmt.Errorf("cannot update %s at %s: %w", name, address.Hex(), err)
+			}
+		}
+		filename, script = "update_contract", templates.UpdateAccountContract(address, contract).Script
+	default:
+		auth, err := initTakesAuthAccount(name, code)
+		if err != nil {
+			return "", err
+		}
+		if auth {
+			filename = filepath.Join(d.Root, "transactions", "deploy_contract_with_auth.cdc")
+			script = util.ParseCadenceTemplate(filename)
+		} else {
+			filename, script = "add_contract", templates.AddAccountContract(address, contract).Script
+		}
+	}
+	_, err = util.SendTransaction(d.G, util.AccountRoles(acct), filename, script,
+		cadence.String(name), cadence.String(contract.SourceHex()))
+	if err != nil {
+		return "", fmt.Errorf("could not deploy %s to %s: %w", name, acct, err)
+	}
+	if exists {
+		d.logf("updated %s at %s", name, address.Hex())
+	} else {
+		d.logf("deployed %s to %s", name, address.Hex())
+	}
+
+	account, err = c.GetAccount(context.Background(), address)
+	if err != nil {
+		return "", err
+	}
+	if hash, expected := Hash(account.Contracts[name]), Hash(code); hash != expected {
+		return "", fmt.Errorf("the code of %s at %s has the hash %s, not %s", name, address.Hex(), hash, expected)
+	}
+	return Hash(code), nil
+}
+
+// initTakesAuthAccount returns true if the initializer of the contract takes the account it is deployed to,
+// as transactions/deploy_contract_with_auth.cdc passes it, and false if it takes nothing
+func initTakesAuthAccount(name string, code []byte) (bool, error) {
+	program, err := parser2.ParseProgram(string(code))
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", name, err)
+	}
+	contract := program.SoleContractDeclaration()
+	if contract == nil {
+		return false, nil
+	}
+	var params []*ast.Parameter
+	if inits := contract.Members.Initializers(); len(inits) > 0 {
+		params = inits[0].FunctionDeclaration.ParameterList.Parameters
+	}
+	switch {
+	case len(params) == 0:
+		return false, nil
+	case len(params) == 1 && params[0].TypeAnnotation.Type.String() == "AuthAccount":
+		return true, nil
+	}
+	return false, fmt.Errorf("the initializer of %s takes args other than an AuthAccount", name)
+}
+
+// checkDeployed checks that the contract `name` is at `address`
+func (d *Deployer) checkDeployed(c *client.Client, name string, address flow.Address) error {
+	account, err := c.GetAccount(context.Background(), address)
+	if err != nil {
+		return fmt.Errorf("could not get the account of %s at %s: %w", name, address.Hex(), err)
+	}
+	if _, ok := account.Contracts[name]; !ok {
+		return fmt.Errorf("%s is not deployed at %s", name, address.Hex())
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package deploy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifestOrdersContractsByImports(t *testing.T) {
+	m, err := LoadManifest("../../../" + ManifestFile)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	for _, network := range []string{"emulator", "testnet", "mainnet"} {
+		order, err := (&Deployer{Root: "../../.."}).order(m[network])
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"FungibleToken", "OnChainMultiSig", "MultiSigFlowToken"}, order, network)
+	}
+}
+
+// newRoot returns a repository with the contracts in `contracts` by name
+func newRoot(t *testing.T, contracts map[string]string) (string, Network) {
+	root := t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(root, "contracts"), 0755))
+	n := Network{Contracts: map[string]Contract{}}
+	for name, code := range contracts {
+		source := "contracts/" + name + ".cdc"
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(root, source), []byte(code), 0644))
+		n.Contracts[name] = Contract{Source: source, Account: "owner"}
+	}
+	return root, n
+}
+
+func TestCyclicImportsError(t *testing.T) {
+	root, n := newRoot(t, map[string]string{
+		"A": "import B from 0x{{.B}}\npub contract A {}\n",
+		"B": "import C from 0x{{.C}}\npub contract B {}\n",
+		"C": "import A from \"./A.cdc\"\npub contract C {}\n",
+	})
+	_, err := (&Deployer{Root: root}).order(n)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cyclic")
+	}
+}
+
+func TestImportsOutOfTheManifestError(t *testing.T) {
+	root, n := newRoot(t, map[string]string{"A": "import B from 0x{{.B}}\npub contract A {}\n"})
+	_, err := (&Deployer{Root: root}).order(n)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "A imports B")
+	}
+}
+
+func TestRenderResolvesImports(t *testing.T) {
+	root, _ := newRoot(t, map[string]string{
+		"A": "import Crypto\nimport B from \"./B.cdc\"\nimport C from 0x{{.C}}\npub contract A {}\n",
+	})
+	d := &Deployer{Root: root}
+	code, err := d.render("contracts/A.cdc", map[string]string{"B": "01cf0e2f2f715450", "C": "ee82856bf20e2aa6"})
+	assert.NoError(t, err)
+	assert.Equal(t, "import Crypto\nimport B from 0x01cf0e2f2f715450\nimport C from 0xee82856bf20e2aa6\npub contract A {}\n", string(code))
+
+	_, err = d.render("contracts/A.cdc", map[string]string{"B": "01cf0e2f2f715450"})
+	assert.Error(t, err)
+}
+
+func TestInitTakesAuthAccount(t *testing.T) {
+	auth, err := initTakesAuthAccount("A", []byte("pub contract A { init(admin: AuthAccount) {} }"))
+	assert.NoError(t, err)
+	assert.True(t, auth)
+	auth, err = initTakesAuthAccount("A", []byte("pub contract A { init() {} }"))
+	assert.NoError(t, err)
+	assert.False(t, auth)
+	auth, err = initTakesAuthAccount("A", []byte("pub contract interface A {}"))
+	assert.NoError(t, err)
+	assert.False(t, auth)
+	_, err = initTakesAuthAccount("A", []byte("pub contract A { init(supply: UFix64) {} }"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,275 @@
+// Package docs generates the reference of the transactions and scripts of the repository, doc/REFERENCE.md.
+//
+// The signatures of the files are parsed with the Cadence parser by the generator of the `bindings` package: each
+// file has a section with its leading comments, a table of its arguments with their types and the descriptions from
+// the comments, the signers of a transaction or the return type of a script, and the Go functions which wrap it, its
+// method of `bindings.Client` and the functions of the Go packages naming the file.
+package docs
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/flow-hydraulics/onchain-multisig/bindings/generator"
+)
+
+// Header is the first line of the generated reference
+const Header = "<!-- Code generated by scripts/generate-docs from the transactions and scripts. DO NOT EDIT. -->"
+
+// File is the path of the reference relative to the root of the repository
+const File = "doc/REFERENCE.md"
+
+// skipped are the directories of the Go packages not searched for helpers: the commands,
+// and the bindings, whose methods are listed by their generated names
+var skipped = map[string]bool{"scripts": true, "bindings": true, "testdata": true}
+
+// Entry is the documentation of a transaction or script
+type Entry struct {
+	generator.Binding
+	// Helpers are the Go functions naming the file, such as `util.GetBalance` or `coordinator.FlowChain.GetTxIndex`
+	Helpers []string
+}
+
+// Parse parses the transactions and scripts of the repository at `root`,
+// and the Go packages in `goRoot` for their helpers
+func Parse(root string, goRoot string) ([]Entry, error) {
+	bindings, err := generator.Parse(root)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, b := range bindings {
+		files = append(files, b.File)
+	}
+	helpers, err := Helpers(goRoot, files)
+	if err != nil {
+		return nil, err
+	}
+	entries := []Entry{}
+	for _, b := range bindings {
+		entries = append(entries, Entry{Binding: b, Helpers: helpers[b.File]})
+	}
+	return entries, nil
+}
+
+// Helpers returns the exported Go functions and methods in the packages of `goRoot` naming each of `files`, by file.
+// A file is named by a string literal ending with its path, or with its name if the call the literal is an argument
+// of has the literal directory of the file as another argument, or if no other of `files` has the same name.
+func Helpers(goRoot string, files []string) (map[string][]string, error) {
+	byName := map[string][]string{}
+	known := map[string]bool{}
+	for _, file := range files {
+		byName[path.Base(file)] = append(byName[path.Base(file)], file)
+		known[file] = true
+	}
+	resolve := func(lit string, dir string) string {
+		for file := range known {
+			if strings.HasSuffix(lit, "/"+file) || lit == file {
+				return file
+			}
+		}
+		candidates := byName[path.Base(lit)]
+		switch {
+		case dir != "" && known[dir+"/"+path.Base(lit)]:
+			return dir + "/" + path.Base(lit)
+		case len(candidates) == 1 && !strings.Contains(lit, "/"):
+			return candidates[0]
+		}
+		return ""
+	}
+
+	helpers := map[string][]string{}
+	fset := token.NewFileSet()
+	err := filepath.Walk(goRoot, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if p != goRoot && (skipped[info.Name()] || strings.HasPrefix(info.Name(), ".")) {
+			return filepath.SkipDir
+		}
+		pkgs, err := parser.ParseDir(fset, p, func(fi os.FileInfo) bool {
+			return !strings.HasSuffix(fi.Name(), "_test.go")
+		}, 0)
+		if err != nil {
+			return err
+		}
+		for _, pkg := range pkgs {
+			for _, f := range pkg.Files {
+				for _, decl := range f.Decls {
+					fn, ok := decl.(*ast.FuncDecl)
+					if !ok || fn.Body == nil {
+						continue
+					}
+					name, ok := funcName(pkg.Name, fn)
+					if !ok {
+						continue
+					}
+					for _, file := range namedFiles(fn.Body, resolve) {
+						helpers[file] = append(helpers[file], name)
+					}
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for file := range helpers {
+		sort.Strings(helpers[file])
+	}
+	return helpers, nil
+}
+
+// funcName returns the qualified name of `fn` in the package `pkg`, false if it is not exported
+func funcName(pkg string, fn *ast.FuncDecl) (string, bool) {
+	if !fn.Name.IsExported() {
+		return "", false
+	}
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return pkg + "." + fn.Name.Name, true
+	}
+	t := fn.Recv.List[0].Type
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	recv, ok := t.(*ast.Ident)
+	if !ok || !recv.IsExported() {
+		return "", false
+	}
+	return pkg + "." + recv.Name + "." + fn.Name.Name, true
+}
+
+// namedFiles returns the files named by the string literals of `body`, resolved by `resolve`
+func namedFiles(body *ast.BlockStmt, resolve func(lit string, dir string) string) (files []string) {
+	dirs := map[*ast.BasicLit]string{}
+	seen := map[string]bool{}
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			// The literal directory of a file joined with its name, as in filepath.Join(root, "scripts", "x.cdc")
+			dir := ""
+			for _, arg := range n.Args {
+				if s, ok := stringLit(arg); ok && (s == "transactions" || s == "scripts") {
+					dir = s
+				}
+			}
+			for _, arg := range n.Args {
+				if lit, ok := arg.(*ast.BasicLit); ok {
+					dirs[lit] = dir
+				}
+			}
+		case *ast.BasicLit:
+			s, ok := stringLit(n)
+			if !ok || !strings.HasSuffix(s, ".cdc") {
+				return true
+			}
+			if file := resolve(s, dirs[n]); file != "" && !seen[file] {
+				seen[file] = true
+				files = append(files, file)
+			}
+		}
+		return true
+	})
+	return
+}
+
+func stringLit(e ast.Expr) (string, bool) {
+	lit, ok := e.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
+
+// Markdown returns the reference of `entries`, whose links are relative to the doc directory
+func Markdown(entries []Entry) []byte {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "%s\n<!-- markdownlint-disable -->\n\n# Transactions and Scripts\n", Header)
+	for _, section := range []struct {
+		title  string
+		script bool
+	}{{"Transactions", false}, {"Scripts", true}} {
+		fmt.Fprintf(buf, "\n## %s\n", section.title)
+		for _, e := range entries {
+			if e.IsScript() == section.script {
+				writeEntry(buf, e)
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+func writeEntry(buf *bytes.Buffer, e Entry) {
+	fmt.Fprintf(buf, "\n### %s\n\n[%s](../%s)\n", path.Base(e.File), e.File, e.File)
+	// The lines describing the arguments are in their table
+	described := map[string]bool{}
+	for _, p := range e.Params {
+		if p.Doc != "" {
+			described[p.Doc] = true
+		}
+	}
+	doc := []string{}
+	for _, line := range e.Doc {
+		if !described[line] {
+			doc = append(doc, line)
+		}
+	}
+	for len(doc) > 0 && doc[len(doc)-1] == "" {
+		doc = doc[:len(doc)-1]
+	}
+	if len(doc) > 0 {
+		fmt.Fprintf(buf, "\n%s\n", strings.Join(doc, "\n"))
+	}
+
+	fmt.Fprint(buf, "\n")
+	if len(e.Params) == 0 {
+		fmt.Fprint(buf, "No arguments.\n")
+	} else {
+		fmt.Fprint(buf, "| Argument | Type | Description |\n| --- | --- | --- |\n")
+		for _, p := range e.Params {
+			fmt.Fprintf(buf, "| `%s` | `%s` | %s |\n", p.Name, p.Type, strings.ReplaceAll(p.Doc, "|", `\|`))
+		}
+	}
+
+	fmt.Fprint(buf, "\n")
+	if e.IsScript() {
+		fmt.Fprintf(buf, "Returns `%s`.\n", e.Returns)
+	} else if len(e.Signers) == 0 {
+		fmt.Fprint(buf, "Signers: none.\n")
+	} else {
+		fmt.Fprintf(buf, "Signers: %s.\n", codeList(e.Signers))
+	}
+
+	fmt.Fprintf(buf, "\nGo: %s.\n", codeList(append([]string{"bindings.Client." + e.Name}, e.Helpers...)))
+}
+
+func codeList(names []string) string {
+	quoted := []string{}
+	for _, name := range names {
+		quoted = append(quoted, "`"+name+"`")
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// Generate returns the reference of the transactions and scripts of `root` and their helpers in `goRoot`
+func Generate(root string, goRoot string) ([]byte, error) {
+	entries, err := Parse(root, goRoot)
+	if err != nil {
+		return nil, err
+	}
+	return Markdown(entries), nil
+}
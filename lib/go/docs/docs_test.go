@@ -0,0 +1,148 @@
+package docs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReferenceIsUpToDate(t *testing.T) {
+	generated, err := Generate("../../..", "..")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	reference, err := ioutil.ReadFile(filepath.Join("../../..", File))
+	assert.NoError(t, err)
+	assert.Equal(t, string(generated), string(reference),
+		"the reference of the transactions and scripts is stale, run `make doc/REFERENCE.md`")
+}
+
+// write writes the files in `files` by path in `dir`
+func write(t *testing.T, dir string, files map[string]string) {
+	for path, content := range files {
+		assert.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), 0755))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, path), []byte(content), 0644))
+	}
+}
+
+const helpers = `package vault
+
+import "path/filepath"
+
+type Client struct{ root string }
+
+func Send() { _ = "../../../transactions/send.cdc" }
+
+func (c *Client) Balance() { c.script("get.cdc") }
+
+func (c Client) Both() {
+	_ = filepath.Join(c.root, "transactions", "same.cdc")
+	_ = filepath.Join(c.root, "scripts", "get.cdc")
+}
+
+func Ambiguous() { _ = "same.cdc" }
+
+func unexported() { _ = "../../../transactions/send.cdc" }
+
+type client struct{}
+
+func (c client) Send() { _ = "../../../transactions/send.cdc" }
+
+func (c *Client) script(name string) { _ = filepath.Join(c.root, "scripts", name) }
+`
+
+func TestHelpers(t *testing.T) {
+	dir := t.TempDir()
+	write(t, dir, map[string]string{
+		"vault/vault.go":       helpers,
+		"vault/vault_test.go":  "package vault\n\nfunc TestSend() { _ = \"transactions/send.cdc\" }\n",
+		"scripts/cmd/cmd.go":   "package main\n\nfunc Main() { _ = \"transactions/send.cdc\" }\n",
+		"bindings/bindings.go": "package bindings\n\nfunc Send() { _ = \"transactions/send.cdc\" }\n",
+	})
+	h, err := Helpers(dir, []string{"transactions/send.cdc", "transactions/same.cdc", "scripts/same.cdc", "scripts/get.cdc"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"transactions/send.cdc": {"vault.Send"},
+		"transactions/same.cdc": {"vault.Client.Both"},
+		"scripts/get.cdc":       {"vault.Client.Balance", "vault.Client.Both"},
+	}, h)
+}
+
+const tx = `// This transaction transfers tokens
+//
+// ` + "`amount`" + ` is withdrawn from the signer
+import FungibleToken from 0x{{.FungibleToken}}
+
+transaction(amount: UFix64, to: Address) {
+    prepare(signer: AuthAccount) {}
+}
+`
+
+const script = `pub fun main(account: Address): UFix64? {
+    return nil
+}
+`
+
+func TestMarkdown(t *testing.T) {
+	root := t.TempDir()
+	write(t, root, map[string]string{
+		"transactions/transfer.cdc":  tx,
+		"transactions/nothing.cdc":   "transaction {\n    prepare() {}\n}\n",
+		"scripts/get_balance.cdc":    script,
+		"lib/go/vault/vault.go":      "package vault\n\nfunc Transfer() { _ = \"transactions/transfer.cdc\" }\n",
+		"lib/go/vault/vault_test.go": "package vault\n",
+	})
+	reference, err := Generate(root, filepath.Join(root, "lib/go"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, Header+`
+<!-- markdownlint-disable -->
+
+# Transactions and Scripts
+
+## Transactions
+
+### nothing.cdc
+
+[transactions/nothing.cdc](../transactions/nothing.cdc)
+
+No arguments.
+
+Signers: none.
+
+Go: `+"`bindings.Client.Nothing`"+`.
+
+### transfer.cdc
+
+[transactions/transfer.cdc](../transactions/transfer.cdc)
+
+This transaction transfers tokens
+
+| Argument | Type | Description |
+| --- | --- | --- |
+| `+"`amount` | `UFix64` | `amount`"+` is withdrawn from the signer |
+| `+"`to` | `Address`"+` |  |
+
+Signers: `+"`signer`"+`.
+
+Go: `+"`bindings.Client.Transfer`, `vault.Transfer`"+`.
+
+## Scripts
+
+### get_balance.cdc
+
+[scripts/get_balance.cdc](../scripts/get_balance.cdc)
+
+| Argument | Type | Description |
+| --- | --- | --- |
+| `+"`account` | `Address`"+` |  |
+
+Returns `+"`UFix64?`"+`.
+
+Go: `+"`bindings.Client.GetBalance`"+`.
+`, string(reference))
+}
@@ -0,0 +1,166 @@
+// Package emulator boots a Flow emulator in process for the tests,
+// with the accounts in flow.json created and the contracts of this repository deployed.
+//
+// Each emulator starts from an empty chain on free ports,
+// so the test packages do not share any state and `go test ./...` needs no `flow emulator` running.
+package emulator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bjartek/go-with-the-flow/gwtf"
+	"github.com/flow-hydraulics/onchain-multisig/deploy"
+	"github.com/onflow/flow-emulator/server"
+	"github.com/onflow/flow-go-sdk/client"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+)
+
+// Owner is the account in flow.json the `OnChainMultiSig` and `MultiSigFlowToken` contracts are deployed to
+const Owner = "owner"
+
+// Network is the network of deploy.json deployed to the emulator
+const Network = "emulator"
+
+type Emulator struct {
+	root    string
+	address string
+	server  *server.EmulatorServer
+}
+
+// Start boots an emulator, creates the accounts and deploys the contracts,
+// `root` is the path to the root of this repository, where flow.json and the contracts are
+func Start(root string) (*Emulator, error) {
+	grpcPort, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+	httpPort, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := gwtf.NewGoWithTheFlowError(filepath.Join(root, "flow.json"))
+	if err != nil {
+		return nil, err
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.WarnLevel)
+	srv := server.NewEmulatorServer(logger, &server.Config{
+		GRPCPort:               grpcPort,
+		HTTPPort:               httpPort,
+		ServicePublicKey:       g.Service.PrivateKey.PublicKey(),
+		ServiceKeySigAlgo:      g.Service.SigAlgo,
+		ServiceKeyHashAlgo:     g.Service.HashAlgo,
+		GenesisTokenSupply:     1000000000000000000,
+		TransactionExpiry:      10,
+		TransactionMaxGasLimit: 9999,
+		ScriptGasLimit:         100000,
+	})
+	if srv == nil {
+		return nil, errors.New("could not configure the emulator")
+	}
+	go srv.Start()
+
+	e := &Emulator{
+		root:    root,
+		address: fmt.Sprintf("127.0.0.1:%d", grpcPort),
+		server:  srv,
+	}
+	if err := e.waitUntilReady(); err != nil {
+		e.Stop()
+		return nil, err
+	}
+	if err := e.deploy(); err != nil {
+		e.Stop()
+		return nil, err
+	}
+	return e, nil
+}
+
+// MustStart is Start for TestMain, it exits if the emulator cannot be started
+func MustStart(root string) *Emulator {
+	e, err := Start(root)
+	if err != nil {
+		log.Fatalf("Cannot start the emulator: %s", err)
+	}
+	return e
+}
+
+// Run runs the tests and stops the emulator, it returns the exit code for os.Exit
+func (e *Emulator) Run(m *testing.M) int {
+	defer e.Stop()
+	return m.Run()
+}
+
+func (e *Emulator) Stop() {
+	e.server.Stop()
+}
+
+// Address is the address of the access API of the emulator
+func (e *Emulator) Address() string {
+	return e.address
+}
+
+// GoWithTheFlow returns the accounts in flow.json, connected to the emulator
+func (e *Emulator) GoWithTheFlow() *gwtf.GoWithTheFlow {
+	g := gwtf.NewGoWithTheFlow(filepath.Join(e.root, "flow.json"))
+	g.Address = e.address
+	return g
+}
+
+// Client returns a new client of the emulator, to be closed by the caller
+func (e *Emulator) Client() (*client.Client, error) {
+	return client.New(e.address, grpc.WithInsecure())
+}
+
+func (e *Emulator) waitUntilReady() error {
+	c, err := e.Client()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	for i := 0; ; i++ {
+		err = c.Ping(context.Background())
+		if err == nil || i == 100 {
+			return err
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// deploy does what scripts/deploy does against a `flow emulator`,
+// and checks that the accounts are created at their address in flow.json
+func (e *Emulator) deploy() error {
+	m, err := deploy.LoadManifest(filepath.Join(e.root, deploy.ManifestFile))
+	if err != nil {
+		return err
+	}
+	d, err := (&deploy.Deployer{G: e.GoWithTheFlow(), Root: e.root}).Deploy(Network, m[Network], nil)
+	if err != nil {
+		return err
+	}
+	g := e.GoWithTheFlow()
+	for acct, address := range d.Accounts {
+		if address != g.Accounts[acct].Address.Hex() {
+			return fmt.Errorf("%s was created at %s, not at %s as in flow.json", acct, address, g.Accounts[acct].Address)
+		}
+	}
+	return nil
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,135 @@
+package emulator
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	util "github.com/flow-hydraulics/onchain-multisig"
+	"github.com/flow-hydraulics/onchain-multisig/deploy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStartDeploysContracts(t *testing.T) {
+	e, err := Start("../../..")
+	assert.NoError(t, err)
+	defer e.Stop()
+	g := e.GoWithTheFlow()
+
+	c, err := e.Client()
+	assert.NoError(t, err)
+	defer c.Close()
+	owner, err := c.GetAccount(context.Background(), g.Accounts[Owner].Address)
+	assert.NoError(t, err)
+	assert.Contains(t, owner.Contracts, "OnChainMultiSig")
+	assert.Contains(t, owner.Contracts, "MultiSigFlowToken")
+
+	m, err := deploy.LoadManifest("../../../deploy.json")
+	assert.NoError(t, err)
+	for _, acct := range m[Network].Accounts {
+		_, err := c.GetAccount(context.Background(), g.Accounts[acct.Name].Address)
+		assert.NoError(t, err, acct.Name)
+	}
+
+	// The owner holds the initial supply of MultiSigFlowToken
+	balance, err := util.GetBalance(g, Owner)
+	assert.NoError(t, err)
+	assert.Greater(t, uint64(balance), uint64(0))
+}
+
+func TestEmulatorsDoNotShareState(t *testing.T) {
+	first, err := Start("../../..")
+	assert.NoError(t, err)
+	defer first.Stop()
+	second, err := Start("../../..")
+	assert.NoError(t, err)
+	defer second.Stop()
+	assert.NotEqual(t, first.Address(), second.Address())
+
+	err = util.AdvanceBlocks(first.GoWithTheFlow(), 3, Owner)
+	assert.NoError(t, err)
+	firstHeight, err := util.GetBlockHeight(first.GoWithTheFlow())
+	assert.NoError(t, err)
+	secondHeight, err := util.GetBlockHeight(second.GoWithTheFlow())
+	assert.NoError(t, err)
+	assert.Equal(t, firstHeight, secondHeight+3)
+}
+
+func TestDeployIsIdempotent(t *testing.T) {
+	e, err := Start("../../..")
+	assert.NoError(t, err)
+	defer e.Stop()
+	m, err := deploy.LoadManifest("../../../deploy.json")
+	assert.NoError(t, err)
+
+	logs := []string{}
+	d := &deploy.Deployer{G: e.GoWithTheFlow(), Root: "../../..", Logf: func(format string, args ...interface{}) {
+		logs = append(logs, fmt.Sprintf(format, args...))
+	}}
+	first, err := d.Deploy(Network, m[Network], nil)
+	assert.NoError(t, err)
+	second, err := d.Deploy(Network, m[Network], first)
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+	assert.Equal(t, []string{
+		"OnChainMultiSig is up to date at 01cf0e2f2f715450",
+		"MultiSigFlowToken is up to date at 01cf0e2f2f715450",
+		"OnChainMultiSig is up to date at 01cf0e2f2f715450",
+		"MultiSigFlowToken is up to date at 01cf0e2f2f715450",
+	}, logs)
+	assert.Equal(t, "ee82856bf20e2aa6", first.Contracts["FungibleToken"])
+	assert.Equal(t, "01cf0e2f2f715450", first.Contracts["MultiSigFlowToken"])
+	assert.Len(t, first.Hashes, 2)
+
+	// The templates are rendered with the addresses written by a deployment
+	path := filepath.Join(t.TempDir(), "addresses.json")
+	assert.NoError(t, first.Write(path))
+	addresses, err := util.LoadAddresses(path)
+	assert.NoError(t, err)
+	assert.Equal(t, util.EmulatorAddresses, addresses)
+}
+
+func TestDeployUpdatesChangedContracts(t *testing.T) {
+	e, err := Start("../../..")
+	assert.NoError(t, err)
+	defer e.Stop()
+	m, err := deploy.LoadManifest("../../../deploy.json")
+	assert.NoError(t, err)
+
+	// A copy of the repository with a change to MultiSigFlowToken the update of a contract allows
+	root := t.TempDir()
+	for _, dir := range []string{"contracts", "transactions"} {
+		assert.NoError(t, os.Mkdir(filepath.Join(root, dir), 0755))
+	}
+	for _, file := range []string{"flow.json", "contracts/OnChainMultiSig.cdc", "contracts/MultiSigFlowToken.cdc", "transactions/deploy_contract_with_auth.cdc"} {
+		code, err := ioutil.ReadFile(filepath.Join("../../..", file))
+		assert.NoError(t, err)
+		if file == "contracts/MultiSigFlowToken.cdc" {
+			code = append(code, "\n// updated\n"...)
+		}
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(root, file), code, 0644))
+	}
+
+	logs := []string{}
+	d := &deploy.Deployer{G: e.GoWithTheFlow(), Root: root, Logf: func(format string, args ...interface{}) {
+		logs = append(logs, fmt.Sprintf(format, args...))
+	}}
+	updated, err := d.Deploy(Network, m[Network], nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"OnChainMultiSig is up to date at 01cf0e2f2f715450",
+		"updated MultiSigFlowToken at 01cf0e2f2f715450",
+	}, logs)
+
+	c, err := e.Client()
+	assert.NoError(t, err)
+	defer c.Close()
+	owner, err := c.GetAccount(context.Background(), e.GoWithTheFlow().Accounts[Owner].Address)
+	assert.NoError(t, err)
+	assert.Equal(t, deploy.Hash(owner.Contracts["MultiSigFlowToken"]), updated.Hashes["MultiSigFlowToken"])
+	assert.True(t, strings.HasSuffix(string(owner.Contracts["MultiSigFlowToken"]), "// updated\n"))
+}
@@ -0,0 +1,261 @@
+// Package encoder encodes multisig payloads into the signable data verified by
+// the `OnChainMultiSig` contract, see `OnChainMultiSig.encodeSignableValue`.
+//
+// The signable data is the Version byte followed by fields of the signable domain,
+// txIndex, method and args. Each field is encoded as its type tag, the big endian
+// uint32 length of its body and its body, so that no two different payloads have
+// the same encoding.
+package encoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"unicode/utf8"
+
+	"github.com/onflow/cadence"
+)
+
+// ErrPathNotSupported is returned for Path args, which `PayloadDetails` rejects
+// as their identifier cannot be read in Cadence to encode them
+var ErrPathNotSupported = errors.New("Path arguments are not supported")
+
+// ErrNotSignableData is returned by Validate for data that is not encoded as Encode encodes payloads
+var ErrNotSignableData = errors.New("not the signable data of a multisig payload")
+
+// Version of the encoding, the first byte of the signable data
+const Version byte = 1
+
+// DomainPrefix starts the signable domain of every resource, followed by the address of the contract,
+// the address of the owner of the resource and its uuid, see `OnChainMultiSig.getSignableDomain`
+const DomainPrefix = "FLOW-ONCHAIN-MULTISIG"
+
+const domainLength = len(DomainPrefix) + 8 + 8 + 8
+
+// Type tags of the encoded fields
+const (
+	TagDomain   byte = 1
+	TagString   byte = 2
+	TagUInt8    byte = 3
+	TagUInt64   byte = 4
+	TagUFix64   byte = 5
+	TagAddress  byte = 6
+	TagBool     byte = 7
+	TagInt      byte = 8
+	TagInt64    byte = 9
+	TagUInt32   byte = 10
+	TagFix64    byte = 11
+	TagArray    byte = 12
+	TagOptional byte = 13
+)
+
+// EncodeField encodes a field as its type tag, the big endian uint32 length of its body and its body
+func EncodeField(tag byte, body []byte) []byte {
+	b := make([]byte, 5, 5+len(body))
+	b[0] = tag
+	binary.BigEndian.PutUint32(b[1:], uint32(len(body)))
+	return append(b, body...)
+}
+
+// EncodeValue encodes a payload arg as a field, it errors on types the contract does not support
+//
+// Arrays are encoded as a field with their encoded elements as its body,
+// optionals as a field with an empty body for nil, and their encoded value otherwise
+func EncodeValue(v cadence.Value) ([]byte, error) {
+	switch v := v.(type) {
+	case cadence.String:
+		return EncodeField(TagString, []byte(v)), nil
+	case cadence.UInt8:
+		return EncodeField(TagUInt8, []byte{uint8(v)}), nil
+	case cadence.UInt64:
+		return EncodeField(TagUInt64, uint64Bytes(uint64(v))), nil
+	case cadence.UFix64:
+		return EncodeField(TagUFix64, uint64Bytes(uint64(v))), nil
+	case cadence.Address:
+		return EncodeField(TagAddress, v.Bytes()), nil
+	case cadence.Bool:
+		if v {
+			return EncodeField(TagBool, []byte{1}), nil
+		}
+		return EncodeField(TagBool, []byte{0}), nil
+	case cadence.Int:
+		return EncodeField(TagInt, signedBigIntBytes(v.Big())), nil
+	case cadence.Int64:
+		return EncodeField(TagInt64, uint64Bytes(uint64(v))), nil
+	case cadence.UInt32:
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, uint32(v))
+		return EncodeField(TagUInt32, b), nil
+	case cadence.Fix64:
+		return EncodeField(TagFix64, uint64Bytes(uint64(v))), nil
+	case cadence.Array:
+		var body []byte
+		for _, e := range v.Values {
+			b, err := EncodeValue(e)
+			if err != nil {
+				return nil, err
+			}
+			body = append(body, b...)
+		}
+		return EncodeField(TagArray, body), nil
+	case cadence.Optional:
+		if v.Value == nil {
+			return EncodeField(TagOptional, []byte{}), nil
+		}
+		b, err := EncodeValue(v.Value)
+		if err != nil {
+			return nil, err
+		}
+		return EncodeField(TagOptional, b), nil
+	case cadence.Path:
+		return nil, ErrPathNotSupported
+	default:
+		return nil, fmt.Errorf("payload arg type %T not supported", v)
+	}
+}
+
+// Encode returns the signable data of a payload for the resource with the signable `domain`,
+// see `OnChainMultiSig.getSignableDomain`
+func Encode(domain []byte, txIndex uint64, method string, args ...cadence.Value) ([]byte, error) {
+	signable := []byte{Version}
+	signable = append(signable, EncodeField(TagDomain, domain)...)
+	signable = append(signable, EncodeField(TagUInt64, uint64Bytes(txIndex))...)
+	signable = append(signable, EncodeField(TagString, []byte(method))...)
+	for _, arg := range args {
+		b, err := EncodeValue(arg)
+		if err != nil {
+			return nil, err
+		}
+		signable = append(signable, b...)
+	}
+	return signable, nil
+}
+
+// Validate returns an error if `signable` is not the signable data of a payload as Encode encodes it,
+// so that signers can refuse to sign any other message
+func Validate(signable []byte) error {
+	if len(signable) == 0 || signable[0] != Version {
+		return fmt.Errorf("%w: unsupported version", ErrNotSignableData)
+	}
+	tag, domain, rest, err := nextField(signable[1:])
+	if err != nil {
+		return err
+	}
+	if tag != TagDomain || len(domain) != domainLength || !bytes.HasPrefix(domain, []byte(DomainPrefix)) {
+		return fmt.Errorf("%w: invalid domain", ErrNotSignableData)
+	}
+	tag, txIndex, rest, err := nextField(rest)
+	if err != nil {
+		return err
+	}
+	if tag != TagUInt64 || len(txIndex) != 8 {
+		return fmt.Errorf("%w: invalid txIndex", ErrNotSignableData)
+	}
+	tag, method, rest, err := nextField(rest)
+	if err != nil {
+		return err
+	}
+	if tag != TagString || len(method) == 0 || !utf8.Valid(method) {
+		return fmt.Errorf("%w: invalid method", ErrNotSignableData)
+	}
+	return validateFields(rest)
+}
+
+// nextField splits the first field of `b` into its tag and body
+func nextField(b []byte) (tag byte, body []byte, rest []byte, err error) {
+	if len(b) < 5 {
+		return 0, nil, nil, fmt.Errorf("%w: truncated field", ErrNotSignableData)
+	}
+	n := binary.BigEndian.Uint32(b[1:5])
+	if uint64(len(b)-5) < uint64(n) {
+		return 0, nil, nil, fmt.Errorf("%w: truncated field", ErrNotSignableData)
+	}
+	return b[0], b[5 : 5+n], b[5+n:], nil
+}
+
+// validateFields returns an error if `b` is not a sequence of encoded values
+func validateFields(b []byte) error {
+	for len(b) > 0 {
+		tag, body, rest, err := nextField(b)
+		if err != nil {
+			return err
+		}
+		if err := validateValue(tag, body); err != nil {
+			return err
+		}
+		b = rest
+	}
+	return nil
+}
+
+// validateValue returns an error if `body` is not the body EncodeValue encodes values with `tag` with
+func validateValue(tag byte, body []byte) error {
+	valid := true
+	switch tag {
+	case TagString:
+		valid = utf8.Valid(body)
+	case TagUInt8:
+		valid = len(body) == 1
+	case TagBool:
+		valid = len(body) == 1 && body[0] <= 1
+	case TagUInt32:
+		valid = len(body) == 4
+	case TagUInt64, TagUFix64, TagAddress, TagInt64, TagFix64:
+		valid = len(body) == 8
+	case TagInt:
+		valid = len(body) > 0
+	case TagArray:
+		return validateFields(body)
+	case TagOptional:
+		if len(body) == 0 {
+			return nil
+		}
+		tag, inner, rest, err := nextField(body)
+		if err != nil {
+			return err
+		}
+		if len(rest) > 0 {
+			return fmt.Errorf("%w: optional with several values", ErrNotSignableData)
+		}
+		return validateValue(tag, inner)
+	default:
+		valid = false
+	}
+	if !valid {
+		return fmt.Errorf("%w: invalid field with tag %d", ErrNotSignableData, tag)
+	}
+	return nil
+}
+
+func uint64Bytes(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+// signedBigIntBytes returns the minimal big endian two's complement bytes of `i`,
+// the same as `Int.toBigEndianBytes` in Cadence
+func signedBigIntBytes(i *big.Int) []byte {
+	switch i.Sign() {
+	case 0:
+		return []byte{0}
+	case 1:
+		b := i.Bytes()
+		if b[0]&0x80 != 0 {
+			return append([]byte{0}, b...)
+		}
+		return b
+	default:
+		// -i - 1 with its bits inverted is the two's complement of i
+		b := new(big.Int).Sub(new(big.Int).Neg(i), big.NewInt(1)).Bytes()
+		for j := range b {
+			b[j] ^= 0xff
+		}
+		if len(b) == 0 || b[0]&0x80 == 0 {
+			return append([]byte{0xff}, b...)
+		}
+		return b
+	}
+}
@@ -0,0 +1,164 @@
+package encoder
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/stretchr/testify/assert"
+)
+
+var domain = []byte("FLOW-ONCHAIN-MULTISIG")
+
+// legacyEncode is the encoding used before Version 1, which concatenated the fields
+func legacyEncode(txIndex uint64, method string, args ...string) []byte {
+	b := uint64Bytes(txIndex)
+	b = append(b, method...)
+	for _, arg := range args {
+		b = append(b, arg...)
+	}
+	return b
+}
+
+func TestEncodeField(t *testing.T) {
+	assert.Equal(t, []byte{TagString, 0, 0, 0, 3, 'a', 'b', 'c'}, EncodeField(TagString, []byte("abc")))
+	assert.Equal(t, []byte{TagString, 0, 0, 0, 0}, EncodeField(TagString, []byte{}))
+}
+
+func TestEncodeStartsWithVersion(t *testing.T) {
+	b, err := Encode(domain, 1, "removeKey", cadence.String("abcd"))
+	assert.NoError(t, err)
+	assert.Equal(t, Version, b[0])
+	assert.True(t, bytes.HasPrefix(b[1:], EncodeField(TagDomain, domain)))
+}
+
+func TestMethodAndArgBoundaryCannotCollide(t *testing.T) {
+	assert.Equal(t, legacyEncode(1, "removeKey", "abcd"), legacyEncode(1, "removeKeyab", "cd"))
+
+	a, err := Encode(domain, 1, "removeKey", cadence.String("abcd"))
+	assert.NoError(t, err)
+	b, err := Encode(domain, 1, "removeKeyab", cadence.String("cd"))
+	assert.NoError(t, err)
+	assert.NotEqual(t, a, b)
+}
+
+func TestStringArgsBoundaryCannotCollide(t *testing.T) {
+	assert.Equal(t, legacyEncode(1, "configureKey", "ab", "cd"), legacyEncode(1, "configureKey", "abc", "d"))
+
+	a, err := Encode(domain, 1, "configureKey", cadence.String("ab"), cadence.String("cd"))
+	assert.NoError(t, err)
+	b, err := Encode(domain, 1, "configureKey", cadence.String("abc"), cadence.String("d"))
+	assert.NoError(t, err)
+	assert.NotEqual(t, a, b)
+}
+
+func TestSameBytesOfDifferentTypesCannotCollide(t *testing.T) {
+	a, err := Encode(domain, 1, "removePayload", cadence.UInt64(1))
+	assert.NoError(t, err)
+	b, err := Encode(domain, 1, "removePayload", cadence.UFix64(1))
+	assert.NoError(t, err)
+	assert.NotEqual(t, a, b)
+}
+
+func TestEncodeInt(t *testing.T) {
+	cases := map[int64][]byte{
+		0:    {0},
+		1:    {1},
+		127:  {127},
+		128:  {0, 128},
+		256:  {1, 0},
+		-1:   {0xff},
+		-128: {0x80},
+		-129: {0xff, 0x7f},
+	}
+	for i, body := range cases {
+		b, err := EncodeValue(cadence.NewInt(int(i)))
+		assert.NoError(t, err)
+		assert.Equal(t, EncodeField(TagInt, body), b, i)
+	}
+}
+
+func TestArrayBoundaryCannotCollide(t *testing.T) {
+	a, err := Encode(domain, 1, "m",
+		cadence.NewArray([]cadence.Value{cadence.String("a"), cadence.String("b")}),
+		cadence.NewArray([]cadence.Value{}),
+	)
+	assert.NoError(t, err)
+	b, err := Encode(domain, 1, "m",
+		cadence.NewArray([]cadence.Value{cadence.String("a")}),
+		cadence.NewArray([]cadence.Value{cadence.String("b")}),
+	)
+	assert.NoError(t, err)
+	assert.NotEqual(t, a, b)
+}
+
+func TestNilIsNotAnEmptyValue(t *testing.T) {
+	nilString, err := EncodeValue(cadence.NewOptional(nil))
+	assert.NoError(t, err)
+	emptyString, err := EncodeValue(cadence.NewOptional(cadence.String("")))
+	assert.NoError(t, err)
+	assert.NotEqual(t, nilString, emptyString)
+	assert.Equal(t, EncodeField(TagOptional, EncodeField(TagString, []byte{})), emptyString)
+}
+
+func TestUnsupportedTypeErrors(t *testing.T) {
+	unsupported := []cadence.Value{
+		cadence.Path{Domain: "storage", Identifier: "vault"},
+		cadence.Int32(1),
+		cadence.NewArray([]cadence.Value{cadence.String("a"), cadence.Int16(1)}),
+		cadence.NewOptional(cadence.UInt16(1)),
+	}
+	for _, v := range unsupported {
+		_, err := Encode(domain, 1, "transfer", v)
+		assert.Error(t, err)
+	}
+}
+
+func TestPathErrors(t *testing.T) {
+	path := cadence.Path{Domain: "public", Identifier: "vaultReceive"}
+	for _, v := range []cadence.Value{
+		path,
+		cadence.NewArray([]cadence.Value{cadence.String("a"), path}),
+		cadence.NewOptional(path),
+	} {
+		_, err := Encode(domain, 1, "transfer", v)
+		assert.ErrorIs(t, err, ErrPathNotSupported)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	resourceDomain := append([]byte(DomainPrefix), make([]byte, 24)...)
+	valid, err := Encode(resourceDomain, 1, "transfer",
+		cadence.UFix64(100000000),
+		cadence.NewAddress([8]byte{0, 0, 0, 0, 0, 0, 0, 1}),
+		cadence.NewInt(-129),
+		cadence.NewArray([]cadence.Value{cadence.String("a"), cadence.NewBool(true)}),
+		cadence.NewOptional(cadence.UInt8(1)),
+		cadence.NewOptional(nil),
+	)
+	assert.NoError(t, err)
+	assert.NoError(t, Validate(valid))
+
+	withDomain := func(d []byte) []byte {
+		b, err := Encode(d, 1, "transfer")
+		assert.NoError(t, err)
+		return b
+	}
+	invalid := map[string][]byte{
+		"empty":              {},
+		"other version":      append([]byte{Version + 1}, valid[1:]...),
+		"transaction":        append([]byte("FLOW-V0.0-transaction"), valid...),
+		"short domain":       withDomain(domain),
+		"other domain":       withDomain(append([]byte("FLOW-ONCHAIN-MULTISIH"), make([]byte, 24)...)),
+		"truncated":          valid[:len(valid)-1],
+		"trailing byte":      append(append([]byte{}, valid...), 0),
+		"unknown tag":        append(append([]byte{}, valid...), EncodeField(TagOptional+1, nil)...),
+		"invalid bool":       append(append([]byte{}, valid...), EncodeField(TagBool, []byte{2})...),
+		"short uint64":       append(append([]byte{}, valid...), EncodeField(TagUInt64, []byte{1})...),
+		"optional of two":    append(append([]byte{}, valid...), EncodeField(TagOptional, append(EncodeField(TagUInt8, []byte{1}), EncodeField(TagUInt8, []byte{1})...))...),
+		"array of a partial": append(append([]byte{}, valid...), EncodeField(TagArray, []byte{TagUInt8, 0})...),
+	}
+	for name, b := range invalid {
+		assert.ErrorIs(t, Validate(b), ErrNotSignableData, name)
+	}
+}
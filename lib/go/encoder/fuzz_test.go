@@ -0,0 +1,133 @@
+//go:build go1.18
+// +build go1.18
+
+package encoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/stretchr/testify/assert"
+)
+
+type field struct {
+	tag  byte
+	body []byte
+}
+
+// splitFields splits encoded fields, it errors if `b` is not a sequence of whole fields
+func splitFields(b []byte) (fields []field, err error) {
+	for len(b) > 0 {
+		if len(b) < 5 {
+			return nil, errors.New("truncated field header")
+		}
+		n := binary.BigEndian.Uint32(b[1:5])
+		if uint64(len(b)-5) < uint64(n) {
+			return nil, errors.New("truncated field body")
+		}
+		fields = append(fields, field{tag: b[0], body: b[5 : 5+n]})
+		b = b[5+n:]
+	}
+	return
+}
+
+// fromSignedBigIntBytes decodes big endian two's complement bytes
+func fromSignedBigIntBytes(b []byte) *big.Int {
+	i := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		i.Sub(i, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+	}
+	return i
+}
+
+// isMinimal returns true if no leading byte of `b` can be dropped without changing its two's complement value
+func isMinimal(b []byte) bool {
+	if len(b) < 2 {
+		return len(b) == 1
+	}
+	return !(b[0] == 0 && b[1]&0x80 == 0) && !(b[0] == 0xff && b[1]&0x80 != 0)
+}
+
+func FuzzEncode(f *testing.F) {
+	f.Add(domain, uint64(1), "transfer", "abcd", int64(-129), uint64(1550000000), true)
+	f.Add([]byte{}, uint64(0), "", "", int64(0), uint64(0), false)
+	f.Add([]byte{0, 0, 0, 5}, ^uint64(0), "removeKey", "\x00\x00\x00\x05", int64(-1<<63), ^uint64(0), true)
+
+	f.Fuzz(func(t *testing.T, domain []byte, txIndex uint64, method string, s string, n int64, u uint64, b bool) {
+		args := []cadence.Value{
+			cadence.String(s),
+			cadence.NewInt64(n),
+			cadence.NewInt(int(n)),
+			cadence.UFix64(u),
+			cadence.UInt64(u),
+			cadence.NewBool(b),
+			cadence.NewArray([]cadence.Value{cadence.String(s), cadence.UInt64(u)}),
+			cadence.NewOptional(cadence.String(s)),
+			cadence.NewOptional(nil),
+		}
+		encoded, err := Encode(domain, txIndex, method, args...)
+		assert.NoError(t, err)
+		assert.Equal(t, Version, encoded[0])
+
+		fields, err := splitFields(encoded[1:])
+		if !assert.NoError(t, err) || !assert.Len(t, fields, 3+len(args)) {
+			return
+		}
+		assert.Equal(t, field{TagDomain, domain}, fields[0])
+		assert.Equal(t, field{TagUInt64, uint64Bytes(txIndex)}, fields[1])
+		assert.Equal(t, field{TagString, []byte(method)}, fields[2])
+		for i, arg := range args {
+			e, err := EncodeValue(arg)
+			assert.NoError(t, err)
+			assert.Equal(t, e, EncodeField(fields[3+i].tag, fields[3+i].body))
+		}
+
+		intBody := fields[5].body
+		assert.Equal(t, big.NewInt(n).String(), fromSignedBigIntBytes(intBody).String())
+		assert.True(t, isMinimal(intBody), "%x is not minimal", intBody)
+
+		elements, err := splitFields(fields[9].body)
+		assert.NoError(t, err)
+		assert.Equal(t, []field{{TagString, []byte(s)}, {TagUInt64, uint64Bytes(u)}}, elements)
+		assert.Empty(t, fields[11].body)
+	})
+}
+
+// FuzzEncodeIsInjective checks that different payloads never have the same signable data
+func FuzzEncodeIsInjective(f *testing.F) {
+	f.Add("removeKey", "abcd", uint64(1), "removeKeyab", "cd", uint64(1))
+	f.Add("configureKey", "", uint64(0), "configureKe", "y", uint64(0))
+
+	f.Fuzz(func(t *testing.T, method1 string, s1 string, u1 uint64, method2 string, s2 string, u2 uint64) {
+		a, err := Encode(domain, 1, method1, cadence.String(s1), cadence.UFix64(u1))
+		assert.NoError(t, err)
+		b, err := Encode(domain, 1, method2, cadence.String(s2), cadence.UFix64(u2))
+		assert.NoError(t, err)
+		same := method1 == method2 && s1 == s2 && u1 == u2
+		assert.Equal(t, same, bytes.Equal(a, b))
+
+		// Values of different types with the same bytes
+		c, err := Encode(domain, 1, method1, cadence.String(s1), cadence.UInt64(u1))
+		assert.NoError(t, err)
+		assert.NotEqual(t, a, c)
+	})
+}
+
+func FuzzSignedBigIntBytes(f *testing.F) {
+	f.Add(int64(0), uint64(0))
+	f.Add(int64(-1), uint64(0))
+	f.Add(int64(0), uint64(128))
+	f.Add(int64(-1), ^uint64(127))
+
+	f.Fuzz(func(t *testing.T, hi int64, lo uint64) {
+		i := new(big.Int).Lsh(big.NewInt(hi), 64)
+		i.Add(i, new(big.Int).SetUint64(lo))
+		b := signedBigIntBytes(i)
+		assert.Equal(t, i.String(), fromSignedBigIntBytes(b).String())
+		assert.True(t, isMinimal(b), "%x is not minimal", b)
+	})
+}
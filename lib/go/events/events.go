@@ -0,0 +1,229 @@
+// Package events decodes the events of the `OnChainMultiSig` contract,
+// and the token events of the `MultiSigFlowToken` contract with DecodeToken.
+//
+// The decoders read the fields of an event by name, so they do not depend on the order of the fields.
+package events
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+)
+
+var ErrUnknownEvent = errors.New("not an OnChainMultiSig or MultiSigFlowToken event")
+
+type NewPayloadAdded struct {
+	ResourceID uint64
+	TxIndex    uint64
+	Method     string
+	PublicKey  string
+}
+
+type NewPayloadSigAdded struct {
+	ResourceID uint64
+	TxIndex    uint64
+	Method     string
+	PublicKey  string
+}
+
+type PayloadExecuted struct {
+	ResourceID uint64
+	TxIndex    uint64
+	Method     string
+	// Signers are the keys that contributed to the approval weight
+	Signers []string
+	Weight  cadence.UFix64
+}
+
+type PayloadRemoved struct {
+	ResourceID uint64
+	TxIndex    uint64
+	Method     string
+}
+
+type KeyConfigured struct {
+	ResourceID uint64
+	PublicKey  string
+	Weight     cadence.UFix64
+	SigAlgo    uint8
+}
+
+type KeyRemoved struct {
+	ResourceID uint64
+	PublicKey  string
+}
+
+type TokensWithdrawn struct {
+	Amount cadence.UFix64
+	// From is the account of the vault, nil if the vault is not stored
+	From *flow.Address
+}
+
+type TokensDeposited struct {
+	Amount cadence.UFix64
+	// To is the account of the vault, nil if the vault is not stored
+	To *flow.Address
+}
+
+// Decode decodes an event of the `OnChainMultiSig` contract at `multiSigAddr`
+// to the struct of this package with the name of the event,
+// it returns ErrUnknownEvent for other events
+func Decode(multiSigAddr flow.Address, event flow.Event) (interface{}, error) {
+	prefix := fmt.Sprintf("A.%s.OnChainMultiSig.", multiSigAddr.Hex())
+	if !strings.HasPrefix(event.Type, prefix) {
+		return nil, ErrUnknownEvent
+	}
+	f := fields(event)
+	var decoded interface{}
+	switch strings.TrimPrefix(event.Type, prefix) {
+	case "NewPayloadAdded":
+		decoded = NewPayloadAdded{
+			ResourceID: f.uint64("resourceId"),
+			TxIndex:    f.uint64("txIndex"),
+			Method:     f.string("method"),
+			PublicKey:  f.string("publicKey"),
+		}
+	case "NewPayloadSigAdded":
+		decoded = NewPayloadSigAdded{
+			ResourceID: f.uint64("resourceId"),
+			TxIndex:    f.uint64("txIndex"),
+			Method:     f.string("method"),
+			PublicKey:  f.string("publicKey"),
+		}
+	case "PayloadExecuted":
+		decoded = PayloadExecuted{
+			ResourceID: f.uint64("resourceId"),
+			TxIndex:    f.uint64("txIndex"),
+			Method:     f.string("method"),
+			Signers:    f.strings("signers"),
+			Weight:     f.ufix64("weight"),
+		}
+	case "PayloadRemoved":
+		decoded = PayloadRemoved{
+			ResourceID: f.uint64("resourceId"),
+			TxIndex:    f.uint64("txIndex"),
+			Method:     f.string("method"),
+		}
+	case "KeyConfigured":
+		decoded = KeyConfigured{
+			ResourceID: f.uint64("resourceId"),
+			PublicKey:  f.string("publicKey"),
+			Weight:     f.ufix64("weight"),
+			SigAlgo:    f.uint8("sigAlgo"),
+		}
+	case "KeyRemoved":
+		decoded = KeyRemoved{
+			ResourceID: f.uint64("resourceId"),
+			PublicKey:  f.string("publicKey"),
+		}
+	default:
+		return nil, ErrUnknownEvent
+	}
+	if f.err != nil {
+		return nil, fmt.Errorf("could not decode %s: %w", event.Type, f.err)
+	}
+	return decoded, nil
+}
+
+// DecodeToken decodes a `TokensWithdrawn` or `TokensDeposited` event of the `MultiSigFlowToken` contract
+// at `tokenAddr`, it returns ErrUnknownEvent for other events
+func DecodeToken(tokenAddr flow.Address, event flow.Event) (interface{}, error) {
+	prefix := fmt.Sprintf("A.%s.MultiSigFlowToken.", tokenAddr.Hex())
+	if !strings.HasPrefix(event.Type, prefix) {
+		return nil, ErrUnknownEvent
+	}
+	f := fields(event)
+	var decoded interface{}
+	switch strings.TrimPrefix(event.Type, prefix) {
+	case "TokensWithdrawn":
+		decoded = TokensWithdrawn{
+			Amount: f.ufix64("amount"),
+			From:   f.optionalAddress("from"),
+		}
+	case "TokensDeposited":
+		decoded = TokensDeposited{
+			Amount: f.ufix64("amount"),
+			To:     f.optionalAddress("to"),
+		}
+	default:
+		return nil, ErrUnknownEvent
+	}
+	if f.err != nil {
+		return nil, fmt.Errorf("could not decode %s: %w", event.Type, f.err)
+	}
+	return decoded, nil
+}
+
+// eventFields are the fields of an event by name,
+// the accessors record an error instead of panicking if a field is missing or has another type
+type eventFields struct {
+	values map[string]cadence.Value
+	err    error
+}
+
+func fields(event flow.Event) *eventFields {
+	f := &eventFields{values: map[string]cadence.Value{}}
+	for i, field := range event.Value.EventType.Fields {
+		if i < len(event.Value.Fields) {
+			f.values[field.Identifier] = event.Value.Fields[i]
+		}
+	}
+	return f
+}
+
+func (f *eventFields) check(name string, v interface{}, ok bool) {
+	if !ok && f.err == nil {
+		f.err = fmt.Errorf("field %s is missing or is not a %T", name, v)
+	}
+}
+
+func (f *eventFields) uint64(name string) uint64 {
+	v, ok := f.values[name].(cadence.UInt64)
+	f.check(name, v, ok)
+	return uint64(v)
+}
+
+func (f *eventFields) uint8(name string) uint8 {
+	v, ok := f.values[name].(cadence.UInt8)
+	f.check(name, v, ok)
+	return uint8(v)
+}
+
+func (f *eventFields) ufix64(name string) cadence.UFix64 {
+	v, ok := f.values[name].(cadence.UFix64)
+	f.check(name, v, ok)
+	return v
+}
+
+func (f *eventFields) optionalAddress(name string) *flow.Address {
+	v, ok := f.values[name].(cadence.Optional)
+	f.check(name, v, ok)
+	if v.Value == nil {
+		return nil
+	}
+	address, ok := v.Value.(cadence.Address)
+	f.check(name, address, ok)
+	a := flow.BytesToAddress(address.Bytes())
+	return &a
+}
+
+func (f *eventFields) string(name string) string {
+	v, ok := f.values[name].(cadence.String)
+	f.check(name, v, ok)
+	return string(v)
+}
+
+func (f *eventFields) strings(name string) []string {
+	v, ok := f.values[name].(cadence.Array)
+	f.check(name, v, ok)
+	s := []string{}
+	for _, e := range v.Values {
+		str, ok := e.(cadence.String)
+		f.check(name, str, ok)
+		s = append(s, string(str))
+	}
+	return s
+}
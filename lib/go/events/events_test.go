@@ -0,0 +1,114 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+var multiSigAddr = flow.HexToAddress("01cf0e2f2f715450")
+
+// newEvent returns an event of type `typ` with the fields in `names` and `values`
+func newEvent(typ string, names []string, values ...cadence.Value) flow.Event {
+	fields := []cadence.Field{}
+	for _, name := range names {
+		fields = append(fields, cadence.Field{Identifier: name})
+	}
+	return flow.Event{
+		Type:  typ,
+		Value: cadence.NewEvent(values).WithType(&cadence.EventType{Fields: fields}),
+	}
+}
+
+func TestDecodePayloadExecuted(t *testing.T) {
+	weight, err := cadence.NewUFix64("1500.0")
+	assert.NoError(t, err)
+	event := newEvent(
+		"A.01cf0e2f2f715450.OnChainMultiSig.PayloadExecuted",
+		[]string{"resourceId", "txIndex", "method", "signers", "weight"},
+		cadence.UInt64(12),
+		cadence.UInt64(3),
+		cadence.String("transfer"),
+		cadence.NewArray([]cadence.Value{cadence.String("aa"), cadence.String("bb")}),
+		weight,
+	)
+
+	decoded, err := Decode(multiSigAddr, event)
+	assert.NoError(t, err)
+	assert.Equal(t, PayloadExecuted{
+		ResourceID: 12,
+		TxIndex:    3,
+		Method:     "transfer",
+		Signers:    []string{"aa", "bb"},
+		Weight:     weight,
+	}, decoded)
+}
+
+func TestDecodeReadsFieldsByName(t *testing.T) {
+	event := newEvent(
+		"A.01cf0e2f2f715450.OnChainMultiSig.KeyRemoved",
+		[]string{"publicKey", "resourceId"},
+		cadence.String("aa"),
+		cadence.UInt64(12),
+	)
+
+	decoded, err := Decode(multiSigAddr, event)
+	assert.NoError(t, err)
+	assert.Equal(t, KeyRemoved{ResourceID: 12, PublicKey: "aa"}, decoded)
+}
+
+func TestDecodeRejectsOtherEvents(t *testing.T) {
+	fields := []string{"resourceId", "txIndex"}
+
+	// The same event of a contract at another address
+	_, err := Decode(multiSigAddr, newEvent("A.f8d6e0586b0a20c7.OnChainMultiSig.PayloadRemoved", fields))
+	assert.ErrorIs(t, err, ErrUnknownEvent)
+
+	_, err = Decode(multiSigAddr, newEvent("A.01cf0e2f2f715450.MultiSigFlowToken.TokensDeposited", fields))
+	assert.ErrorIs(t, err, ErrUnknownEvent)
+}
+
+func TestDecodeMissingFieldErrors(t *testing.T) {
+	event := newEvent(
+		"A.01cf0e2f2f715450.OnChainMultiSig.PayloadRemoved",
+		[]string{"resourceId", "txIndex"},
+		cadence.UInt64(12),
+		cadence.UInt64(3),
+	)
+
+	_, err := Decode(multiSigAddr, event)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrUnknownEvent)
+}
+
+func TestDecodeToken(t *testing.T) {
+	amount, err := cadence.NewUFix64("2.5")
+	assert.NoError(t, err)
+	owner := flow.HexToAddress("179b6b1cb6755e31")
+	withdrawn := newEvent(
+		"A.01cf0e2f2f715450.MultiSigFlowToken.TokensWithdrawn",
+		[]string{"amount", "from"},
+		amount,
+		cadence.NewOptional(cadence.BytesToAddress(owner.Bytes())),
+	)
+	decoded, err := DecodeToken(multiSigAddr, withdrawn)
+	assert.NoError(t, err)
+	assert.Equal(t, TokensWithdrawn{Amount: amount, From: &owner}, decoded)
+
+	deposited := newEvent(
+		"A.01cf0e2f2f715450.MultiSigFlowToken.TokensDeposited",
+		[]string{"amount", "to"},
+		amount,
+		cadence.NewOptional(nil),
+	)
+	decoded, err = DecodeToken(multiSigAddr, deposited)
+	assert.NoError(t, err)
+	assert.Equal(t, TokensDeposited{Amount: amount}, decoded)
+
+	_, err = DecodeToken(multiSigAddr, newEvent("A.01cf0e2f2f715450.OnChainMultiSig.KeyRemoved", nil))
+	assert.ErrorIs(t, err, ErrUnknownEvent)
+	_, err = Decode(multiSigAddr, withdrawn)
+	assert.ErrorIs(t, err, ErrUnknownEvent)
+}
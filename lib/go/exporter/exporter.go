@@ -0,0 +1,321 @@
+// Package exporter exposes Prometheus metrics of multisig vaults.
+//
+// Each Poll queries the pending payloads, signer keys and balance of the configured vaults,
+// and scans the blocks sealed since the last poll for executions of their payloads:
+// a `PayloadExecuted` event of a vault is a successful execution,
+// and a failed `executeTx.cdc` transaction for a vault is a failed execution.
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync"
+
+	"github.com/bjartek/go-with-the-flow/gwtf"
+	util "github.com/flow-hydraulics/onchain-multisig"
+	"github.com/flow-hydraulics/onchain-multisig/events"
+	"github.com/onflow/cadence"
+	jsoncdc "github.com/onflow/cadence/encoding/json"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/client"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+type Exporter struct {
+	g            *gwtf.GoWithTheFlow
+	client       *client.Client
+	root         string
+	multiSigAddr flow.Address
+	vaults       []flow.Address
+
+	mu sync.Mutex
+	// uuids are the uuids of the resources of the vaults, to match `PayloadExecuted` events
+	uuids           map[flow.Address]uint64
+	executeTxScript []byte
+	// lastHeight is the last block scanned for executions, 0 before the first poll
+	lastHeight uint64
+
+	registry      *prometheus.Registry
+	pending       *prometheus.GaugeVec
+	oldestPending *prometheus.GaugeVec
+	payloadWeight *prometheus.GaugeVec
+	signerKeys    *prometheus.GaugeVec
+	signerWeight  *prometheus.GaugeVec
+	balance       *prometheus.GaugeVec
+	executions    *prometheus.CounterVec
+	pollErrors    prometheus.Counter
+}
+
+// New returns an exporter of the metrics of `vaults`, which use the `OnChainMultiSig` contract at `multiSigAddr`,
+// `root` is the path to the root of this repository, where the scripts and transactions are
+func New(g *gwtf.GoWithTheFlow, c *client.Client, multiSigAddr flow.Address, root string, vaults []flow.Address) *Exporter {
+	vaultLabel := []string{"vault"}
+	e := &Exporter{
+		g:               g,
+		client:          c,
+		root:            root,
+		multiSigAddr:    multiSigAddr,
+		vaults:          vaults,
+		uuids:           map[flow.Address]uint64{},
+		executeTxScript: util.ParseCadenceTemplate(filepath.Join(root, "transactions", "executeTx.cdc")),
+		registry:        prometheus.NewRegistry(),
+		pending: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "multisig_pending_payloads",
+			Help: "Number of payloads that have not been executed or removed.",
+		}, vaultLabel),
+		oldestPending: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "multisig_oldest_pending_payload_age_blocks",
+			Help: "Blocks since the oldest pending payload was added, 0 without pending payloads.",
+		}, vaultLabel),
+		payloadWeight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "multisig_pending_payload_weight",
+			Help: "Approval weight of the signatures of a pending payload with the current key list.",
+		}, []string{"vault", "tx_index", "method"}),
+		signerKeys: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "multisig_signer_keys",
+			Help: "Number of registered signer keys.",
+		}, vaultLabel),
+		signerWeight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "multisig_signer_weight_total",
+			Help: "Total weight of the registered signer keys.",
+		}, vaultLabel),
+		balance: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "multisig_vault_balance",
+			Help: "Balance of the vault.",
+		}, vaultLabel),
+		executions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "multisig_executions_total",
+			Help: "Executions of payloads since the exporter started, by result.",
+		}, []string{"vault", "result"}),
+		pollErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "multisig_exporter_poll_errors_total",
+			Help: "Polls that failed to query the chain.",
+		}),
+	}
+	e.registry.MustRegister(
+		e.pending, e.oldestPending, e.payloadWeight, e.signerKeys, e.signerWeight, e.balance, e.executions, e.pollErrors,
+	)
+	return e
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (e *Exporter) Handler() http.Handler {
+	return promhttp.HandlerFor(e.registry, promhttp.HandlerOpts{})
+}
+
+// Poll updates the metrics of the vaults.
+// The first poll only records the latest sealed block, executions are counted from the blocks after it
+func (e *Exporter) Poll(ctx context.Context) (err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	defer func() {
+		if err != nil {
+			e.pollErrors.Inc()
+		}
+	}()
+
+	latest, err := e.client.GetLatestBlockHeader(ctx, true)
+	if err != nil {
+		return err
+	}
+	e.payloadWeight.Reset()
+	for _, vault := range e.vaults {
+		if err := e.pollVault(vault, latest.Height); err != nil {
+			return fmt.Errorf("could not poll vault %s: %w", vault, err)
+		}
+	}
+
+	if e.lastHeight == 0 {
+		e.lastHeight = latest.Height
+		return nil
+	}
+	for height := e.lastHeight + 1; height <= latest.Height; height++ {
+		if err := e.scanBlock(ctx, height); err != nil {
+			return err
+		}
+		e.lastHeight = height
+	}
+	return nil
+}
+
+func (e *Exporter) pollVault(vault flow.Address, height uint64) error {
+	label := vault.Hex()
+	if _, ok := e.uuids[vault]; !ok {
+		value, err := e.script("get_vault_uuid.cdc", vault, util.DefaultVaultPaths.Signer).RunReturns()
+		if err != nil {
+			return err
+		}
+		e.uuids[vault] = value.ToGoValue().(uint64)
+	}
+
+	payloads, err := e.getPendingPayloads(vault)
+	if err != nil {
+		return err
+	}
+	e.pending.WithLabelValues(label).Set(float64(len(payloads)))
+	oldest := uint64(0)
+	for _, p := range payloads {
+		// the scripts may run against a block after the latest sealed block,
+		// and payloads added before the height was recorded have no age
+		if p.AddedAt != nil && *p.AddedAt < height && height-*p.AddedAt > oldest {
+			oldest = height - *p.AddedAt
+		}
+		e.payloadWeight.WithLabelValues(label, fmt.Sprint(p.TxIndex), p.Method).Set(ufix64ToFloat(p.Weight))
+	}
+	e.oldestPending.WithLabelValues(label).Set(float64(oldest))
+
+	weights, err := e.script("get_signer_weights.cdc", vault, util.DefaultVaultPaths.Signer).RunReturns()
+	if err != nil {
+		return err
+	}
+	total := 0.0
+	dict, ok := weights.(cadence.Dictionary)
+	if !ok {
+		return errors.New("signer weights are not a dictionary")
+	}
+	for _, pair := range dict.Pairs {
+		total += ufix64ToFloat(pair.Value.(cadence.UFix64))
+	}
+	e.signerKeys.WithLabelValues(label).Set(float64(len(dict.Pairs)))
+	e.signerWeight.WithLabelValues(label).Set(total)
+
+	balance, err := e.script("get_balance.cdc", vault, util.DefaultVaultPaths.Balance).RunReturns()
+	if err != nil {
+		return err
+	}
+	e.balance.WithLabelValues(label).Set(ufix64ToFloat(balance.(cadence.UFix64)))
+	return nil
+}
+
+// PendingPayload mirrors `OnChainMultiSig.PayloadInfo`
+type PendingPayload struct {
+	TxIndex uint64
+	Method  string
+	AddedAt *uint64
+	Weight  cadence.UFix64
+}
+
+func (e *Exporter) getPendingPayloads(vault flow.Address) ([]PendingPayload, error) {
+	value, err := e.script("get_pending_payloads.cdc", vault, util.DefaultVaultPaths.Signer).RunReturns()
+	if err != nil {
+		return nil, err
+	}
+	payloads := []PendingPayload{}
+	for _, v := range value.(cadence.Array).Values {
+		s := v.(cadence.Struct)
+		fields := map[string]cadence.Value{}
+		for i, f := range s.StructType.Fields {
+			fields[f.Identifier] = s.Fields[i]
+		}
+		p := PendingPayload{
+			TxIndex: uint64(fields["txIndex"].(cadence.UInt64)),
+			Method:  string(fields["method"].(cadence.String)),
+			Weight:  fields["weight"].(cadence.UFix64),
+		}
+		if addedAt := fields["addedAt"].(cadence.Optional).Value; addedAt != nil {
+			h := uint64(addedAt.(cadence.UInt64))
+			p.AddedAt = &h
+		}
+		payloads = append(payloads, p)
+	}
+	return payloads, nil
+}
+
+// scanBlock counts the executions of payloads of the vaults in the block at `height`
+func (e *Exporter) scanBlock(ctx context.Context, height uint64) error {
+	block, err := e.client.GetBlockByHeight(ctx, height)
+	if err != nil {
+		return err
+	}
+	for _, guarantee := range block.CollectionGuarantees {
+		collection, err := e.client.GetCollection(ctx, guarantee.CollectionID)
+		if err != nil {
+			return err
+		}
+		for _, id := range collection.TransactionIDs {
+			result, err := e.client.GetTransactionResult(ctx, id)
+			if err != nil {
+				return err
+			}
+			if result.Error == nil {
+				e.countExecutions(result.Events)
+				continue
+			}
+			tx, err := e.client.GetTransaction(ctx, id)
+			if err != nil {
+				return err
+			}
+			if vault, ok := e.executeTxVault(tx); ok {
+				e.executions.WithLabelValues(vault.Hex(), ResultFailure).Inc()
+			}
+		}
+	}
+	return nil
+}
+
+func (e *Exporter) countExecutions(txEvents []flow.Event) {
+	for _, event := range txEvents {
+		decoded, err := events.Decode(e.multiSigAddr, event)
+		if err != nil {
+			continue
+		}
+		executed, ok := decoded.(events.PayloadExecuted)
+		if !ok {
+			continue
+		}
+		for vault, uuid := range e.uuids {
+			if uuid == executed.ResourceID {
+				e.executions.WithLabelValues(vault.Hex(), ResultSuccess).Inc()
+			}
+		}
+	}
+}
+
+// executeTxVault returns the vault of an `executeTx.cdc` transaction, if it is one of the vaults
+func (e *Exporter) executeTxVault(tx *flow.Transaction) (flow.Address, bool) {
+	if !bytes.Equal(tx.Script, e.executeTxScript) || len(tx.Arguments) < 2 {
+		return flow.EmptyAddress, false
+	}
+	// transaction (multiSigVaultAddr: Address, signerPath: PublicPath, txIndex: UInt64),
+	// the vaults of the exporter are those at the default paths of their accounts
+	arg, err := jsoncdc.Decode(tx.Arguments[0])
+	if err != nil {
+		return flow.EmptyAddress, false
+	}
+	addr, ok := arg.(cadence.Address)
+	if !ok {
+		return flow.EmptyAddress, false
+	}
+	if path, err := jsoncdc.Decode(tx.Arguments[1]); err != nil || path != util.DefaultVaultPaths.Signer {
+		return flow.EmptyAddress, false
+	}
+	vault := flow.BytesToAddress(addr.Bytes())
+	for _, v := range e.vaults {
+		if v == vault {
+			return vault, true
+		}
+	}
+	return flow.EmptyAddress, false
+}
+
+// script returns the script `filename` with the arguments of the capability of `vault` at `capability`
+func (e *Exporter) script(filename string, vault flow.Address, capability cadence.Path) gwtf.FlowScriptBuilder {
+	path := filepath.Join(e.root, "scripts", filename)
+	return e.g.ScriptFromFile(path, util.ParseCadenceTemplate(path)).
+		Argument(cadence.BytesToAddress(vault.Bytes())).
+		Argument(capability)
+}
+
+func ufix64ToFloat(v cadence.UFix64) float64 {
+	return float64(v) / 1e8
+}
@@ -0,0 +1,79 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	util "github.com/flow-hydraulics/onchain-multisig"
+	"github.com/flow-hydraulics/onchain-multisig/vault"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/client"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+const vaultAcct = "vaulted-account"
+
+func TestExporterReportsVaultMetrics(t *testing.T) {
+	g := emu.GoWithTheFlow()
+	c, err := client.New(g.Address, grpc.WithInsecure())
+	assert.NoError(t, err)
+	defer c.Close()
+	vaultAddr := flow.HexToAddress(util.GetAccountAddr(g, vaultAcct))
+	label := vaultAddr.Hex()
+	e := New(g, c, g.Accounts["owner"].Address, "../../..", []flow.Address{vaultAddr})
+
+	assert.NoError(t, e.Poll(context.Background()))
+	initPending := testutil.ToFloat64(e.pending.WithLabelValues(label))
+	initBalance := testutil.ToFloat64(e.balance.WithLabelValues(label))
+	keys, err := util.GetStoreKeys(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(len(keys)), testutil.ToFloat64(e.signerKeys.WithLabelValues(label)))
+
+	txIndex, err := util.GetTxIndex(g, vaultAcct)
+	assert.NoError(t, err)
+	txIndex = txIndex + 1
+	_, err = vault.MultiSig_Transfer(g, "1.0", "owner", txIndex, vault.Acct500_2, vaultAcct, true)
+	assert.NoError(t, err)
+	// The payload is pending for at least a block
+	assert.NoError(t, util.AdvanceBlocks(g, 1, "owner"))
+
+	assert.NoError(t, e.Poll(context.Background()))
+	assert.Equal(t, initPending+1, testutil.ToFloat64(e.pending.WithLabelValues(label)))
+	assert.Greater(t, testutil.ToFloat64(e.oldestPending.WithLabelValues(label)), float64(0))
+	weight, err := util.GetKeyWeight(g, vaultAcct, vault.Acct500_2)
+	assert.NoError(t, err)
+	assert.Equal(t,
+		ufix64ToFloat(weight),
+		testutil.ToFloat64(e.payloadWeight.WithLabelValues(label, fmt.Sprint(txIndex), "transfer")),
+	)
+
+	_, err = vault.MultiSig_Transfer(g, "1.0", "owner", txIndex, vault.Acct1000, vaultAcct, false)
+	assert.NoError(t, err)
+	_, err = vault.MultiSig_VaultExecuteTx(g, txIndex, "owner", vaultAcct)
+	assert.NoError(t, err)
+	// The payload has been executed
+	_, err = vault.MultiSig_VaultExecuteTx(g, txIndex, "owner", vaultAcct)
+	assert.Error(t, err)
+
+	assert.NoError(t, e.Poll(context.Background()))
+	assert.Equal(t, initPending, testutil.ToFloat64(e.pending.WithLabelValues(label)))
+	assert.Equal(t, initBalance-1, testutil.ToFloat64(e.balance.WithLabelValues(label)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(e.executions.WithLabelValues(label, ResultSuccess)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(e.executions.WithLabelValues(label, ResultFailure)))
+
+	server := httptest.NewServer(e.Handler())
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), fmt.Sprintf(`multisig_executions_total{result="success",vault="%s"} 1`, label))
+	assert.Contains(t, string(body), fmt.Sprintf(`multisig_pending_payloads{vault="%s"} %v`, label, initPending))
+}
@@ -0,0 +1,279 @@
+package indexer
+
+import (
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/onflow/flow-go-sdk"
+)
+
+//go:embed schema.sql
+var schema string
+
+const (
+	StatusPending  = "pending"
+	StatusExecuted = "executed"
+	StatusRemoved  = "removed"
+)
+
+var ErrNotFound = errors.New("payload not found")
+
+// DB is the SQLite database of the indexed payloads, see schema.sql
+type DB struct {
+	db *sql.DB
+}
+
+// Open opens the database at `path`, creating the schema if it does not exist
+func Open(path string) (*DB, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &DB{db: db}, nil
+}
+
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// Cursor returns the last indexed block height, `ok` is false if no block has been indexed
+func (d *DB) Cursor() (height uint64, ok bool, err error) {
+	err = d.db.QueryRow("SELECT height FROM cursor WHERE id = 0").Scan(&height)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	return height, err == nil, err
+}
+
+// apply records the lifecycle records of the block at `height` and advances the cursor to it
+func (d *DB) apply(height uint64, records []Record) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, r := range records {
+		if err := applyRecord(tx, r); err != nil {
+			return fmt.Errorf("could not apply %s record of tx %s: %w", r.Kind, r.TxID, err)
+		}
+	}
+	_, err = tx.Exec("INSERT INTO cursor (id, height) VALUES (0, ?) ON CONFLICT (id) DO UPDATE SET height = excluded.height", height)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func applyRecord(tx *sql.Tx, r Record) error {
+	switch r.Kind {
+	case KindProposed:
+		args, err := json.Marshal(r.Args)
+		if err != nil {
+			return err
+		}
+		// The vault of a payload not added with add_new_payload.cdc is that of the earlier payloads of its resource
+		_, err = tx.Exec(
+			`INSERT OR IGNORE INTO payloads (resource_id, tx_index, vault, method, args, proposed_height, proposed_tx, status)
+			VALUES (?, ?, COALESCE(NULLIF(?, ''), (SELECT vault FROM payloads WHERE resource_id = ? AND vault != '' LIMIT 1), ''),
+			?, ?, ?, ?, ?)`,
+			r.ResourceID, r.TxIndex, vaultHex(r.Vault), r.ResourceID, r.Method, string(args), r.Height, r.TxID.String(), StatusPending,
+		)
+		return err
+	case KindSigned:
+		_, err := tx.Exec(
+			"INSERT OR IGNORE INTO signatures (resource_id, tx_index, public_key, height, tx) VALUES (?, ?, ?, ?, ?)",
+			r.ResourceID, r.TxIndex, r.PublicKey, r.Height, r.TxID.String(),
+		)
+		return err
+	case KindExecuted:
+		return closePayload(tx, StatusExecuted, r)
+	case KindRemoved:
+		return closePayload(tx, StatusRemoved, r)
+	case KindWithdrawn, KindDeposited:
+		var vault sql.NullString
+		if r.Vault != flow.EmptyAddress {
+			vault = sql.NullString{String: r.Vault.Hex(), Valid: true}
+		}
+		_, err := tx.Exec(
+			"INSERT OR IGNORE INTO vault_activity (tx, event_index, height, kind, vault, amount) VALUES (?, ?, ?, ?, ?, ?)",
+			r.TxID.String(), r.EventIndex, r.Height, string(r.Kind), vault, r.Amount.String(),
+		)
+		return err
+	}
+	return fmt.Errorf("unknown record kind %s", r.Kind)
+}
+
+// vaultHex returns the hex address of `vault`, or the empty string for the empty address
+func vaultHex(vault flow.Address) string {
+	if vault == flow.EmptyAddress {
+		return ""
+	}
+	return vault.Hex()
+}
+
+func closePayload(tx *sql.Tx, status string, r Record) error {
+	_, err := tx.Exec(
+		"UPDATE payloads SET status = ?, closed_height = ?, closed_tx = ? WHERE resource_id = ? AND tx_index = ?",
+		status, r.Height, r.TxID.String(), r.ResourceID, r.TxIndex,
+	)
+	return err
+}
+
+type Signature struct {
+	PublicKey string `json:"publicKey"`
+	Height    uint64 `json:"height"`
+	Tx        string `json:"tx"`
+}
+
+// Payload is the indexed lifecycle of a payload
+type Payload struct {
+	ResourceID     uint64            `json:"resourceId"`
+	TxIndex        uint64            `json:"txIndex"`
+	Vault          string            `json:"vault"`
+	Method         string            `json:"method"`
+	Args           []json.RawMessage `json:"args"`
+	ProposedHeight uint64            `json:"proposedHeight"`
+	ProposedTx     string            `json:"proposedTx"`
+	Status         string            `json:"status"`
+	ClosedHeight   *uint64           `json:"closedHeight,omitempty"`
+	ClosedTx       *string           `json:"closedTx,omitempty"`
+	Signatures     []Signature       `json:"signatures"`
+}
+
+const payloadColumns = "p.resource_id, p.tx_index, p.vault, p.method, p.args, p.proposed_height, p.proposed_tx, p.status, p.closed_height, p.closed_tx"
+
+// History returns the payloads of `vault` in the order they were proposed
+func (d *DB) History(vault flow.Address) ([]*Payload, error) {
+	return d.queryPayloads(
+		"SELECT "+payloadColumns+" FROM payloads p WHERE p.vault = ? ORDER BY p.proposed_height, p.tx_index",
+		vault.Hex(),
+	)
+}
+
+// SignedBy returns the payloads signed by `publicKey` in the order they were proposed
+func (d *DB) SignedBy(publicKey string) ([]*Payload, error) {
+	return d.queryPayloads(
+		"SELECT "+payloadColumns+` FROM payloads p JOIN signatures s
+		ON s.resource_id = p.resource_id AND s.tx_index = p.tx_index
+		WHERE s.public_key = ? ORDER BY p.proposed_height, p.tx_index`,
+		publicKey,
+	)
+}
+
+// Payload returns the latest payload at `txIndex` of `vault`, or ErrNotFound
+func (d *DB) Payload(vault flow.Address, txIndex uint64) (*Payload, error) {
+	payloads, err := d.queryPayloads(
+		"SELECT "+payloadColumns+" FROM payloads p WHERE p.vault = ? AND p.tx_index = ? ORDER BY p.proposed_height DESC LIMIT 1",
+		vault.Hex(), txIndex,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(payloads) == 0 {
+		return nil, ErrNotFound
+	}
+	return payloads[0], nil
+}
+
+func (d *DB) queryPayloads(query string, args ...interface{}) ([]*Payload, error) {
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payloads := []*Payload{}
+	for rows.Next() {
+		p := &Payload{}
+		var encodedArgs string
+		var closedHeight sql.NullInt64
+		var closedTx sql.NullString
+		err := rows.Scan(&p.ResourceID, &p.TxIndex, &p.Vault, &p.Method, &encodedArgs,
+			&p.ProposedHeight, &p.ProposedTx, &p.Status, &closedHeight, &closedTx)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(encodedArgs), &p.Args); err != nil {
+			return nil, err
+		}
+		if closedHeight.Valid {
+			h := uint64(closedHeight.Int64)
+			p.ClosedHeight = &h
+		}
+		if closedTx.Valid {
+			p.ClosedTx = &closedTx.String
+		}
+		payloads = append(payloads, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, p := range payloads {
+		if err := d.loadSignatures(p); err != nil {
+			return nil, err
+		}
+	}
+	return payloads, nil
+}
+
+// Activity is a withdrawal from or deposit to a vault
+type Activity struct {
+	Height uint64 `json:"height"`
+	Tx     string `json:"tx"`
+	// Kind is withdrawn or deposited
+	Kind   string `json:"kind"`
+	Amount string `json:"amount"`
+}
+
+// Activity returns the withdrawals from and deposits to the vaults of `vault` in the order they happened
+func (d *DB) Activity(vault flow.Address) ([]*Activity, error) {
+	rows, err := d.db.Query(
+		"SELECT height, tx, kind, amount FROM vault_activity WHERE vault = ? ORDER BY height, tx, event_index",
+		vault.Hex(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	activity := []*Activity{}
+	for rows.Next() {
+		a := &Activity{}
+		if err := rows.Scan(&a.Height, &a.Tx, &a.Kind, &a.Amount); err != nil {
+			return nil, err
+		}
+		activity = append(activity, a)
+	}
+	return activity, rows.Err()
+}
+
+func (d *DB) loadSignatures(p *Payload) error {
+	rows, err := d.db.Query(
+		"SELECT public_key, height, tx FROM signatures WHERE resource_id = ? AND tx_index = ? ORDER BY height",
+		p.ResourceID, p.TxIndex,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	p.Signatures = []Signature{}
+	for rows.Next() {
+		var s Signature
+		if err := rows.Scan(&s.PublicKey, &s.Height, &s.Tx); err != nil {
+			return err
+		}
+		p.Signatures = append(p.Signatures, s)
+	}
+	return rows.Err()
+}
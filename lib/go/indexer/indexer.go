@@ -0,0 +1,257 @@
+// Package indexer records the lifecycle of multisig payloads in a SQLite database.
+//
+// It scans sealed blocks for the `NewPayloadAdded`, `NewPayloadSigAdded`, `PayloadExecuted`
+// and `PayloadRemoved` events of `OnChainMultiSig`, and the `TokensWithdrawn` and `TokensDeposited` events
+// of `MultiSigFlowToken` as the activity of the vaults.
+// The vault and args of new payloads are not in the events and are read from the arguments of the transactions
+// that add them, which are expected to follow `add_new_payload.cdc`. Payloads added by other transactions are
+// logged and recorded from their events, without args and with the vault of the earlier payloads of their resource.
+// Each block is recorded in one database transaction together with the cursor,
+// so indexing can be stopped at any time and resumed from the cursor.
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/flow-hydraulics/onchain-multisig/events"
+	"github.com/onflow/cadence"
+	jsoncdc "github.com/onflow/cadence/encoding/json"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/client"
+)
+
+type Kind string
+
+const (
+	KindProposed Kind = "proposed"
+	KindSigned   Kind = "signed"
+	KindExecuted Kind = "executed"
+	KindRemoved  Kind = "removed"
+	// KindWithdrawn and KindDeposited records are the activity of a vault rather than of a payload
+	KindWithdrawn Kind = "withdrawn"
+	KindDeposited Kind = "deposited"
+)
+
+// Record is a step in the lifecycle of a payload, or a withdrawal from or deposit to a vault, found in a block
+type Record struct {
+	Kind Kind
+	// Vault is only set for proposals, where it is empty if the transaction does not follow `add_new_payload.cdc`,
+	// and for vault activity, where it is empty if the vault is not stored
+	Vault      flow.Address
+	ResourceID uint64
+	TxIndex    uint64
+	Method     string
+	Args       []json.RawMessage
+	PublicKey  string
+	// Amount is only set for vault activity
+	Amount     cadence.UFix64
+	Height     uint64
+	TxID       flow.Identifier
+	EventIndex int
+}
+
+type Indexer struct {
+	client       *client.Client
+	db           *DB
+	multiSigAddr flow.Address
+	tokenAddr    flow.Address
+}
+
+// New returns an indexer of the multisig payloads of the `OnChainMultiSig` contract at `multiSigAddr`
+// and of the activity of the vaults of the `MultiSigFlowToken` contract at `tokenAddr`
+func New(c *client.Client, db *DB, multiSigAddr flow.Address, tokenAddr flow.Address) *Indexer {
+	return &Indexer{client: c, db: db, multiSigAddr: multiSigAddr, tokenAddr: tokenAddr}
+}
+
+// Sync indexes the blocks after the cursor up to the latest sealed block,
+// starting at `start` if nothing has been indexed yet.
+// It returns the last indexed height, or `start` if no block has been indexed
+func (i *Indexer) Sync(ctx context.Context, start uint64) (uint64, error) {
+	cursor, ok, err := i.db.Cursor()
+	if err != nil {
+		return start, err
+	}
+	last := start
+	if ok {
+		start = cursor + 1
+		last = cursor
+	}
+	latest, err := i.client.GetLatestBlockHeader(ctx, true)
+	if err != nil {
+		return last, err
+	}
+	for height := start; height <= latest.Height; height++ {
+		records, err := i.ScanBlock(ctx, height)
+		if err != nil {
+			return last, err
+		}
+		if err := i.db.apply(height, records); err != nil {
+			return last, err
+		}
+		last = height
+	}
+	return last, nil
+}
+
+// ScanBlock returns the lifecycle records of the successful transactions in the block at `height`
+func (i *Indexer) ScanBlock(ctx context.Context, height uint64) (records []Record, err error) {
+	block, err := i.client.GetBlockByHeight(ctx, height)
+	if err != nil {
+		return
+	}
+	for _, guarantee := range block.CollectionGuarantees {
+		collection, err := i.client.GetCollection(ctx, guarantee.CollectionID)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range collection.TransactionIDs {
+			tx, err := i.client.GetTransaction(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			result, err := i.client.GetTransactionResult(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			if result.Error != nil {
+				continue
+			}
+			txRecords, err := i.records(tx, result, height)
+			if err != nil {
+				return nil, fmt.Errorf("could not read tx %s: %w", id, err)
+			}
+			records = append(records, txRecords...)
+		}
+	}
+	return
+}
+
+func (i *Indexer) records(tx *flow.Transaction, result *flow.TransactionResult, height uint64) (records []Record, err error) {
+	for _, event := range result.Events {
+		decoded, err := events.Decode(i.multiSigAddr, event)
+		if errors.Is(err, events.ErrUnknownEvent) {
+			decoded, err = events.DecodeToken(i.tokenAddr, event)
+		}
+		if errors.Is(err, events.ErrUnknownEvent) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		r := Record{Height: height, TxID: event.TransactionID, EventIndex: event.EventIndex}
+		switch e := decoded.(type) {
+		case events.NewPayloadAdded:
+			vault, payloadArgs, err := proposalArgs(tx)
+			if err != nil {
+				log.Printf("Tx %s does not follow add_new_payload.cdc, payload %d of resource %d is indexed without its args: %s",
+					tx.ID(), e.TxIndex, e.ResourceID, err)
+				vault, payloadArgs = flow.EmptyAddress, []json.RawMessage{}
+			}
+			r.Vault = vault
+			r.ResourceID, r.TxIndex, r.Method, r.Args = e.ResourceID, e.TxIndex, e.Method, payloadArgs
+			r.Kind = KindProposed
+			records = append(records, r)
+			r.Kind, r.PublicKey = KindSigned, e.PublicKey
+			records = append(records, r)
+		case events.NewPayloadSigAdded:
+			r.Kind, r.ResourceID, r.TxIndex, r.PublicKey = KindSigned, e.ResourceID, e.TxIndex, e.PublicKey
+			records = append(records, r)
+		case events.PayloadExecuted:
+			r.Kind, r.ResourceID, r.TxIndex = KindExecuted, e.ResourceID, e.TxIndex
+			records = append(records, r)
+		case events.PayloadRemoved:
+			r.Kind, r.ResourceID, r.TxIndex = KindRemoved, e.ResourceID, e.TxIndex
+			records = append(records, r)
+		case events.TokensWithdrawn:
+			r.Kind, r.Amount = KindWithdrawn, e.Amount
+			if e.From != nil {
+				r.Vault = *e.From
+			}
+			records = append(records, r)
+		case events.TokensDeposited:
+			r.Kind, r.Amount = KindDeposited, e.Amount
+			if e.To != nil {
+				r.Vault = *e.To
+			}
+			records = append(records, r)
+		}
+	}
+	return
+}
+
+// proposalArgs returns the vault and the JSON-Cadence encoded payload args of a transaction of `add_new_payload.cdc`
+func proposalArgs(tx *flow.Transaction) (flow.Address, []json.RawMessage, error) {
+	// transaction (sig: String, txIndex: UInt64, method: String, args: [AnyStruct], publicKey: String, addr: Address, ...)
+	args, err := decodeArgs(tx)
+	if err != nil {
+		return flow.EmptyAddress, nil, err
+	}
+	payloadArgs := []json.RawMessage{}
+	for _, arg := range args.array(3) {
+		b, err := jsoncdc.Encode(arg)
+		if err != nil {
+			return flow.EmptyAddress, nil, err
+		}
+		payloadArgs = append(payloadArgs, b)
+	}
+	vault := args.address(5)
+	if args.err != nil {
+		return flow.EmptyAddress, nil, args.err
+	}
+	return vault, payloadArgs, nil
+}
+
+// txArgs are the decoded arguments of a transaction,
+// the accessors record an error instead of panicking if the transaction does not follow the template
+type txArgs struct {
+	values []cadence.Value
+	err    error
+}
+
+func decodeArgs(tx *flow.Transaction) (*txArgs, error) {
+	args := &txArgs{}
+	for _, b := range tx.Arguments {
+		v, err := jsoncdc.Decode(b)
+		if err != nil {
+			return nil, err
+		}
+		args.values = append(args.values, v)
+	}
+	return args, nil
+}
+
+func (a *txArgs) get(i int) cadence.Value {
+	if i < len(a.values) {
+		return a.values[i]
+	}
+	if a.err == nil {
+		a.err = fmt.Errorf("expected at least %d arguments, got %d", i+1, len(a.values))
+	}
+	return nil
+}
+
+func (a *txArgs) mismatch(i int, typ string) {
+	if a.err == nil {
+		a.err = fmt.Errorf("argument %d is not %s", i, typ)
+	}
+}
+
+func (a *txArgs) address(i int) flow.Address {
+	v, ok := a.get(i).(cadence.Address)
+	if !ok {
+		a.mismatch(i, "an Address")
+	}
+	return flow.BytesToAddress(v.Bytes())
+}
+
+func (a *txArgs) array(i int) []cadence.Value {
+	v, ok := a.get(i).(cadence.Array)
+	if !ok {
+		a.mismatch(i, "an array")
+	}
+	return v.Values
+}
@@ -0,0 +1,192 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/bjartek/go-with-the-flow/gwtf"
+	util "github.com/flow-hydraulics/onchain-multisig"
+	"github.com/flow-hydraulics/onchain-multisig/signer"
+	"github.com/flow-hydraulics/onchain-multisig/vault"
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/client"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+const vaultAcct = "vaulted-account"
+
+func newTestIndexer(t *testing.T, g *gwtf.GoWithTheFlow) (*Indexer, *DB) {
+	c, err := client.New(g.Address, grpc.WithInsecure())
+	assert.NoError(t, err)
+	t.Cleanup(func() { c.Close() })
+	db, err := Open(filepath.Join(t.TempDir(), "indexer.db"))
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return New(c, db, g.Accounts["owner"].Address, g.Accounts["owner"].Address), db
+}
+
+func TestIndexerRecordsPayloadLifecycle(t *testing.T) {
+	g := emu.GoWithTheFlow()
+	i, db := newTestIndexer(t, g)
+	vaultAddr := flow.HexToAddress(util.GetAccountAddr(g, vaultAcct))
+
+	// Backfill the blocks of the setup in TestMain
+	backfilled, err := i.Sync(context.Background(), 0)
+	assert.NoError(t, err)
+	cursor, ok, err := db.Cursor()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, backfilled, cursor)
+	history, err := db.History(vaultAddr)
+	assert.NoError(t, err)
+
+	txIndex, err := util.GetTxIndex(g, vaultAcct)
+	assert.NoError(t, err)
+	executed := txIndex + 1
+	removed := txIndex + 2
+	remover := txIndex + 3
+
+	_, err = vault.MultiSig_Transfer(g, "1.0", "owner", executed, vault.Acct500_2, vaultAcct, true)
+	assert.NoError(t, err)
+	_, err = vault.MultiSig_Transfer(g, "1.0", "owner", executed, vault.Acct1000, vaultAcct, false)
+	assert.NoError(t, err)
+	_, err = vault.MultiSig_VaultExecuteTx(g, executed, "owner", vaultAcct)
+	assert.NoError(t, err)
+
+	_, err = vault.MultiSig_Transfer(g, "2.0", "owner", removed, vault.Acct1000, vaultAcct, true)
+	assert.NoError(t, err)
+	_, err = vault.MultiSig_RemoveVaultedPayload(g, remover, removed, vault.Acct1000, vaultAcct, true)
+	assert.NoError(t, err)
+	_, err = vault.MultiSig_VaultExecuteTx(g, remover, "owner", vaultAcct)
+	assert.NoError(t, err)
+
+	// Resume from the cursor
+	synced, err := i.Sync(context.Background(), 0)
+	assert.NoError(t, err)
+	assert.Greater(t, synced, backfilled)
+
+	p, err := db.Payload(vaultAddr, executed)
+	assert.NoError(t, err)
+	assert.Equal(t, "transfer", p.Method)
+	assert.Len(t, p.Args, 2)
+	assert.Equal(t, StatusExecuted, p.Status)
+	assert.NotNil(t, p.ClosedHeight)
+	signers := []string{}
+	for _, s := range p.Signatures {
+		signers = append(signers, s.PublicKey)
+	}
+	assert.Equal(t, []string{
+		signer.PublicKeyHex(util.GetSigner(g, vault.Acct500_2)),
+		signer.PublicKeyHex(util.GetSigner(g, vault.Acct1000)),
+	}, signers)
+
+	p, err = db.Payload(vaultAddr, removed)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusRemoved, p.Status)
+	p, err = db.Payload(vaultAddr, remover)
+	assert.NoError(t, err)
+	assert.Equal(t, "removePayload", p.Method)
+	assert.Equal(t, StatusExecuted, p.Status)
+
+	signed, err := db.SignedBy(signers[0])
+	assert.NoError(t, err)
+	assert.NotEmpty(t, signed)
+
+	// Syncing again does not record the payloads twice
+	_, err = i.Sync(context.Background(), 0)
+	assert.NoError(t, err)
+	postHistory, err := db.History(vaultAddr)
+	assert.NoError(t, err)
+	assert.Len(t, postHistory, len(history)+3)
+}
+
+func TestIndexerRecordsPayloadsAddedByOtherTransactions(t *testing.T) {
+	g := emu.GoWithTheFlow()
+	i, db := newTestIndexer(t, g)
+	vaultAddr := flow.HexToAddress(util.GetAccountAddr(g, vaultAcct))
+
+	txIndex, err := util.GetTxIndex(g, vaultAcct)
+	assert.NoError(t, err)
+	_, err = vault.MultiSig_Transfer(g, "1.0", "owner", txIndex+1, vault.Acct1000, vaultAcct, true)
+	assert.NoError(t, err)
+
+	// A payload added by a transaction with other arguments than add_new_payload.cdc
+	amount, err := cadence.NewUFix64("1.0")
+	assert.NoError(t, err)
+	to := cadence.BytesToAddress(g.Accounts["owner"].Address.Bytes())
+	signable, err := util.GetSignableDataFromScript(g, vaultAcct, txIndex+2, "transfer", amount, to)
+	assert.NoError(t, err)
+	sig, err := util.SignPayloadOffline(g, signable, vault.Acct1000)
+	assert.NoError(t, err)
+	addresses := util.EmulatorAddresses
+	code := fmt.Sprintf(`import MultiSigFlowToken from 0x%s
+import OnChainMultiSig from 0x%s
+transaction(publicKey: String) {
+    prepare(acct: AuthAccount) {}
+    execute {
+        let signer = getAccount(0x%s).getCapability(/%s/%s)
+            .borrow<&MultiSigFlowToken.Vault{OnChainMultiSig.PublicSigner}>()!
+        let args: [AnyStruct] = [1.0 as UFix64, 0x%s as Address]
+        let p <- OnChainMultiSig.createPayload(txIndex: %d, method: "transfer", args: args, rsc: nil)
+        signer.addNewPayload(payload: <-p, publicKey: publicKey, sig: "%s".decodeHex())
+    }
+}`, addresses.MultiSigFlowToken, addresses.OnChainMultiSig, vaultAddr.Hex(),
+		util.DefaultVaultPaths.Signer.Domain, util.DefaultVaultPaths.Signer.Identifier,
+		g.Accounts["owner"].Address.Hex(), txIndex+2, sig)
+	publicKey := signer.PublicKeyHex(util.GetSigner(g, vault.Acct1000))
+	_, err = util.SendTransaction(g, util.AccountRoles("owner"), "add_payload_inline.cdc", []byte(code), cadence.String(publicKey))
+	assert.NoError(t, err)
+
+	// It does not stop the indexer, and is recorded from its events with the vault of the earlier payload
+	_, err = i.Sync(context.Background(), 0)
+	assert.NoError(t, err)
+	p, err := db.Payload(vaultAddr, txIndex+2)
+	assert.NoError(t, err)
+	assert.Equal(t, "transfer", p.Method)
+	assert.Empty(t, p.Args)
+	assert.Equal(t, StatusPending, p.Status)
+	assert.Len(t, p.Signatures, 1)
+
+	// The withdrawals and deposits of the vaults are recorded as their activity
+	_, err = vault.MultiSig_VaultExecuteTx(g, txIndex+1, "owner", vaultAcct)
+	assert.NoError(t, err)
+	_, err = i.Sync(context.Background(), 0)
+	assert.NoError(t, err)
+	activity, err := db.Activity(vaultAddr)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, activity)
+	last := activity[len(activity)-1]
+	assert.Equal(t, "withdrawn", last.Kind)
+	assert.Equal(t, "1.00000000", last.Amount)
+	ownerActivity, err := db.Activity(g.Accounts["owner"].Address)
+	assert.NoError(t, err)
+	assert.Equal(t, "deposited", ownerActivity[len(ownerActivity)-1].Kind)
+}
+
+func TestIndexerSyncFailingOnFirstBlock(t *testing.T) {
+	g := emu.GoWithTheFlow()
+	i, db := newTestIndexer(t, g)
+
+	_, err := db.db.Exec("CREATE TRIGGER fail_cursor BEFORE INSERT ON cursor BEGIN SELECT RAISE(ABORT, 'cursor is read only'); END")
+	assert.NoError(t, err)
+	synced, err := i.Sync(context.Background(), 0)
+	assert.Error(t, err)
+	assert.Equal(t, uint64(0), synced)
+	_, ok, err := db.Cursor()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// The next sync starts over at the first block
+	_, err = db.db.Exec("DROP TRIGGER fail_cursor")
+	assert.NoError(t, err)
+	synced, err = i.Sync(context.Background(), 0)
+	assert.NoError(t, err)
+	cursor, ok, err := db.Cursor()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, synced, cursor)
+}
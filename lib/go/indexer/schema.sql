@@ -0,0 +1,48 @@
+-- Payloads added to multisig resources, one row per resource and txIndex
+CREATE TABLE IF NOT EXISTS payloads (
+    resource_id     INTEGER NOT NULL, -- uuid of the multisig resource
+    tx_index        INTEGER NOT NULL,
+    vault           TEXT    NOT NULL, -- hex address of the account storing the resource, that of the earlier payloads
+                                      -- of the resource, or empty, if it was not added with add_new_payload.cdc
+    method          TEXT    NOT NULL,
+    args            TEXT    NOT NULL, -- JSON array of the JSON-Cadence encoded args
+    proposed_height INTEGER NOT NULL,
+    proposed_tx     TEXT    NOT NULL,
+    status          TEXT    NOT NULL, -- pending, executed or removed
+    closed_height   INTEGER,          -- height the payload was executed or removed at
+    closed_tx       TEXT,
+    PRIMARY KEY (resource_id, tx_index)
+);
+
+CREATE INDEX IF NOT EXISTS payloads_vault ON payloads (vault, tx_index);
+
+-- Signatures added to payloads, including the one each payload was added with
+CREATE TABLE IF NOT EXISTS signatures (
+    resource_id INTEGER NOT NULL,
+    tx_index    INTEGER NOT NULL,
+    public_key  TEXT    NOT NULL,
+    height      INTEGER NOT NULL,
+    tx          TEXT    NOT NULL,
+    PRIMARY KEY (resource_id, tx_index, public_key)
+);
+
+CREATE INDEX IF NOT EXISTS signatures_public_key ON signatures (public_key);
+
+-- Tokens withdrawn from and deposited to MultiSigFlowToken vaults, one row per event
+CREATE TABLE IF NOT EXISTS vault_activity (
+    tx          TEXT    NOT NULL,
+    event_index INTEGER NOT NULL,
+    height      INTEGER NOT NULL,
+    kind        TEXT    NOT NULL, -- withdrawn or deposited
+    vault       TEXT,             -- hex address of the account storing the vault, NULL if it is not stored
+    amount      TEXT    NOT NULL, -- UFix64 amount, e.g. 1.50000000
+    PRIMARY KEY (tx, event_index)
+);
+
+CREATE INDEX IF NOT EXISTS vault_activity_vault ON vault_activity (vault, height);
+
+-- The last block height that has been indexed
+CREATE TABLE IF NOT EXISTS cursor (
+    id     INTEGER PRIMARY KEY CHECK (id = 0),
+    height INTEGER NOT NULL
+);
@@ -1,6 +1,8 @@
 package keys
 
 import (
+	"errors"
+
 	"github.com/bjartek/go-with-the-flow/gwtf"
 	util "github.com/flow-hydraulics/onchain-multisig"
 	"github.com/onflow/cadence"
@@ -27,8 +29,8 @@ func MultiSig_RemoveKey(
 	newPayload bool,
 ) (events []*gwtf.FormatedEvent, err error) {
 	method := "removeKey"
-	pkToRemove := cadence.NewString(g.Accounts[acctToRemove].PrivateKey.PublicKey().String()[2:])
-	signable, err := util.GetSignableDataFromScript(g, txIndex, method, pkToRemove)
+	pkToRemove := cadence.NewString(util.GetSigner(g, acctToRemove).PublicKey().String()[2:])
+	signable, err := util.GetSignableDataFromScript(g, vaultAcct, txIndex, method, pkToRemove)
 	if err != nil {
 		return
 	}
@@ -57,7 +59,7 @@ func MultiSig_ConfigKey(
 ) (events []*gwtf.FormatedEvent, err error) {
 
 	method := "configureKey"
-	pkToConfig := cadence.NewString(g.Accounts[acctToConfig].PrivateKey.PublicKey().String()[2:])
+	pkToConfig := cadence.NewString(util.GetSigner(g, acctToConfig).PublicKey().String()[2:])
 
 	weightToConfig, err := cadence.NewUFix64(acctToConfigWeight)
 	sigAlgoToConfig := cadence.NewUInt8(1)
@@ -65,7 +67,7 @@ func MultiSig_ConfigKey(
 	if err != nil {
 		return
 	}
-	signable, err := util.GetSignableDataFromScript(g, txIndex, method, pkToConfig, weightToConfig, sigAlgoToConfig)
+	signable, err := util.GetSignableDataFromScript(g, vaultAcct, txIndex, method, pkToConfig, weightToConfig, sigAlgoToConfig)
 	if err != nil {
 		return
 	}
@@ -82,3 +84,132 @@ func MultiSig_ConfigKey(
 		return util.MultiSig_VaultAddPayloadSignature(g, sig, txIndex, signerAcct, vaultAcct)
 	}
 }
+
+// KeyPolicy mirrors `OnChainMultiSig.PubKeyAttr` with the `OnChainMultiSig.KeyPolicy` of the key,
+// the attributes of a multisig key including the methods it is allowed to sign for
+type KeyPolicy struct {
+	SigAlgo uint8
+	Weight  cadence.UFix64
+	// AllowedMethods is nil if the key is allowed to sign for all methods
+	AllowedMethods []string
+	// MethodWeights overrides Weight for particular methods
+	MethodWeights map[string]cadence.UFix64
+}
+
+// WeightFor returns the weight the key contributes to a payload calling `method`,
+// the same as `KeyPolicy.getWeight` in the contract
+func (p KeyPolicy) WeightFor(method string) cadence.UFix64 {
+	if p.AllowedMethods != nil {
+		allowed := false
+		for _, m := range p.AllowedMethods {
+			if m == method {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return 0
+		}
+	}
+	if w, ok := p.MethodWeights[method]; ok {
+		return w
+	}
+	return p.Weight
+}
+
+// NewKeyPolicyFromCadence decodes the weight and signature algorithm of a key
+// and its optional `OnChainMultiSig.KeyPolicy` struct returned by a script
+func NewKeyPolicyFromCadence(weight cadence.UFix64, sigAlgo uint8, policy cadence.Value) (p KeyPolicy, err error) {
+	p.SigAlgo = sigAlgo
+	p.Weight = weight
+	p.MethodWeights = map[string]cadence.UFix64{}
+	o, ok := policy.(cadence.Optional)
+	if !ok {
+		err = errors.New("returned not KeyPolicy?")
+		return
+	}
+	if o.Value == nil {
+		return
+	}
+	s, ok := o.Value.(cadence.Struct)
+	if !ok || len(s.Fields) != 2 {
+		err = errors.New("returned not KeyPolicy")
+		return
+	}
+	if methods := s.Fields[0].(cadence.Optional).Value; methods != nil {
+		p.AllowedMethods = util.ConvertCadenceStringArray(methods)
+	}
+	for _, pair := range s.Fields[1].(cadence.Dictionary).Pairs {
+		p.MethodWeights[string(pair.Key.(cadence.String))] = pair.Value.(cadence.UFix64)
+	}
+	return
+}
+
+// GetKeyPolicy returns the attributes of the key of `signerAcct` in the vault of `resourceAcct` with its policy
+func GetKeyPolicy(g *gwtf.GoWithTheFlow, resourceAcct string, signerAcct string) (result KeyPolicy, err error) {
+	weight, err := util.GetKeyWeight(g, resourceAcct, signerAcct)
+	if err != nil {
+		return
+	}
+	signerPubKey := util.GetSigner(g, signerAcct).PublicKey().String()[2:]
+	run := func(filename string) (cadence.Value, error) {
+		script := util.ParseCadenceTemplate(filename)
+		return g.ScriptFromFile(filename, script).
+			AccountArgument(resourceAcct).
+			Argument(util.DefaultVaultPaths.Signer).
+			StringArgument(signerPubKey).
+			RunReturns()
+	}
+	sigAlgo, err := run("../../../scripts/get_key_sig_algo.cdc")
+	if err != nil {
+		return
+	}
+	policy, err := run("../../../scripts/get_key_policy.cdc")
+	if err != nil {
+		return
+	}
+	return NewKeyPolicyFromCadence(weight, uint8(sigAlgo.(cadence.Optional).Value.(cadence.UInt8)), policy)
+}
+
+// SetKeyPolicy is signed by the owner of the vault to restrict the methods the key of `acctToConfig`
+// can sign for. `allowedMethods` of nil allows all methods, `methodWeights` overrides the key weight
+func SetKeyPolicy(
+	g *gwtf.GoWithTheFlow,
+	acctToConfig string,
+	allowedMethods []string,
+	methodWeights map[string]string,
+	vaultAcct string,
+) (events []*gwtf.FormatedEvent, err error) {
+	txFilename := "../../../transactions/set_key_policy.cdc"
+	txScript := util.ParseCadenceTemplate(txFilename)
+
+	pkToConfig := util.GetSigner(g, acctToConfig).PublicKey().String()[2:]
+
+	methods := cadence.NewOptional(nil)
+	if allowedMethods != nil {
+		values := []cadence.Value{}
+		for _, m := range allowedMethods {
+			values = append(values, cadence.NewString(m))
+		}
+		methods = cadence.NewOptional(cadence.NewArray(values))
+	}
+
+	weights := []cadence.KeyValuePair{}
+	for m, w := range methodWeights {
+		weight, err := cadence.NewUFix64(w)
+		if err != nil {
+			return nil, err
+		}
+		weights = append(weights, cadence.KeyValuePair{Key: cadence.NewString(m), Value: weight})
+	}
+
+	e, err := g.TransactionFromFile(txFilename, txScript).
+		SignProposeAndPayAs(vaultAcct).
+		Argument(util.DefaultVaultPaths.Storage).
+		StringArgument(pkToConfig).
+		Argument(methods).
+		Argument(cadence.NewDictionary(weights)).
+		Run()
+	events = util.ParseTestEvents(e)
+	return
+}
@@ -1,20 +1,23 @@
 package keys
 
 import (
+	"strconv"
 	"testing"
 
-	"github.com/bjartek/go-with-the-flow/gwtf"
 	util "github.com/flow-hydraulics/onchain-multisig"
 	"github.com/flow-hydraulics/onchain-multisig/vault"
+	"github.com/onflow/cadence"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestAddAndExecuteKeyRemoval(t *testing.T) {
-	g := gwtf.NewGoWithTheFlow("../../../flow.json")
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := vault.NewTestFixture(t, g)
 
-	vaultAcct := "vaulted-account"
-	payerAcct := "owner"
-	removedPk := g.Accounts[vault.Acct500_1].PrivateKey.PublicKey().String()[2:]
+	vaultAcct := f.Vault
+	payerAcct := f.Payer
+	removedPk := util.GetSigner(g, f.Signers[1]).PublicKey().String()[2:]
 
 	hasKey, err := ContainsKey(g, vaultAcct, removedPk)
 	assert.NoError(t, err)
@@ -23,14 +26,28 @@ func TestAddAndExecuteKeyRemoval(t *testing.T) {
 	initTxIndex, err := util.GetTxIndex(g, vaultAcct)
 	assert.NoError(t, err)
 
-	_, err = MultiSig_RemoveKey(g, vault.Acct500_1, initTxIndex+uint64(1), vault.Acct1000, vaultAcct, true)
+	_, err = MultiSig_RemoveKey(g, f.Signers[1], initTxIndex+uint64(1), f.Signers[0], vaultAcct, true)
 	assert.NoError(t, err)
 
 	postTxIndex, err := util.GetTxIndex(g, vaultAcct)
 	assert.NoError(t, err)
 
-	_, err = vault.MultiSig_VaultExecuteTx(g, postTxIndex, payerAcct, vaultAcct)
+	events, err := vault.MultiSig_VaultExecuteTx(g, postTxIndex, payerAcct, vaultAcct)
+	assert.NoError(t, err)
+
+	uuid, err := util.GetVaultUUID(g, vaultAcct)
 	assert.NoError(t, err)
+	util.NewExpectedEvent("OnChainMultiSig", "PayloadExecuted").
+		AddField("resourceId", strconv.Itoa(int(uuid))).
+		AddField("txIndex", strconv.Itoa(int(postTxIndex))).
+		AddField("method", "removeKey").
+		AddField("signers", []string{util.GetSigner(g, f.Signers[0]).PublicKey().String()[2:]}).
+		AddField("weight", "1000.00000000").
+		AssertEqual(t, events[0])
+	util.NewExpectedEvent("OnChainMultiSig", "KeyRemoved").
+		AddField("resourceId", strconv.Itoa(int(uuid))).
+		AddField("publicKey", removedPk).
+		AssertEqual(t, events[1])
 
 	hasKey, err = ContainsKey(g, vaultAcct, removedPk)
 	assert.NoError(t, err)
@@ -38,42 +55,60 @@ func TestAddAndExecuteKeyRemoval(t *testing.T) {
 }
 
 func TestRemovedKeyCannotAddSig(t *testing.T) {
-	g := gwtf.NewGoWithTheFlow("../../../flow.json")
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := vault.NewTestFixture(t, g)
 
-	vaultAcct := "vaulted-account"
-	// From the previous test
-	removedAcct := vault.Acct500_1
+	vaultAcct := f.Vault
+	acct1000, removedAcct := f.Signers[0], f.Signers[1]
 
 	txIndex, err := util.GetTxIndex(g, vaultAcct)
 	assert.NoError(t, err)
 
+	_, err = MultiSig_RemoveKey(g, removedAcct, txIndex+uint64(1), acct1000, vaultAcct, true)
+	assert.NoError(t, err)
+
+	_, err = vault.MultiSig_VaultExecuteTx(g, txIndex+uint64(1), f.Payer, vaultAcct)
+	assert.NoError(t, err)
+
 	// Add a new payload to test new signature cannot be added by removed account
-	_, err = MultiSig_RemoveKey(g, vault.Acct500_1, txIndex+uint64(1), vault.Acct1000, vaultAcct, true)
+	_, err = MultiSig_RemoveKey(g, f.Signers[2], txIndex+uint64(2), acct1000, vaultAcct, true)
 	assert.NoError(t, err)
 
-	_, err = MultiSig_RemoveKey(g, removedAcct, txIndex+uint64(1), removedAcct, vaultAcct, false)
+	_, err = MultiSig_RemoveKey(g, f.Signers[2], txIndex+uint64(2), removedAcct, vaultAcct, false)
 	assert.Error(t, err)
 }
 
 func TestAddAndExecuteKeyConfig(t *testing.T) {
-	g := gwtf.NewGoWithTheFlow("../../../flow.json")
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := vault.NewTestFixture(t, g)
 
-	vaultAcct := "vaulted-account"
-	payerAcct := "owner"
-	newAcct := vault.Acct500_2
+	vaultAcct := f.Vault
+	payerAcct := f.Payer
+	newAcct := f.Signers[2]
 	newAcctWeight := "100.00000000"
 
 	initTxIndex, err := util.GetTxIndex(g, vaultAcct)
 	assert.NoError(t, err)
 
-	_, err = MultiSig_ConfigKey(g, newAcct, newAcctWeight, initTxIndex+uint64(1), vault.Acct1000, vaultAcct, true)
+	_, err = MultiSig_ConfigKey(g, newAcct, newAcctWeight, initTxIndex+uint64(1), f.Signers[0], vaultAcct, true)
 	assert.NoError(t, err)
 
 	postTxIndex, err := util.GetTxIndex(g, vaultAcct)
 	assert.NoError(t, err)
 
-	_, err = vault.MultiSig_VaultExecuteTx(g, postTxIndex, payerAcct, vaultAcct)
+	events, err := vault.MultiSig_VaultExecuteTx(g, postTxIndex, payerAcct, vaultAcct)
+	assert.NoError(t, err)
+
+	uuid, err := util.GetVaultUUID(g, vaultAcct)
 	assert.NoError(t, err)
+	util.NewExpectedEvent("OnChainMultiSig", "KeyConfigured").
+		AddField("resourceId", strconv.Itoa(int(uuid))).
+		AddField("publicKey", util.GetSigner(g, newAcct).PublicKey().String()[2:]).
+		AddField("weight", newAcctWeight).
+		AddField("sigAlgo", "1").
+		AssertEqual(t, events[1])
 
 	weight, err := util.GetKeyWeight(g, vaultAcct, newAcct)
 	assert.NoError(t, err)
@@ -81,17 +116,20 @@ func TestAddAndExecuteKeyConfig(t *testing.T) {
 }
 
 func TestAddAndExecuteNewKeyConfig(t *testing.T) {
-	g := gwtf.NewGoWithTheFlow("../../../flow.json")
-
-	vaultAcct := "vaulted-account"
-	payerAcct := "owner"
-	newAcct := "non-registered-account"
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := vault.NewTestFixture(t, g)
+
+	vaultAcct := f.Vault
+	payerAcct := f.Payer
+	// The payer has no key in the vault
+	newAcct := f.Payer
 	newAcctWeight := "150.00000000"
 
 	initTxIndex, err := util.GetTxIndex(g, vaultAcct)
 	assert.NoError(t, err)
 
-	_, err = MultiSig_ConfigKey(g, newAcct, newAcctWeight, initTxIndex+uint64(1), vault.Acct1000, vaultAcct, true)
+	_, err = MultiSig_ConfigKey(g, newAcct, newAcctWeight, initTxIndex+uint64(1), f.Signers[0], vaultAcct, true)
 	assert.NoError(t, err)
 
 	postTxIndex, err := util.GetTxIndex(g, vaultAcct)
@@ -104,3 +142,113 @@ func TestAddAndExecuteNewKeyConfig(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, newAcctWeight, weight.String())
 }
+
+func TestKeyPolicyWeightFor(t *testing.T) {
+	w250, _ := cadence.NewUFix64("250.0")
+	w300, _ := cadence.NewUFix64("300.0")
+
+	unrestricted := KeyPolicy{Weight: w250}
+	assert.Equal(t, w250, unrestricted.WeightFor("removeKey"))
+
+	restricted := KeyPolicy{
+		Weight:         w250,
+		AllowedMethods: []string{"deposit", "configureKey"},
+		MethodWeights:  map[string]cadence.UFix64{"configureKey": w300},
+	}
+	assert.Equal(t, w250, restricted.WeightFor("deposit"))
+	assert.Equal(t, w300, restricted.WeightFor("configureKey"))
+	assert.Equal(t, cadence.UFix64(0), restricted.WeightFor("removeKey"))
+}
+
+func TestRestrictedKeyHasNoWeightForDisallowedMethod(t *testing.T) {
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := vault.NewTestFixture(t, g)
+
+	vaultAcct := f.Vault
+	restrictedAcct := f.Signers[3]
+
+	_, err := SetKeyPolicy(g, restrictedAcct, []string{"configureKey"}, map[string]string{"configureKey": "300.0"}, vaultAcct)
+	assert.NoError(t, err)
+
+	policy, err := GetKeyPolicy(g, vaultAcct, restrictedAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"configureKey"}, policy.AllowedMethods)
+	assert.Equal(t, "250.00000000", policy.Weight.String())
+	assert.Equal(t, "300.00000000", policy.WeightFor("configureKey").String())
+	assert.Equal(t, "0.00000000", policy.WeightFor("removeKey").String())
+
+	txIndex, err := util.GetTxIndex(g, vaultAcct)
+	assert.NoError(t, err)
+
+	// The restricted key cannot start or sign a payload for a disallowed method
+	_, err = MultiSig_RemoveKey(g, f.Signers[0], txIndex+uint64(1), restrictedAcct, vaultAcct, true)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Public key cannot sign for this method")
+	}
+
+	_, err = MultiSig_RemoveKey(g, f.Payer, txIndex+uint64(1), f.Signers[0], vaultAcct, true)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_RemoveKey(g, f.Payer, txIndex+uint64(1), restrictedAcct, vaultAcct, false)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Public key cannot sign for this method")
+	}
+
+	// but can for an allowed method, with the overridden weight
+	_, err = MultiSig_ConfigKey(g, f.Signers[2], "500.0", txIndex+uint64(2), restrictedAcct, vaultAcct, true)
+	assert.NoError(t, err)
+
+	// Reconfiguring the weight of a key keeps its policy
+	_, err = MultiSig_ConfigKey(g, restrictedAcct, "200.0", txIndex+uint64(3), f.Signers[0], vaultAcct, true)
+	assert.NoError(t, err)
+
+	_, err = vault.MultiSig_VaultExecuteTx(g, txIndex+uint64(3), f.Payer, vaultAcct)
+	assert.NoError(t, err)
+
+	policy, err = GetKeyPolicy(g, vaultAcct, restrictedAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, "200.00000000", policy.Weight.String())
+	assert.Equal(t, []string{"configureKey"}, policy.AllowedMethods)
+}
+
+func TestSignaturesOfDisallowedKeysDoNotCount(t *testing.T) {
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := vault.NewTestFixture(t, g)
+
+	vaultAcct := f.Vault
+	disallowedAcct := f.Signers[1]
+
+	uuid, err := util.GetVaultUUID(g, vaultAcct)
+	assert.NoError(t, err)
+	txIndex, err := util.GetTxIndex(g, vaultAcct)
+	assert.NoError(t, err)
+
+	// The key signs while it is allowed to
+	_, err = MultiSig_RemoveKey(g, f.Signers[4], txIndex+uint64(1), disallowedAcct, vaultAcct, true)
+	assert.NoError(t, err)
+
+	_, err = SetKeyPolicy(g, disallowedAcct, []string{"deposit"}, map[string]string{}, vaultAcct)
+	assert.NoError(t, err)
+
+	// Its signature is neglected, so the payload is not ready rather than failing to verify
+	_, err = vault.MultiSig_VaultExecuteTx(g, txIndex+uint64(1), f.Payer, vaultAcct)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no transactable payload at given txIndex")
+	}
+
+	_, err = MultiSig_RemoveKey(g, f.Signers[4], txIndex+uint64(1), f.Signers[0], vaultAcct, false)
+	assert.NoError(t, err)
+
+	events, err := vault.MultiSig_VaultExecuteTx(g, txIndex+uint64(1), f.Payer, vaultAcct)
+	assert.NoError(t, err)
+
+	util.NewExpectedEvent("OnChainMultiSig", "PayloadExecuted").
+		AddField("resourceId", strconv.Itoa(int(uuid))).
+		AddField("txIndex", strconv.Itoa(int(txIndex+uint64(1)))).
+		AddField("method", "removeKey").
+		AddField("signers", []string{util.GetSigner(g, f.Signers[0]).PublicKey().String()[2:]}).
+		AddField("weight", "1000.00000000").
+		AssertEqual(t, events[0])
+}
@@ -0,0 +1,353 @@
+package model
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/bjartek/go-with-the-flow/gwtf"
+	util "github.com/flow-hydraulics/onchain-multisig"
+	"github.com/flow-hydraulics/onchain-multisig/emulator"
+	"github.com/flow-hydraulics/onchain-multisig/signer"
+	"github.com/flow-hydraulics/onchain-multisig/vault"
+	"github.com/onflow/cadence"
+	"github.com/stretchr/testify/assert"
+)
+
+// The number of random operations of the differential test
+const differentialOps = 60
+
+var seed = flag.Int64("seed", 0, "seed of the operations of the differential test, random if 0")
+
+// differ applies the same operations to the vault of a fixture on an emulator and to a model of it
+type differ struct {
+	t *testing.T
+	g *gwtf.GoWithTheFlow
+	f vault.Fixture
+	v *Vault
+	r *rand.Rand
+	// accts are the signers of the fixture and the payer, whose key is not registered
+	accts []string
+}
+
+func TestModelMatchesEmulator(t *testing.T) {
+	if testing.Short() {
+		t.Skip("the differential test needs an emulator")
+	}
+	e, err := emulator.Start("../../..")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer e.Stop()
+	g := e.GoWithTheFlow()
+	f := vault.NewTestFixture(t, g)
+	// The payer adds the deposits
+	assert.NoError(t, f.Fund(f.Payer, "1000.0"))
+
+	uuid, err := util.GetVaultUUID(g, f.Vault)
+	assert.NoError(t, err)
+	balance, err := util.GetBalance(g, f.Vault)
+	assert.NoError(t, err)
+	v := NewVault(g.Accounts[emulator.Owner].Address, g.Accounts[f.Vault].Address, uuid, balance)
+	v.Receivers[g.Accounts[f.Payer].Address] = true
+	for i, acct := range f.Signers {
+		weight, err := cadence.NewUFix64(vault.DefaultWeights[i])
+		assert.NoError(t, err)
+		v.ConfigureKeys([]string{signer.PublicKeyHex(util.GetSigner(g, acct))}, []cadence.UFix64{weight}, []uint8{1})
+	}
+
+	domain, err := util.GetSignableDomain(g, f.Vault)
+	assert.NoError(t, err)
+	assert.Equal(t, domain, v.Domain)
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	t.Logf("seed: %d", *seed)
+	d := &differ{t: t, g: g, f: f, v: v, r: rand.New(rand.NewSource(*seed)), accts: append(f.Signers, f.Payer)}
+	d.assertSameState()
+
+	for i := 0; i < differentialOps && !t.Failed(); i++ {
+		height, err := util.GetBlockHeight(g)
+		assert.NoError(t, err)
+		// The transactions are executed in the next block
+		v.Height = height + 1
+
+		op, modelErr, emuErr := d.randomOp()
+		t.Logf("%d: %s: %v", i, op, modelErr)
+		if modelErr == nil {
+			assert.NoError(t, emuErr, op)
+		} else if assert.Error(t, emuErr, op) {
+			assert.Contains(t, emuErr.Error(), modelErr.Error(), op)
+		}
+		d.assertSameState()
+	}
+}
+
+func (d *differ) randomOp() (op string, modelErr error, emuErr error) {
+	switch n := d.r.Intn(10); {
+	case n < 4:
+		return d.addNewPayload()
+	case n < 6:
+		return d.addPayloadSignature()
+	case n < 9:
+		return d.executeTx()
+	default:
+		op = "advance a block"
+		emuErr = util.AdvanceBlocks(d.g, 1, d.f.Payer)
+		return
+	}
+}
+
+func (d *differ) addNewPayload() (op string, modelErr error, emuErr error) {
+	txIndex := d.v.TxIndex + 1
+	if d.r.Intn(10) == 0 {
+		txIndex = d.v.TxIndex + uint64(d.r.Intn(2)*2)
+	}
+	method, args, resource := d.randomPayload()
+	acct := d.pick(d.accts)
+	sig, pk := d.sign(txIndex, method, args, acct)
+
+	withdrawAmount := "0.0"
+	p := Payload{TxIndex: txIndex, Method: method, Args: args}
+	if resource != nil {
+		withdrawAmount = resource.String()
+		p.Resource = resource
+	}
+	op = fmt.Sprintf("add payload %d %s %v with %s", txIndex, method, args, withdrawAmount)
+	if resource != nil {
+		modelErr = d.v.AddNewPayloadFrom(p, pk, sig, d.g.Accounts[d.f.Payer].Address)
+	} else {
+		modelErr = d.v.AddNewPayload(p, pk, sig)
+	}
+
+	roles := util.TxRoles{Proposer: d.f.Payer, Payer: d.f.Payer, Authorizers: []string{d.f.Payer}}
+	_, emuErr = util.SubmitNewPayload(d.g, roles, hex.EncodeToString(sig), txIndex, method, args, pk, d.g.FindAddress(d.f.Vault), util.DefaultVaultPaths.Signer, withdrawAmount)
+	return
+}
+
+func (d *differ) addPayloadSignature() (op string, modelErr error, emuErr error) {
+	txIndex := d.randomTxIndex()
+	method, args := "transfer", []cadence.Value{}
+	if p, ok := d.v.Payloads[txIndex]; ok {
+		method, args = p.Method, p.Args
+	}
+	acct := d.pick(d.accts)
+	sig, pk := d.sign(txIndex, method, args, acct)
+
+	op = fmt.Sprintf("add signature of %s to %d", acct, txIndex)
+	modelErr = d.v.AddPayloadSignature(txIndex, pk, sig)
+
+	roles := util.TxRoles{Proposer: d.f.Payer, Payer: d.f.Payer, Authorizers: []string{d.f.Payer}}
+	_, emuErr = util.SubmitPayloadSignature(d.g, roles, hex.EncodeToString(sig), txIndex, pk, d.g.FindAddress(d.f.Vault), util.DefaultVaultPaths.Signer)
+	return
+}
+
+func (d *differ) executeTx() (op string, modelErr error, emuErr error) {
+	txIndex := d.randomTxIndex()
+	op = fmt.Sprintf("execute %d", txIndex)
+	_, modelErr = d.v.ExecuteTx(txIndex)
+	_, emuErr = vault.MultiSig_VaultExecuteTx(d.g, txIndex, d.f.Payer, d.f.Vault)
+	return
+}
+
+// sign signs a payload with the key of `acct`, or with the key of another account once in a while
+func (d *differ) sign(txIndex uint64, method string, args []cadence.Value, acct string) (sig []byte, pk string) {
+	signable, err := d.v.SignableData(txIndex, method, args...)
+	assert.NoError(d.t, err)
+	pk = signer.PublicKeyHex(util.GetSigner(d.g, acct))
+	signingAcct := acct
+	if d.r.Intn(10) == 0 {
+		signingAcct = d.pick(d.accts)
+	}
+	sigHex, err := util.SignPayload(util.GetSigner(d.g, signingAcct), signable)
+	assert.NoError(d.t, err)
+	sig, err = hex.DecodeString(sigHex)
+	assert.NoError(d.t, err)
+	return
+}
+
+// randomPayload returns the method and args of a payload, and the balance of the vault it holds if any
+func (d *differ) randomPayload() (method string, args []cadence.Value, resource *cadence.UFix64) {
+	amount := d.ufix64("1.0", "5.0", "30.0", "150.0")
+	payer := cadence.BytesToAddress(d.g.Accounts[d.f.Payer].Address.Bytes())
+	recipient := d.pick([]string{d.f.Payer, d.f.Vault, d.f.Signers[0]})
+	to := cadence.BytesToAddress(d.g.Accounts[recipient].Address.Bytes())
+	pk := cadence.String(signer.PublicKeyHex(util.GetSigner(d.g, d.pick(d.accts))))
+
+	switch d.r.Intn(13) {
+	case 0:
+		return "transfer", []cadence.Value{amount, to}, nil
+	case 1:
+		return "withdraw", []cadence.Value{amount}, nil
+	case 2:
+		deposit := d.ufix64("1.0", "5.0")
+		// The amount withdrawn for a deposit must be its first arg,
+		// and the payer proposing it the address it is returned to its second
+		resource := deposit
+		if d.r.Intn(5) == 0 {
+			resource = d.ufix64("1.0", "2.0")
+		}
+		return "deposit", []cadence.Value{deposit, payer}, &resource
+	case 3:
+		return "configureKey", []cadence.Value{pk, d.ufix64("0.5", "250.0", "500.0", "1000.0"), cadence.UInt8(1)}, nil
+	case 4:
+		return "removeKey", []cadence.Value{pk}, nil
+	case 5:
+		return "removePayload", []cadence.Value{cadence.UInt64(d.r.Intn(int(d.v.TxIndex) + 2))}, nil
+	case 6:
+		period := cadence.UInt64(d.r.Intn(2) * 3)
+		return "setSpendingLimit", []cadence.Value{d.ufix64("10.0", "40.0"), period, d.ufix64("0.0", "500.0")}, nil
+	case 7:
+		return "removeSpendingLimit", []cadence.Value{}, nil
+	case 8:
+		delay := cadence.UInt64(d.r.Intn(2) * 2)
+		return "setMethodDelay", []cadence.Value{cadence.String(d.pick([]string{"transfer", "withdraw"})), delay}, nil
+	case 9:
+		if d.r.Intn(4) == 0 {
+			return "disableAllowlist", []cadence.Value{}, nil
+		}
+		return d.pick([]string{"addRecipient", "removeRecipient"}), []cadence.Value{payer}, nil
+	case 10:
+		return "setUnlistedRecipientWeight", []cadence.Value{d.ufix64("0.0", "1500.0")}, nil
+	case 11:
+		// Args the vault cannot downcast
+		return "transfer", []cadence.Value{cadence.String("1.0"), to}, nil
+	default:
+		return "unknownMethod", []cadence.Value{amount}, nil
+	}
+}
+
+// randomTxIndex returns the txIndex of a pending payload, or of no payload once in a while
+func (d *differ) randomTxIndex() uint64 {
+	pending := d.v.GetPendingPayloads()
+	if len(pending) == 0 || d.r.Intn(10) == 0 {
+		return uint64(d.r.Intn(int(d.v.TxIndex) + 2))
+	}
+	return pending[d.r.Intn(len(pending))].TxIndex
+}
+
+func (d *differ) pick(choices []string) string {
+	return choices[d.r.Intn(len(choices))]
+}
+
+func (d *differ) ufix64(choices ...string) cadence.UFix64 {
+	v, err := cadence.NewUFix64(d.pick(choices))
+	assert.NoError(d.t, err)
+	return v
+}
+
+// assertSameState asserts that the state of the vault on the emulator is the state of the model
+func (d *differ) assertSameState() {
+	t, g, v, vaultAcct := d.t, d.g, d.v, d.f.Vault
+
+	// The scripts are executed at the latest block
+	height, err := util.GetBlockHeight(g)
+	assert.NoError(t, err)
+	v.Height = height
+
+	txIndex, err := util.GetTxIndex(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, v.TxIndex, txIndex, "txIndex")
+
+	balance, err := util.GetBalance(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, v.Balance.String(), balance.String(), "balance")
+
+	weights, err := getSignerWeights(g, vaultAcct)
+	assert.NoError(t, err)
+	modelWeights := map[string]string{}
+	for pk, attr := range v.Keys {
+		modelWeights[pk] = attr.Weight.String()
+	}
+	assert.Equal(t, modelWeights, weights, "keys")
+
+	pending, err := getPendingPayloads(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, v.GetPendingPayloads(), pending, "pending payloads")
+
+	for _, method := range []string{"transfer", "withdraw"} {
+		delay, err := util.GetMethodDelay(g, vaultAcct, method)
+		assert.NoError(t, err)
+		assert.Equal(t, v.GetMethodDelay(method), delay, "delay of %s", method)
+	}
+
+	limit, err := vault.GetSpendingLimit(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, v.SpendingLimit, limit, "spending limit")
+
+	remaining, err := vault.GetRemainingSpendingLimit(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, v.GetRemainingSpendingLimit().String(), remaining.String(), "remaining spending limit")
+
+	recipients, err := vault.GetAllowedRecipients(g, vaultAcct)
+	assert.NoError(t, err)
+	var modelRecipients []string
+	for address := range v.AllowedRecipients {
+		modelRecipients = append(modelRecipients, "0x"+address.Hex())
+	}
+	sort.Strings(recipients)
+	sort.Strings(modelRecipients)
+	assert.Equal(t, modelRecipients, recipients, "allowed recipients")
+
+	enabled, err := vault.IsAllowlistEnabled(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, v.AllowlistEnabled, enabled, "allowlist enabled")
+
+	weight, err := vault.GetUnlistedRecipientWeight(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, v.UnlistedRecipientWeight.String(), weight.String(), "unlisted recipient weight")
+}
+
+func getSignerWeights(g *gwtf.GoWithTheFlow, account string) (weights map[string]string, err error) {
+	filename := "../../../scripts/get_signer_weights.cdc"
+	script := util.ParseCadenceTemplate(filename)
+	value, err := g.ScriptFromFile(filename, script).AccountArgument(account).Argument(util.DefaultVaultPaths.Signer).RunReturns()
+	if err != nil {
+		return
+	}
+	weights = map[string]string{}
+	for _, pair := range value.(cadence.Dictionary).Pairs {
+		weights[string(pair.Key.(cadence.String))] = pair.Value.(cadence.UFix64).String()
+	}
+	return
+}
+
+// getPendingPayloads returns the pending payloads of the vault on the emulator by txIndex
+func getPendingPayloads(g *gwtf.GoWithTheFlow, account string) (payloads []PayloadInfo, err error) {
+	filename := "../../../scripts/get_pending_payloads.cdc"
+	script := util.ParseCadenceTemplate(filename)
+	value, err := g.ScriptFromFile(filename, script).AccountArgument(account).Argument(util.DefaultVaultPaths.Signer).RunReturns()
+	if err != nil {
+		return
+	}
+	payloads = []PayloadInfo{}
+	for _, v := range value.(cadence.Array).Values {
+		s := v.(cadence.Struct)
+		fields := map[string]cadence.Value{}
+		for i, f := range s.StructType.Fields {
+			fields[f.Identifier] = s.Fields[i]
+		}
+		p := PayloadInfo{
+			TxIndex: uint64(fields["txIndex"].(cadence.UInt64)),
+			Method:  string(fields["method"].(cadence.String)),
+			Signers: append([]string{}, util.ConvertCadenceStringArray(fields["signers"])...),
+			Weight:  fields["weight"].(cadence.UFix64),
+		}
+		if addedAt := fields["addedAt"].(cadence.Optional).Value; addedAt != nil {
+			h := uint64(addedAt.(cadence.UInt64))
+			p.AddedAt = &h
+		}
+		if readyAt := fields["readyAt"].(cadence.Optional).Value; readyAt != nil {
+			h := uint64(readyAt.(cadence.UInt64))
+			p.ReadyAt = &h
+		}
+		payloads = append(payloads, p)
+	}
+	sort.Slice(payloads, func(i, j int) bool { return payloads[i].TxIndex < payloads[j].TxIndex })
+	return
+}
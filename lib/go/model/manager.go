@@ -0,0 +1,413 @@
+// Package model is an in-memory reference model of the `OnChainMultiSig.Manager` and `MultiSigFlowToken.Vault`
+// resources, to test the edge cases of adding, signing and executing payloads without an emulator.
+//
+// The operations of the model return the errors the contracts panic with, and like transactions
+// they do not change the state of the model if they fail. The signatures are verified as the contract verifies them,
+// so payloads are signed with `util.SignPayload` and the signable data of `SignableData`.
+package model
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/flow-hydraulics/onchain-multisig/encoder"
+	"github.com/flow-hydraulics/onchain-multisig/keys"
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/crypto"
+)
+
+// The messages of the panics of the contracts and the Cadence runtime
+var (
+	ErrUnregisteredKey    = errors.New("Public key is not a registered signer")
+	ErrKeyNotAllowed      = errors.New("Public key cannot sign for this method")
+	ErrIncorrectTxIndex   = errors.New("Incorrect txIndex provided in paylaod")
+	ErrInvalidSigner      = errors.New("Invalid signer")
+	ErrPayloadNotAdded    = errors.New("Payload has not been added")
+	ErrSignatureAdded     = errors.New("Signature already added for this txIndex")
+	ErrNoPayload          = errors.New("No payload for such index")
+	ErrNoPayloadToRemove  = errors.New("no payload at txIndex")
+	ErrTimelockNotStarted = errors.New("Payload timelock has not started")
+	ErrTimelocked         = errors.New("Payload is timelocked")
+	ErrInvalidSigAlgo     = errors.New("Invalid signature algo")
+	ErrVaultBalance       = errors.New("First arguement must be balance of Vault")
+	ErrReturnAddress      = errors.New("Second argument must be the address the Vault is returned to")
+	ErrArgOutOfBounds     = errors.New("array index out of bounds")
+	ErrForceNil           = errors.New("unexpectedly found nil while forcing an Optional value")
+)
+
+// Payload mirrors `OnChainMultiSig.PayloadDetails`
+type Payload struct {
+	TxIndex uint64
+	Method  string
+	Args    []cadence.Value
+	// Resource is the balance of the vault the payload holds, nil if it holds no resource
+	Resource *cadence.UFix64
+	// PubKeys are the keys of Signatures
+	PubKeys    []string
+	Signatures [][]byte
+	ReadyAt    *uint64
+	AddedAt    *uint64
+}
+
+// arg returns the arg at `i`, as `getArg(i: i)!` does
+func (p *Payload) arg(i int) (cadence.Value, error) {
+	if i >= len(p.Args) {
+		return nil, ErrArgOutOfBounds
+	}
+	if o, ok := p.Args[i].(cadence.Optional); ok && o.Value == nil {
+		return nil, ErrForceNil
+	}
+	return p.Args[i], nil
+}
+
+// PayloadInfo mirrors `OnChainMultiSig.PayloadInfo`
+type PayloadInfo struct {
+	TxIndex uint64
+	Method  string
+	AddedAt *uint64
+	ReadyAt *uint64
+	Signers []string
+	Weight  cadence.UFix64
+}
+
+// Manager mirrors `OnChainMultiSig.Manager`
+type Manager struct {
+	TxIndex uint64
+	// Keys are the public keys in hex without prefix and their attributes
+	Keys         map[string]keys.KeyPolicy
+	Payloads     map[uint64]*Payload
+	MethodDelays map[string]uint64
+	// Height is the height of the block the next operation is executed in, i.e. `getCurrentBlock().height`
+	Height uint64
+	// Domain is the signable domain of the resource the manager is stored in
+	Domain []byte
+}
+
+func NewManager(domain []byte) *Manager {
+	return &Manager{
+		Keys:         map[string]keys.KeyPolicy{},
+		Payloads:     map[uint64]*Payload{},
+		MethodDelays: map[string]uint64{},
+		Domain:       domain,
+	}
+}
+
+// SignableDomain returns the signable domain of the resource with uuid `resourceID` stored in `resourceOwner`,
+// with the `OnChainMultiSig` contract at `multiSigAddr`, see `OnChainMultiSig.getSignableDomain`
+func SignableDomain(multiSigAddr flow.Address, resourceOwner flow.Address, resourceID uint64) []byte {
+	domain := []byte("FLOW-ONCHAIN-MULTISIG")
+	domain = append(domain, multiSigAddr.Bytes()...)
+	domain = append(domain, resourceOwner.Bytes()...)
+	id := cadence.UInt64(resourceID).ToBigEndianBytes()
+	return append(domain, id...)
+}
+
+// SignableData returns the data signed for a payload of the resource of the manager
+func (m *Manager) SignableData(txIndex uint64, method string, args ...cadence.Value) ([]byte, error) {
+	return encoder.Encode(m.Domain, txIndex, method, args...)
+}
+
+func (m *Manager) GetSignerKeys() []string {
+	pks := []string{}
+	for pk := range m.Keys {
+		pks = append(pks, pk)
+	}
+	sort.Strings(pks)
+	return pks
+}
+
+func (m *Manager) GetMethodDelay(method string) uint64 {
+	return m.MethodDelays[method]
+}
+
+func (m *Manager) SetMethodDelay(method string, delay uint64) {
+	if delay == 0 {
+		delete(m.MethodDelays, method)
+	} else {
+		m.MethodDelays[method] = delay
+	}
+}
+
+// GetPendingPayloads returns the details of the payloads that have not been executed or removed, by txIndex
+func (m *Manager) GetPendingPayloads() []PayloadInfo {
+	infos := []PayloadInfo{}
+	for _, p := range m.Payloads {
+		signers := m.approvingKeys(p)
+		weight := cadence.UFix64(0)
+		for _, pk := range signers {
+			weight += m.Keys[pk].WeightFor(p.Method)
+		}
+		infos = append(infos, PayloadInfo{
+			TxIndex: p.TxIndex,
+			Method:  p.Method,
+			AddedAt: p.AddedAt,
+			ReadyAt: p.ReadyAt,
+			Signers: signers,
+			Weight:  weight,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].TxIndex < infos[j].TxIndex })
+	return infos
+}
+
+// ConfigureKeys adds or replaces keys, keeping the method policy of existing keys
+func (m *Manager) ConfigureKeys(pks []string, weights []cadence.UFix64, sigAlgos []uint8) {
+	for i, pk := range pks {
+		attr := keys.KeyPolicy{SigAlgo: sigAlgos[i], Weight: weights[i], MethodWeights: map[string]cadence.UFix64{}}
+		if existing, ok := m.Keys[pk]; ok {
+			attr.AllowedMethods = existing.AllowedMethods
+			attr.MethodWeights = existing.MethodWeights
+		}
+		m.Keys[pk] = attr
+	}
+}
+
+func (m *Manager) ConfigureKeyPolicy(pk string, allowedMethods []string, methodWeights map[string]cadence.UFix64) error {
+	existing, ok := m.Keys[pk]
+	if !ok {
+		return ErrUnregisteredKey
+	}
+	existing.AllowedMethods = allowedMethods
+	existing.MethodWeights = methodWeights
+	m.Keys[pk] = existing
+	return nil
+}
+
+func (m *Manager) RemoveKeys(pks []string) {
+	for _, pk := range pks {
+		delete(m.Keys, pk)
+	}
+}
+
+// AddNewPayload adds a payload signed by `publicKey`, the payload must be at the next txIndex
+func (m *Manager) AddNewPayload(p Payload, publicKey string, sig []byte) error {
+	for _, arg := range p.Args {
+		if containsPath(arg) {
+			return encoder.ErrPathNotSupported
+		}
+	}
+	if err := checkResource(p); err != nil {
+		return err
+	}
+	attr, ok := m.Keys[publicKey]
+	if !ok {
+		return ErrUnregisteredKey
+	}
+	if attr.WeightFor(p.Method) == 0 {
+		return ErrKeyNotAllowed
+	}
+	if p.TxIndex != m.TxIndex+1 {
+		return ErrIncorrectTxIndex
+	}
+	addedAt := m.Height
+	p.AddedAt = &addedAt
+	p.PubKeys = nil
+	p.Signatures = nil
+	p.ReadyAt = nil
+	weight, err := m.VerifySigners(&p, []string{publicKey}, [][]byte{sig})
+	if err != nil {
+		return err
+	}
+	if weight == nil {
+		return ErrInvalidSigner
+	}
+	p.PubKeys = []string{publicKey}
+	p.Signatures = [][]byte{sig}
+	m.TxIndex = p.TxIndex
+	m.Payloads[p.TxIndex] = &p
+	return nil
+}
+
+// containsPath returns true if `v` is a path, or an array or optional containing one, as `OnChainMultiSig.containsPath`
+func containsPath(v cadence.Value) bool {
+	switch v := v.(type) {
+	case cadence.Path:
+		return true
+	case cadence.Array:
+		for _, e := range v.Values {
+			if containsPath(e) {
+				return true
+			}
+		}
+	case cadence.Optional:
+		return v.Value != nil && containsPath(v.Value)
+	}
+	return false
+}
+
+// checkResource checks that the balance of the vault of a payload is its first arg
+// and the address it is returned to its second, as `PayloadDetails.init` does
+func checkResource(p Payload) error {
+	if p.Resource == nil {
+		return nil
+	}
+	if len(p.Args) == 0 {
+		return ErrArgOutOfBounds
+	}
+	if amount, ok := p.Args[0].(cadence.UFix64); !ok || amount != *p.Resource {
+		return ErrVaultBalance
+	}
+	if len(p.Args) < 2 {
+		return ErrReturnAddress
+	}
+	if _, ok := p.Args[1].(cadence.Address); !ok {
+		return ErrReturnAddress
+	}
+	return nil
+}
+
+// AddPayloadSignature adds a signature of `publicKey` to the payload at `txIndex`
+func (m *Manager) AddPayloadSignature(txIndex uint64, publicKey string, sig []byte) error {
+	p, ok := m.Payloads[txIndex]
+	if !ok {
+		return ErrPayloadNotAdded
+	}
+	attr, ok := m.Keys[publicKey]
+	if !ok {
+		return ErrUnregisteredKey
+	}
+	if attr.WeightFor(p.Method) == 0 {
+		return ErrKeyNotAllowed
+	}
+	for _, pk := range p.PubKeys {
+		if pk == publicKey {
+			return ErrSignatureAdded
+		}
+	}
+	weight, err := m.VerifySigners(p, []string{publicKey}, [][]byte{sig})
+	if err != nil {
+		return err
+	}
+	if weight == nil {
+		return ErrInvalidSigner
+	}
+	p.PubKeys = append(p.PubKeys, publicKey)
+	p.Signatures = append(p.Signatures, sig)
+	return nil
+}
+
+// startTimelock starts the timelock of the payload at `txIndex` if it is delayed by `delay`
+// and it has `requiredWeight` for the first time, it returns true if the timelock was started
+func (m *Manager) startTimelock(txIndex uint64, requiredWeight cadence.UFix64, delay uint64) (bool, error) {
+	p, ok := m.Payloads[txIndex]
+	if !ok {
+		return false, ErrNoPayload
+	}
+	if delay == 0 || p.ReadyAt != nil {
+		return false, nil
+	}
+	weight, err := m.VerifySigners(p, p.PubKeys, p.Signatures)
+	if err != nil {
+		return false, err
+	}
+	if weight == nil || *weight < requiredWeight {
+		return false, nil
+	}
+	readyAt := m.Height + delay
+	p.ReadyAt = &readyAt
+	return true, nil
+}
+
+// readyForExecution removes and returns the payload at `txIndex` if it has `requiredWeight`,
+// it returns nil if it does not
+func (m *Manager) readyForExecution(txIndex uint64, requiredWeight cadence.UFix64, delay uint64) (*Payload, error) {
+	p, ok := m.Payloads[txIndex]
+	if !ok {
+		return nil, ErrNoPayload
+	}
+	weight, err := m.VerifySigners(p, p.PubKeys, p.Signatures)
+	if err != nil {
+		return nil, err
+	}
+	if weight == nil || *weight < requiredWeight {
+		return nil, nil
+	}
+	if delay > 0 {
+		if p.ReadyAt == nil {
+			return nil, ErrTimelockNotStarted
+		}
+		if m.Height < *p.ReadyAt {
+			return nil, ErrTimelocked
+		}
+	}
+	delete(m.Payloads, txIndex)
+	return p, nil
+}
+
+func (m *Manager) removePayload(txIndex uint64) (*Payload, error) {
+	p, ok := m.Payloads[txIndex]
+	if !ok {
+		return nil, ErrNoPayloadToRemove
+	}
+	delete(m.Payloads, txIndex)
+	return p, nil
+}
+
+// approvingKeys returns the keys of the signatures of `p` that contribute weight with the current keys
+func (m *Manager) approvingKeys(p *Payload) []string {
+	signers := []string{}
+	for _, pk := range p.PubKeys {
+		if attr, ok := m.Keys[pk]; ok && attr.WeightFor(p.Method) > 0 {
+			signers = append(signers, pk)
+		}
+	}
+	return signers
+}
+
+// VerifySigners returns the total weight of the signatures of registered keys for the method of `p`
+// as `PayloadDetails.verifySigners` does, or nil if any of them is invalid or their total weight is less than
+// the 1.0 `Crypto.KeyList` requires. The signatures of keys that cannot sign for the method are neglected as those of
+// unregistered keys are. A key counts for each of its signatures, the `Manager` does not add duplicates
+func (m *Manager) VerifySigners(p *Payload, pks []string, sigs [][]byte) (*cadence.UFix64, error) {
+	message, err := m.SignableData(p.TxIndex, p.Method, p.Args...)
+	if err != nil {
+		return nil, err
+	}
+	message = append(flow.UserDomainTag[:], message...)
+
+	total := cadence.UFix64(0)
+	valid := true
+	for i, pk := range pks {
+		attr, ok := m.Keys[pk]
+		if !ok {
+			continue
+		}
+		weight := attr.WeightFor(p.Method)
+		if weight == 0 {
+			continue
+		}
+		sigAlgo, err := signatureAlgorithm(attr.SigAlgo)
+		if err != nil {
+			return nil, err
+		}
+		if valid {
+			valid = verify(sigAlgo, pk, sigs[i], message)
+		}
+		total += weight
+	}
+	if !valid || total < cadence.UFix64(1_0000_0000) {
+		return nil, nil
+	}
+	return &total, nil
+}
+
+// signatureAlgorithm returns the algorithm of the raw value of a Cadence `SignatureAlgorithm`
+func signatureAlgorithm(raw uint8) (crypto.SignatureAlgorithm, error) {
+	switch raw {
+	case 1:
+		return crypto.ECDSA_P256, nil
+	case 2:
+		return crypto.ECDSA_secp256k1, nil
+	}
+	return crypto.UnknownSignatureAlgorithm, ErrInvalidSigAlgo
+}
+
+func verify(sigAlgo crypto.SignatureAlgorithm, pk string, sig []byte, message []byte) bool {
+	publicKey, err := crypto.DecodePublicKeyHex(sigAlgo, pk)
+	if err != nil {
+		return false
+	}
+	valid, err := publicKey.Verify(sig, message, crypto.NewSHA3_256())
+	return err == nil && valid
+}
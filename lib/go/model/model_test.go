@@ -0,0 +1,379 @@
+package model
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+
+	util "github.com/flow-hydraulics/onchain-multisig"
+	"github.com/flow-hydraulics/onchain-multisig/encoder"
+	"github.com/flow-hydraulics/onchain-multisig/signer"
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	multiSigAddr = flow.HexToAddress("01cf0e2f2f715450")
+	ownerAddr    = flow.HexToAddress("179b6b1cb6755e31")
+	recipient    = flow.HexToAddress("f3fcd2c1a78f5eee")
+)
+
+// newTestVault returns a vault with a balance of 100.0 and a key of a new signer for each of `weights`
+func newTestVault(t *testing.T, weights ...string) (*Vault, []signer.Signer) {
+	v := NewVault(multiSigAddr, ownerAddr, 42, ufix64(t, "100.0"))
+	v.Receivers[recipient] = true
+	v.Height = 10
+	signers := []signer.Signer{}
+	for _, w := range weights {
+		seed := make([]byte, crypto.MinSeedLength)
+		_, err := rand.Read(seed)
+		assert.NoError(t, err)
+		key, err := crypto.GeneratePrivateKey(crypto.ECDSA_P256, seed)
+		assert.NoError(t, err)
+		s := signer.NewInMemorySigner(key, crypto.SHA3_256)
+		v.ConfigureKeys([]string{signer.PublicKeyHex(s)}, []cadence.UFix64{ufix64(t, w)}, []uint8{1})
+		signers = append(signers, s)
+	}
+	return v, signers
+}
+
+func ufix64(t *testing.T, s string) cadence.UFix64 {
+	v, err := cadence.NewUFix64(s)
+	assert.NoError(t, err)
+	return v
+}
+
+func sign(t *testing.T, v *Vault, s signer.Signer, txIndex uint64, method string, args ...cadence.Value) (sig []byte, pk string) {
+	signable, err := v.SignableData(txIndex, method, args...)
+	assert.NoError(t, err)
+	sigHex, err := util.SignPayload(s, signable)
+	assert.NoError(t, err)
+	sig, err = hex.DecodeString(sigHex)
+	assert.NoError(t, err)
+	return sig, signer.PublicKeyHex(s)
+}
+
+// add adds a payload signed by `signers`
+func add(t *testing.T, v *Vault, method string, args []cadence.Value, signers ...signer.Signer) uint64 {
+	txIndex := v.TxIndex + 1
+	sig, pk := sign(t, v, signers[0], txIndex, method, args...)
+	assert.NoError(t, v.AddNewPayload(Payload{TxIndex: txIndex, Method: method, Args: args}, pk, sig))
+	for _, s := range signers[1:] {
+		sig, pk := sign(t, v, s, txIndex, method, args...)
+		assert.NoError(t, v.AddPayloadSignature(txIndex, pk, sig))
+	}
+	return txIndex
+}
+
+func TestSignableDomain(t *testing.T) {
+	domain := SignableDomain(multiSigAddr, ownerAddr, 42)
+	assert.Equal(t, "FLOW-ONCHAIN-MULTISIG", string(domain[:21]))
+	assert.Equal(t, multiSigAddr.Bytes(), domain[21:29])
+	assert.Equal(t, ownerAddr.Bytes(), domain[29:37])
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 42}, domain[37:])
+}
+
+func TestPayloadExecutesWithFullApprovalWeight(t *testing.T) {
+	v, signers := newTestVault(t, "500.0", "250.0", "500.0")
+	args := []cadence.Value{ufix64(t, "15.5"), cadence.BytesToAddress(recipient.Bytes())}
+	txIndex := add(t, v, "transfer", args, signers[0], signers[1])
+
+	pending := v.GetPendingPayloads()
+	assert.Len(t, pending, 1)
+	assert.Equal(t, "750.00000000", pending[0].Weight.String())
+	assert.Equal(t, uint64(10), *pending[0].AddedAt)
+
+	_, err := v.ExecuteTx(txIndex)
+	assert.Equal(t, ErrNotReady, err)
+
+	sig, pk := sign(t, v, signers[2], txIndex, "transfer", args...)
+	assert.NoError(t, v.AddPayloadSignature(txIndex, pk, sig))
+
+	_, err = v.ExecuteTx(txIndex)
+	assert.NoError(t, err)
+	assert.Equal(t, "84.50000000", v.Balance.String())
+	assert.Empty(t, v.GetPendingPayloads())
+
+	_, err = v.ExecuteTx(txIndex)
+	assert.Equal(t, ErrNoPayload, err)
+}
+
+func TestAddNewPayloadErrors(t *testing.T) {
+	v, signers := newTestVault(t, "1000.0", "500.0")
+	_, others := newTestVault(t, "1000.0")
+	args := []cadence.Value{ufix64(t, "1.0")}
+
+	sig, pk := sign(t, v, others[0], 1, "withdraw", args...)
+	assert.Equal(t, ErrUnregisteredKey, v.AddNewPayload(Payload{TxIndex: 1, Method: "withdraw", Args: args}, pk, sig))
+
+	sig, pk = sign(t, v, signers[0], 2, "withdraw", args...)
+	assert.Equal(t, ErrIncorrectTxIndex, v.AddNewPayload(Payload{TxIndex: 2, Method: "withdraw", Args: args}, pk, sig))
+
+	// Signed by another key
+	sig, _ = sign(t, v, signers[1], 1, "withdraw", args...)
+	assert.Equal(t, ErrInvalidSigner, v.AddNewPayload(Payload{TxIndex: 1, Method: "withdraw", Args: args}, pk, sig))
+
+	// Signed for another vault
+	other, _ := newTestVault(t)
+	other.Domain = SignableDomain(multiSigAddr, recipient, 42)
+	sig, pk = sign(t, other, signers[0], 1, "withdraw", args...)
+	assert.Equal(t, ErrInvalidSigner, v.AddNewPayload(Payload{TxIndex: 1, Method: "withdraw", Args: args}, pk, sig))
+
+	// The amount withdrawn for a deposit must be its first arg, and its proposer, the address it is returned to, its second
+	resource := ufix64(t, "2.0")
+	sig, pk = sign(t, v, signers[0], 1, "deposit", args...)
+	assert.Equal(t, ErrWithdrawAmount, v.AddNewPayloadFrom(Payload{TxIndex: 1, Method: "deposit", Args: args, Resource: &resource}, pk, sig, recipient))
+	resource = args[0].(cadence.UFix64)
+	assert.Equal(t, ErrNotProposer, v.AddNewPayloadFrom(Payload{TxIndex: 1, Method: "deposit", Args: args, Resource: &resource}, pk, sig, recipient))
+	deposit := []cadence.Value{args[0], cadence.Address(recipient)}
+	sig, pk = sign(t, v, signers[0], 1, "deposit", deposit...)
+	assert.Equal(t, ErrNotProposer, v.AddNewPayloadFrom(Payload{TxIndex: 1, Method: "deposit", Args: deposit, Resource: &resource}, pk, sig, ownerAddr))
+	// and it is not added without its proposer
+	assert.Equal(t, ErrVaultPayload, v.AddNewPayload(Payload{TxIndex: 1, Method: "deposit", Args: deposit, Resource: &resource}, pk, sig))
+
+	assert.Equal(t, uint64(0), v.TxIndex)
+	assert.Empty(t, v.GetPendingPayloads())
+}
+
+func TestAddPayloadSignatureErrors(t *testing.T) {
+	v, signers := newTestVault(t, "500.0", "250.0")
+	args := []cadence.Value{ufix64(t, "1.0")}
+	txIndex := add(t, v, "withdraw", args, signers[0])
+
+	sig, pk := sign(t, v, signers[1], txIndex+1, "withdraw", args...)
+	assert.Equal(t, ErrPayloadNotAdded, v.AddPayloadSignature(txIndex+1, pk, sig))
+
+	sig, pk = sign(t, v, signers[0], txIndex, "withdraw", args...)
+	assert.Equal(t, ErrSignatureAdded, v.AddPayloadSignature(txIndex, pk, sig))
+
+	sig, pk = sign(t, v, signers[1], txIndex, "withdraw", ufix64(t, "2.0"))
+	assert.Equal(t, ErrInvalidSigner, v.AddPayloadSignature(txIndex, pk, sig))
+
+	assert.Equal(t, []string{signer.PublicKeyHex(signers[0])}, v.GetPendingPayloads()[0].Signers)
+}
+
+func TestRestrictedKeysCannotSignForOtherMethods(t *testing.T) {
+	v, signers := newTestVault(t, "500.0", "250.0")
+	restricted := signer.PublicKeyHex(signers[1])
+	assert.NoError(t, v.ConfigureKeyPolicy(restricted, []string{"deposit"}, map[string]cadence.UFix64{}))
+	args := []cadence.Value{ufix64(t, "1.0")}
+
+	// Neither with a valid signature nor with a forged one
+	sig, pk := sign(t, v, signers[1], 1, "withdraw", args...)
+	assert.Equal(t, ErrKeyNotAllowed, v.AddNewPayload(Payload{TxIndex: 1, Method: "withdraw", Args: args}, pk, sig))
+	assert.Equal(t, ErrKeyNotAllowed, v.AddNewPayload(Payload{TxIndex: 1, Method: "withdraw", Args: args}, pk, []byte{1, 2, 3}))
+
+	txIndex := add(t, v, "withdraw", args, signers[0])
+	sig, pk = sign(t, v, signers[1], txIndex, "withdraw", args...)
+	assert.Equal(t, ErrKeyNotAllowed, v.AddPayloadSignature(txIndex, pk, sig))
+	assert.Equal(t, ErrKeyNotAllowed, v.AddPayloadSignature(txIndex, pk, []byte{1, 2, 3}))
+	assert.Len(t, v.GetPendingPayloads()[0].Signers, 1)
+}
+
+func TestKeysWithLessThanOneWeightCannotSign(t *testing.T) {
+	v, signers := newTestVault(t, "0.5")
+	args := []cadence.Value{ufix64(t, "1.0")}
+	sig, pk := sign(t, v, signers[0], 1, "withdraw", args...)
+	assert.Equal(t, ErrInvalidSigner, v.AddNewPayload(Payload{TxIndex: 1, Method: "withdraw", Args: args}, pk, sig))
+}
+
+func TestRemovedKeysDoNotCount(t *testing.T) {
+	v, signers := newTestVault(t, "1000.0", "500.0", "500.0")
+	args := []cadence.Value{ufix64(t, "1.0")}
+	withdrawal := add(t, v, "withdraw", args, signers[1], signers[2])
+
+	removal := add(t, v, "removeKey", []cadence.Value{cadence.String(signer.PublicKeyHex(signers[1]))}, signers[0])
+	_, err := v.ExecuteTx(removal)
+	assert.NoError(t, err)
+	assert.Equal(t, "500.00000000", v.GetPendingPayloads()[0].Weight.String())
+
+	_, err = v.ExecuteTx(withdrawal)
+	assert.Equal(t, ErrNotReady, err)
+
+	// Without any of its signers the weight of the payload cannot be verified
+	removal = add(t, v, "removeKey", []cadence.Value{cadence.String(signer.PublicKeyHex(signers[2]))}, signers[0])
+	_, err = v.ExecuteTx(removal)
+	assert.NoError(t, err)
+
+	_, err = v.ExecuteTx(withdrawal)
+	assert.Equal(t, ErrNotReady, err)
+	assert.Len(t, v.GetPendingPayloads(), 1)
+}
+
+func TestFailedExecutionDoesNotChangeState(t *testing.T) {
+	v, signers := newTestVault(t, "1000.0")
+	args := []cadence.Value{ufix64(t, "150.0")}
+	txIndex := add(t, v, "withdraw", args, signers[0])
+
+	_, err := v.ExecuteTx(txIndex)
+	assert.Equal(t, ErrInsufficientBalance, err)
+	assert.Equal(t, "100.00000000", v.Balance.String())
+	assert.Len(t, v.GetPendingPayloads(), 1)
+
+	txIndex = add(t, v, "transfer", []cadence.Value{cadence.String("1.0"), cadence.BytesToAddress(recipient.Bytes())}, signers[0])
+	_, err = v.ExecuteTx(txIndex)
+	assert.EqualError(t, err, "cannot downcast amount")
+	assert.Len(t, v.GetPendingPayloads(), 2)
+}
+
+func TestPayloadWithPathArgIsRejected(t *testing.T) {
+	v, signers := newTestVault(t, "1000.0")
+	path := cadence.Path{Domain: "public", Identifier: "vaultReceive"}
+	for _, arg := range []cadence.Value{path, cadence.NewOptional(cadence.NewArray([]cadence.Value{path}))} {
+		pk := signer.PublicKeyHex(signers[0])
+		assert.Equal(t, encoder.ErrPathNotSupported, v.AddNewPayload(Payload{TxIndex: 1, Method: "transfer", Args: []cadence.Value{arg}}, pk, nil))
+	}
+	assert.Empty(t, v.GetPendingPayloads())
+}
+
+func TestRemovedPayloadReturnsItsResource(t *testing.T) {
+	v, signers := newTestVault(t, "1000.0")
+	args := []cadence.Value{ufix64(t, "2.0"), cadence.Address(recipient)}
+	resource := args[0].(cadence.UFix64)
+	sig, pk := sign(t, v, signers[0], 1, "deposit", args...)
+	assert.NoError(t, v.AddNewPayloadFrom(Payload{TxIndex: 1, Method: "deposit", Args: args, Resource: &resource}, pk, sig, recipient))
+
+	// The vault is returned to the receiver of the address it recorded, not to the executor
+	removal := add(t, v, "removePayload", []cadence.Value{cadence.UInt64(1)}, signers[0])
+	returned, err := v.ExecuteTx(removal)
+	assert.NoError(t, err)
+	assert.Nil(t, returned)
+	assert.Empty(t, v.GetPendingPayloads())
+	assert.Equal(t, "100.00000000", v.Balance.String())
+
+	removal = add(t, v, "removePayload", []cadence.Value{cadence.UInt64(1)}, signers[0])
+	_, err = v.ExecuteTx(removal)
+	assert.Equal(t, ErrNoPayloadToRemove, err)
+
+	// A deposit cannot be proposed for an address without a receiver
+	other := flow.HexToAddress("0x0a")
+	args[1] = cadence.Address(other)
+	deposit := v.TxIndex + 1
+	sig, pk = sign(t, v, signers[0], deposit, "deposit", args...)
+	assert.Equal(t, ErrNoReturnReceiver, v.AddNewPayloadFrom(Payload{TxIndex: deposit, Method: "deposit", Args: args, Resource: &resource}, pk, sig, other))
+	assert.Equal(t, deposit-1, v.TxIndex)
+
+	// and it is deposited back to the vault if the receiver is unlinked, so that the removal cannot be blocked
+	v.Receivers[other] = true
+	assert.NoError(t, v.AddNewPayloadFrom(Payload{TxIndex: deposit, Method: "deposit", Args: args, Resource: &resource}, pk, sig, other))
+	delete(v.Receivers, other)
+	removal = add(t, v, "removePayload", []cadence.Value{cadence.UInt64(deposit)}, signers[0])
+	returned, err = v.ExecuteTx(removal)
+	assert.NoError(t, err)
+	assert.Nil(t, returned)
+	assert.NotContains(t, v.Payloads, deposit)
+	assert.Equal(t, "102.00000000", v.Balance.String())
+}
+
+func TestTimelockStartsOnceThereIsEnoughWeight(t *testing.T) {
+	v, signers := newTestVault(t, "1000.0", "500.0")
+	v.SetMethodDelay("withdraw", 5)
+	txIndex := add(t, v, "withdraw", []cadence.Value{ufix64(t, "1.0")}, signers[1])
+
+	// The timelock has not started without enough weight
+	_, err := v.ExecuteTx(txIndex)
+	assert.Equal(t, ErrNotReady, err)
+	assert.Nil(t, v.GetPendingPayloads()[0].ReadyAt)
+
+	sig, pk := sign(t, v, signers[0], txIndex, "withdraw", ufix64(t, "1.0"))
+	assert.NoError(t, v.AddPayloadSignature(txIndex, pk, sig))
+
+	returned, err := v.ExecuteTx(txIndex)
+	assert.NoError(t, err)
+	assert.Nil(t, returned)
+	assert.Equal(t, uint64(15), *v.GetPendingPayloads()[0].ReadyAt)
+
+	v.Height = 14
+	_, err = v.ExecuteTx(txIndex)
+	assert.Equal(t, ErrTimelocked, err)
+
+	v.Height = 15
+	returned, err = v.ExecuteTx(txIndex)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.00000000", returned.String())
+}
+
+func TestMethodDelayCannotBeLoweredBeforeItPasses(t *testing.T) {
+	v, signers := newTestVault(t, "1000.0")
+	v.SetMethodDelay("withdraw", 5)
+	txIndex := add(t, v, "setMethodDelay", []cadence.Value{cadence.String("withdraw"), cadence.UInt64(0)}, signers[0])
+
+	// The payload is timelocked by the delay of the method it changes
+	_, err := v.ExecuteTx(txIndex)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(15), *v.GetPendingPayloads()[0].ReadyAt)
+	assert.Equal(t, uint64(5), v.GetMethodDelay("withdraw"))
+
+	v.Height = 14
+	_, err = v.ExecuteTx(txIndex)
+	assert.Equal(t, ErrTimelocked, err)
+
+	v.Height = 15
+	_, err = v.ExecuteTx(txIndex)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), v.GetMethodDelay("withdraw"))
+
+	// Raising a delay from none is not timelocked
+	txIndex = add(t, v, "setMethodDelay", []cadence.Value{cadence.String("transfer"), cadence.UInt64(3)}, signers[0])
+	_, err = v.ExecuteTx(txIndex)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), v.GetMethodDelay("transfer"))
+}
+
+func TestSpendingLimitAndRecipientAllowlist(t *testing.T) {
+	v, signers := newTestVault(t, "1000.0", "500.0")
+	to := cadence.BytesToAddress(recipient.Bytes())
+
+	txIndex := add(t, v, "setSpendingLimit", []cadence.Value{ufix64(t, "10.0"), cadence.UInt64(3), ufix64(t, "500.0")}, signers[0])
+	_, err := v.ExecuteTx(txIndex)
+	assert.NoError(t, err)
+
+	txIndex = add(t, v, "transfer", []cadence.Value{ufix64(t, "6.0"), to}, signers[1])
+	_, err = v.ExecuteTx(txIndex)
+	assert.NoError(t, err)
+	assert.Equal(t, "4.00000000", v.GetRemainingSpendingLimit().String())
+
+	txIndex = add(t, v, "transfer", []cadence.Value{ufix64(t, "6.0"), to}, signers[1])
+	_, err = v.ExecuteTx(txIndex)
+	assert.Equal(t, ErrNotReady, err)
+
+	v.Height += 3
+	assert.Equal(t, "10.00000000", v.GetRemainingSpendingLimit().String())
+	_, err = v.ExecuteTx(txIndex)
+	assert.NoError(t, err)
+
+	// Only the owner is allowed once the allowlist is set
+	txIndex = add(t, v, "addRecipient", []cadence.Value{cadence.BytesToAddress(ownerAddr.Bytes())}, signers[0])
+	_, err = v.ExecuteTx(txIndex)
+	assert.NoError(t, err)
+
+	txIndex = add(t, v, "transfer", []cadence.Value{ufix64(t, "1.0"), to}, signers[0])
+	_, err = v.ExecuteTx(txIndex)
+	assert.Equal(t, ErrRecipientNotAllowed, err)
+	assert.Equal(t, "88.00000000", v.Balance.String())
+
+	// and withdrawals are rejected as transfers to an unlisted recipient
+	withdrawal := add(t, v, "withdraw", []cadence.Value{ufix64(t, "1.0")}, signers[0])
+	_, err = v.ExecuteTx(withdrawal)
+	assert.Equal(t, ErrRecipientNotAllowed, err)
+
+	// Removing the last address keeps the allowlist enabled
+	removal := add(t, v, "removeRecipient", []cadence.Value{cadence.BytesToAddress(ownerAddr.Bytes())}, signers[0])
+	_, err = v.ExecuteTx(removal)
+	assert.NoError(t, err)
+	assert.True(t, v.AllowlistEnabled)
+	_, err = v.ExecuteTx(txIndex)
+	assert.Equal(t, ErrRecipientNotAllowed, err)
+
+	disable := add(t, v, "disableAllowlist", []cadence.Value{}, signers[0])
+	_, err = v.ExecuteTx(disable)
+	assert.NoError(t, err)
+	assert.False(t, v.AllowlistEnabled)
+	_, err = v.ExecuteTx(txIndex)
+	assert.NoError(t, err)
+	_, err = v.ExecuteTx(withdrawal)
+	assert.NoError(t, err)
+	assert.Equal(t, "86.00000000", v.Balance.String())
+}
@@ -0,0 +1,224 @@
+package model
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/bjartek/go-with-the-flow/gwtf"
+	util "github.com/flow-hydraulics/onchain-multisig"
+	"github.com/flow-hydraulics/onchain-multisig/signer"
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+// The number of random payloads of the signature property tests
+const signingPayloads = 50
+
+// mutation changes what is signed, the signature of the payload before the change must be invalid after it
+type mutation struct {
+	name   string
+	mutate func(p *Payload, domain []byte) []byte
+}
+
+var mutations = []mutation{
+	{"txIndex+1", func(p *Payload, domain []byte) []byte { p.TxIndex++; return domain }},
+	{"txIndex-1", func(p *Payload, domain []byte) []byte { p.TxIndex--; return domain }},
+	{"method", func(p *Payload, domain []byte) []byte { p.Method = otherMethod(p.Method); return domain }},
+	{"method prefix", func(p *Payload, domain []byte) []byte { p.Method = p.Method[:len(p.Method)-1]; return domain }},
+	{"arg dropped", func(p *Payload, domain []byte) []byte { p.Args = p.Args[:len(p.Args)-1]; return domain }},
+	{"arg added", func(p *Payload, domain []byte) []byte {
+		p.Args = append(append([]cadence.Value{}, p.Args...), cadence.String(""))
+		return domain
+	}},
+	{"arg changed", func(p *Payload, domain []byte) []byte {
+		p.Args = withArg(p.Args, 0, changeValue(p.Args[0]))
+		return domain
+	}},
+	{"arg type changed", func(p *Payload, domain []byte) []byte {
+		p.Args = withArg(p.Args, 0, changeType(p.Args[0]))
+		return domain
+	}},
+	{"args swapped", func(p *Payload, domain []byte) []byte {
+		p.Args = withArg(withArg(p.Args, 0, p.Args[1]), 1, p.Args[0])
+		return domain
+	}},
+	{"vault uuid", func(p *Payload, domain []byte) []byte { return SignableDomain(multiSigAddr, ownerAddr, 43) }},
+	{"vault owner", func(p *Payload, domain []byte) []byte { return SignableDomain(multiSigAddr, recipient, 42) }},
+	{"multisig contract", func(p *Payload, domain []byte) []byte { return SignableDomain(recipient, ownerAddr, 42) }},
+}
+
+func withArg(args []cadence.Value, i int, v cadence.Value) []cadence.Value {
+	args = append([]cadence.Value{}, args...)
+	args[i] = v
+	return args
+}
+
+func otherMethod(method string) string {
+	if method == "transfer" {
+		return "withdraw"
+	}
+	return "transfer"
+}
+
+// changeValue returns a value of the same type as `v` and a different value
+func changeValue(v cadence.Value) cadence.Value {
+	switch v := v.(type) {
+	case cadence.UFix64:
+		return v + 1
+	case cadence.UInt64:
+		return v + 1
+	case cadence.String:
+		return v + "a"
+	case cadence.Address:
+		v[7]++
+		return v
+	}
+	panic(fmt.Sprintf("no change of %T", v))
+}
+
+// changeType returns a value of another type with the same bytes as `v`
+func changeType(v cadence.Value) cadence.Value {
+	switch v := v.(type) {
+	case cadence.UFix64:
+		return cadence.UInt64(v)
+	case cadence.UInt64:
+		return cadence.UFix64(v)
+	case cadence.String:
+		return cadence.NewOptional(v)
+	case cadence.Address:
+		return cadence.String(v[:])
+	}
+	panic(fmt.Sprintf("no type change of %T", v))
+}
+
+// randomSigningPayload returns a payload of a random method with random args of at least two values
+func randomSigningPayload(r *rand.Rand) Payload {
+	p := Payload{TxIndex: uint64(r.Int63n(1000)) + 1}
+	switch r.Intn(4) {
+	case 0:
+		p.Method = "transfer"
+		p.Args = []cadence.Value{cadence.UFix64(r.Int63()), cadence.BytesToAddress(recipient.Bytes())}
+	case 1:
+		p.Method = "configureKey"
+		p.Args = []cadence.Value{cadence.String(fmt.Sprintf("%x", r.Int63())), cadence.UFix64(r.Int63()), cadence.UInt8(1)}
+	case 2:
+		p.Method = "setMethodDelay"
+		p.Args = []cadence.Value{cadence.String("transfer"), cadence.UInt64(r.Int63())}
+	case 3:
+		p.Method = "setSpendingLimit"
+		p.Args = []cadence.Value{cadence.UFix64(r.Int63()), cadence.UInt64(r.Int63n(100) + 1), cadence.UFix64(r.Int63())}
+	}
+	return p
+}
+
+func newSigner(t *testing.T, r *rand.Rand) signer.Signer {
+	seed := make([]byte, crypto.MinSeedLength)
+	_, err := r.Read(seed)
+	assert.NoError(t, err)
+	key, err := crypto.GeneratePrivateKey(crypto.ECDSA_P256, seed)
+	assert.NoError(t, err)
+	return signer.NewInMemorySigner(key, crypto.SHA3_256)
+}
+
+func signBytes(t *testing.T, s signer.Signer, message []byte) []byte {
+	sigHex, err := util.SignPayload(s, message)
+	assert.NoError(t, err)
+	sig, err := hex.DecodeString(sigHex)
+	assert.NoError(t, err)
+	return sig
+}
+
+// verifies returns true if both Go and the model accept `sig` of `s` for `p` of a vault with the signable domain `domain`
+func verifies(t *testing.T, s signer.Signer, domain []byte, p Payload, sig []byte) bool {
+	m := NewManager(domain)
+	m.ConfigureKeys([]string{signer.PublicKeyHex(s)}, []cadence.UFix64{FullApprovalWeight}, []uint8{1})
+	signable, err := m.SignableData(p.TxIndex, p.Method, p.Args...)
+	assert.NoError(t, err)
+	valid, err := util.VerifyPayloadSignature(s.PublicKey(), sig, signable)
+	assert.NoError(t, err)
+
+	m.TxIndex = p.TxIndex - 1
+	err = m.AddNewPayload(p, signer.PublicKeyHex(s), sig)
+	if valid {
+		assert.NoError(t, err)
+	} else {
+		assert.Equal(t, ErrInvalidSigner, err)
+	}
+	return valid
+}
+
+func TestMutatedPayloadsInvalidateSignatures(t *testing.T) {
+	s := *seed
+	if s == 0 {
+		s = time.Now().UnixNano()
+	}
+	t.Logf("seed %d", s)
+	r := rand.New(rand.NewSource(s))
+	domain := SignableDomain(multiSigAddr, ownerAddr, 42)
+
+	for i := 0; i < signingPayloads; i++ {
+		key := newSigner(t, r)
+		p := randomSigningPayload(r)
+		signable, err := NewManager(domain).SignableData(p.TxIndex, p.Method, p.Args...)
+		assert.NoError(t, err)
+		sig := signBytes(t, key, signable)
+		if !assert.True(t, verifies(t, key, domain, p, sig), "%+v", p) {
+			continue
+		}
+		for _, m := range mutations {
+			mutated := p
+			mutatedDomain := m.mutate(&mutated, domain)
+			assert.False(t, verifies(t, key, mutatedDomain, mutated, sig), "%s of %+v", m.name, p)
+		}
+
+		// Signatures of other keys and other signatures of the key are invalid
+		assert.False(t, verifies(t, newSigner(t, r), domain, p, sig), "other key of %+v", p)
+		otherSig := signBytes(t, key, append(signable, 0))
+		assert.False(t, verifies(t, key, domain, p, otherSig), "other signature of %+v", p)
+		for _, b := range []int{0, len(sig) / 2, len(sig) - 1} {
+			flipped := append([]byte{}, sig...)
+			flipped[b] ^= 1
+			assert.False(t, verifies(t, key, domain, p, flipped), "flipped signature byte %d of %+v", b, p)
+		}
+	}
+}
+
+func TestSignPayloadOffline(t *testing.T) {
+	g := gwtf.NewGoWithTheFlow("../../../flow.json")
+	message := []byte("signable data")
+	pk := g.Accounts["w-1000"].PrivateKey.PublicKey()
+
+	sigHex, err := util.SignPayloadOffline(g, message, "w-1000")
+	assert.NoError(t, err)
+	sig, err := hex.DecodeString(sigHex)
+	assert.NoError(t, err)
+	valid, err := util.VerifyPayloadSignature(pk, sig, message)
+	assert.NoError(t, err)
+	assert.True(t, valid)
+	valid, err = util.VerifyPayloadSignature(pk, sig, append(message, 0))
+	assert.NoError(t, err)
+	assert.False(t, valid)
+	// Signatures are made with the user domain tag
+	valid, err = pk.Verify(sig, message, crypto.NewSHA3_256())
+	assert.NoError(t, err)
+	assert.False(t, valid)
+
+	// The signer set for the account signs instead of its key in flow.json
+	s := newSigner(t, rand.New(rand.NewSource(1)))
+	util.SetSigner("w-1000", s)
+	defer util.SetSigner("w-1000", nil)
+	sigHex, err = util.SignPayloadOffline(g, message, "w-1000")
+	assert.NoError(t, err)
+	sig, err = hex.DecodeString(sigHex)
+	assert.NoError(t, err)
+	valid, err = util.VerifyPayloadSignature(pk, sig, message)
+	assert.NoError(t, err)
+	assert.False(t, valid)
+	valid, err = util.VerifyPayloadSignature(s.PublicKey(), sig, message)
+	assert.NoError(t, err)
+	assert.True(t, valid)
+}
@@ -0,0 +1,469 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/flow-hydraulics/onchain-multisig/keys"
+	"github.com/flow-hydraulics/onchain-multisig/vault"
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+)
+
+// FullApprovalWeight is `MultiSigFlowToken.getFullApprovalWeight()`
+const FullApprovalWeight = cadence.UFix64(1000_0000_0000)
+
+var (
+	ErrNotReady            = errors.New("no transactable payload at given txIndex")
+	ErrNoReceiver          = errors.New("Unable to borrow receiver reference for recipient")
+	ErrRecipientNotAllowed = errors.New("Recipient is not in the allowlist")
+	ErrLimitPeriod         = errors.New("Spending limit period must be at least one block")
+	ErrLimitWeight         = errors.New("Spending limit must require some approval weight")
+	ErrInsufficientBalance = errors.New("Amount withdrawn must be less than or equal than the balance of the Vault")
+	ErrMigrateByOwner      = errors.New("migrate payloads are executed by the owner of the vault with migrate")
+	ErrNoReturnReceiver    = errors.New("The proposer has no receiver to return the vault to")
+	ErrVaultPayload        = errors.New("Payloads holding a vault must be added with addNewPayloadFrom")
+	ErrWithdrawAmount      = errors.New("First argument must be the amount withdrawn")
+	ErrNotProposer         = errors.New("Second argument must be the address of the proposer")
+)
+
+// Vault mirrors `MultiSigFlowToken.Vault`
+type Vault struct {
+	Manager
+	UUID    uint64
+	Owner   flow.Address
+	Balance cadence.UFix64
+	// SpendingLimit is nil if the vault has no spending limit
+	SpendingLimit *vault.SpendingLimit
+	// Spent are the amounts spent under the spending limit by the height they were spent at
+	Spent map[uint64]cadence.UFix64
+	// AllowlistEnabled is set by the first `addRecipient` until `disableAllowlist`
+	AllowlistEnabled        bool
+	AllowedRecipients       map[flow.Address]bool
+	UnlistedRecipientWeight cadence.UFix64
+	// Receivers are the addresses with a `MultiSigFlowToken` receiver that transfers can be deposited to,
+	// transfers to the owner are deposited back to the vault
+	Receivers map[flow.Address]bool
+}
+
+// NewVault returns a vault with no keys, with uuid `uuid` and stored in `owner`,
+// the `OnChainMultiSig` contract is at `multiSigAddr`
+func NewVault(multiSigAddr flow.Address, owner flow.Address, uuid uint64, balance cadence.UFix64) *Vault {
+	return &Vault{
+		Manager:           *NewManager(SignableDomain(multiSigAddr, owner, uuid)),
+		UUID:              uuid,
+		Owner:             owner,
+		Balance:           balance,
+		Spent:             map[uint64]cadence.UFix64{},
+		AllowedRecipients: map[flow.Address]bool{},
+		Receivers:         map[flow.Address]bool{owner: true},
+	}
+}
+
+// AddNewPayload adds a payload signed by `publicKey` as `Manager.AddNewPayload` does,
+// payloads holding a vault are added with AddNewPayloadFrom
+func (v *Vault) AddNewPayload(p Payload, publicKey string, sig []byte) error {
+	if p.Resource != nil {
+		return ErrVaultPayload
+	}
+	return v.Manager.AddNewPayload(p, publicKey, sig)
+}
+
+// AddNewPayloadFrom adds a payload holding a vault withdrawn from the account `proposer`,
+// as `add_new_payload.cdc` does with `addNewPayloadFrom`. The amount withdrawn, its Resource, must be its first arg
+// and `proposer` its second, which must have a receiver to return the vault to
+func (v *Vault) AddNewPayloadFrom(p Payload, publicKey string, sig []byte, proposer flow.Address) error {
+	if p.Resource == nil || len(p.Args) == 0 {
+		return ErrWithdrawAmount
+	}
+	if amount, ok := p.Args[0].(cadence.UFix64); !ok || amount != *p.Resource {
+		return ErrWithdrawAmount
+	}
+	if to, ok := returnAddress(&p); !ok || to != proposer {
+		return ErrNotProposer
+	}
+	next := v.clone()
+	if err := next.Manager.AddNewPayload(p, publicKey, sig); err != nil {
+		return err
+	}
+	if !v.Receivers[proposer] {
+		return ErrNoReturnReceiver
+	}
+	*v = *next
+	return nil
+}
+
+// ExecuteTx executes the payload at `txIndex` if it has the required weight,
+// it returns the balance of the vault returned to the account executing it, if any.
+// The vault of a removed payload is returned to the receiver of its proposer instead, or deposited back to the vault
+// if the proposer has unlinked it.
+// The timelock of a payload of a delayed method is started instead the first time it has the required weight
+func (v *Vault) ExecuteTx(txIndex uint64) (returned *cadence.UFix64, err error) {
+	next := v.clone()
+	returned, err = next.executeTx(txIndex)
+	if err == nil {
+		*v = *next
+	}
+	return
+}
+
+func (v *Vault) executeTx(txIndex uint64) (*cadence.UFix64, error) {
+	if p, ok := v.Payloads[txIndex]; ok && p.Method == "migrate" {
+		return nil, ErrMigrateByOwner
+	}
+	requiredWeight, err := v.getRequiredWeight(txIndex)
+	if err != nil {
+		return nil, err
+	}
+	delay, err := v.getDelay(txIndex)
+	if err != nil {
+		return nil, err
+	}
+	started, err := v.startTimelock(txIndex, requiredWeight, delay)
+	if err != nil || started {
+		return nil, err
+	}
+	p, err := v.readyForExecution(txIndex, requiredWeight, delay)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrNotReady
+	}
+
+	switch p.Method {
+	case "configureKey":
+		pk, err := stringArg(p, 0, "public key")
+		if err != nil {
+			return nil, err
+		}
+		weight, err := ufix64Arg(p, 1, "weight")
+		if err != nil {
+			return nil, err
+		}
+		sigAlgo, err := uint8Arg(p, 2, "sigAlgo")
+		if err != nil {
+			return nil, err
+		}
+		v.ConfigureKeys([]string{pk}, []cadence.UFix64{weight}, []uint8{sigAlgo})
+	case "removeKey":
+		pk, err := stringArg(p, 0, "public key")
+		if err != nil {
+			return nil, err
+		}
+		v.RemoveKeys([]string{pk})
+	case "removePayload":
+		index, err := uint64Arg(p, 0, "txIndex")
+		if err != nil {
+			return nil, err
+		}
+		removed, err := v.removePayload(index)
+		if err != nil {
+			return nil, err
+		}
+		// The vault is returned to the receiver of its proposer, the address it recorded,
+		// or deposited back to the vault so that the proposer cannot block the removal
+		if to, ok := returnAddress(removed); ok {
+			if !v.Receivers[to] || to == v.Owner {
+				v.Balance += *removed.Resource
+			}
+			return nil, nil
+		}
+		return removed.Resource, nil
+	case "withdraw":
+		amount, err := ufix64Arg(p, 0, "amount")
+		if err != nil {
+			return nil, err
+		}
+		v.spendWithinLimit(amount)
+		if err := v.withdraw(amount); err != nil {
+			return nil, err
+		}
+		return &amount, nil
+	case "deposit":
+		if p.Resource == nil {
+			return nil, ErrForceNil
+		}
+		v.Balance += *p.Resource
+	case "transfer":
+		amount, err := ufix64Arg(p, 0, "amount")
+		if err != nil {
+			return nil, err
+		}
+		to, err := addressArg(p, 1, "address")
+		if err != nil {
+			return nil, err
+		}
+		if !v.Receivers[to] {
+			return nil, ErrNoReceiver
+		}
+		v.spendWithinLimit(amount)
+		if err := v.withdraw(amount); err != nil {
+			return nil, err
+		}
+		if to == v.Owner {
+			v.Balance += amount
+		}
+	case "setSpendingLimit":
+		limit, err := ufix64Arg(p, 0, "limit")
+		if err != nil {
+			return nil, err
+		}
+		period, err := uint64Arg(p, 1, "period")
+		if err != nil {
+			return nil, err
+		}
+		weight, err := ufix64Arg(p, 2, "required weight")
+		if err != nil {
+			return nil, err
+		}
+		if period == 0 {
+			return nil, ErrLimitPeriod
+		}
+		if weight == 0 {
+			return nil, ErrLimitWeight
+		}
+		v.SpendingLimit = &vault.SpendingLimit{Limit: limit, Period: period, RequiredWeight: weight}
+		v.Spent = map[uint64]cadence.UFix64{}
+	case "removeSpendingLimit":
+		v.SpendingLimit = nil
+		v.Spent = map[uint64]cadence.UFix64{}
+	case "setMethodDelay":
+		method, err := stringArg(p, 0, "method")
+		if err != nil {
+			return nil, err
+		}
+		delay, err := uint64Arg(p, 1, "delay")
+		if err != nil {
+			return nil, err
+		}
+		v.SetMethodDelay(method, delay)
+	case "addRecipient":
+		address, err := addressArg(p, 0, "address")
+		if err != nil {
+			return nil, err
+		}
+		v.AllowedRecipients[address] = true
+		v.AllowlistEnabled = true
+	case "removeRecipient":
+		address, err := addressArg(p, 0, "address")
+		if err != nil {
+			return nil, err
+		}
+		delete(v.AllowedRecipients, address)
+	case "disableAllowlist":
+		v.AllowedRecipients = map[flow.Address]bool{}
+		v.AllowlistEnabled = false
+	case "setUnlistedRecipientWeight":
+		weight, err := ufix64Arg(p, 0, "weight")
+		if err != nil {
+			return nil, err
+		}
+		v.UnlistedRecipientWeight = weight
+	}
+	return nil, nil
+}
+
+// getRequiredWeight returns the approval weight required to execute the payload at `txIndex`
+func (v *Vault) getRequiredWeight(txIndex uint64) (cadence.UFix64, error) {
+	p, ok := v.Payloads[txIndex]
+	if !ok {
+		return 0, ErrNoPayload
+	}
+	weight := FullApprovalWeight
+	if v.SpendingLimit != nil && (p.Method == "withdraw" || p.Method == "transfer") {
+		amount, err := ufix64Arg(p, 0, "amount")
+		if err != nil {
+			return 0, err
+		}
+		if amount <= v.GetRemainingSpendingLimit() {
+			weight = v.SpendingLimit.RequiredWeight
+		}
+	}
+	// Withdrawals are restricted as transfers to an address outside of the allowlist
+	if p.Method == "transfer" || p.Method == "withdraw" {
+		allowed := !v.AllowlistEnabled
+		if p.Method == "transfer" {
+			to, err := addressArg(p, 1, "address")
+			if err != nil {
+				return 0, err
+			}
+			allowed = v.IsAllowedRecipient(to)
+		}
+		if !allowed {
+			if v.UnlistedRecipientWeight == 0 {
+				return 0, ErrRecipientNotAllowed
+			}
+			if v.UnlistedRecipientWeight > weight {
+				weight = v.UnlistedRecipientWeight
+			}
+		}
+	}
+	return weight, nil
+}
+
+// getDelay returns the number of blocks the payload at `txIndex` is timelocked for,
+// raised to the current delay of the method a `setMethodDelay` payload changes
+func (v *Vault) getDelay(txIndex uint64) (uint64, error) {
+	p, ok := v.Payloads[txIndex]
+	if !ok {
+		return 0, ErrNoPayload
+	}
+	delay := v.GetMethodDelay(p.Method)
+	if p.Method == "setMethodDelay" {
+		method, err := stringArg(p, 0, "method")
+		if err != nil {
+			return 0, err
+		}
+		if methodDelay := v.GetMethodDelay(method); methodDelay > delay {
+			delay = methodDelay
+		}
+	}
+	return delay, nil
+}
+
+// returnAddress returns the address the vault held by `p` is returned to if it is removed, its second arg,
+// false if it holds no vault or has no such arg
+func returnAddress(p *Payload) (flow.Address, bool) {
+	if p.Resource == nil || len(p.Args) < 2 {
+		return flow.EmptyAddress, false
+	}
+	to, err := addressArg(p, 1, "address")
+	return to, err == nil
+}
+
+// spendWithinLimit records `amount` as spent under the spending limit if it is within it
+func (v *Vault) spendWithinLimit(amount cadence.UFix64) {
+	if v.SpendingLimit == nil || amount > v.GetRemainingSpendingLimit() {
+		return
+	}
+	for h := range v.Spent {
+		if h+v.SpendingLimit.Period <= v.Height {
+			delete(v.Spent, h)
+		}
+	}
+	v.Spent[v.Height] += amount
+}
+
+func (v *Vault) withdraw(amount cadence.UFix64) error {
+	if amount > v.Balance {
+		return ErrInsufficientBalance
+	}
+	v.Balance -= amount
+	return nil
+}
+
+// GetRemainingSpendingLimit returns the amount that can still be spent under the spending limit in the current period
+func (v *Vault) GetRemainingSpendingLimit() cadence.UFix64 {
+	if v.SpendingLimit == nil {
+		return 0
+	}
+	spent := cadence.UFix64(0)
+	for h, amount := range v.Spent {
+		if h+v.SpendingLimit.Period > v.Height {
+			spent += amount
+		}
+	}
+	if spent >= v.SpendingLimit.Limit {
+		return 0
+	}
+	return v.SpendingLimit.Limit - spent
+}
+
+// IsAllowedRecipient returns true if transfers can be sent to `address` without the unlisted recipient weight
+func (v *Vault) IsAllowedRecipient(address flow.Address) bool {
+	return !v.AllowlistEnabled || v.AllowedRecipients[address]
+}
+
+// clone returns a copy of the vault that does not share state with it
+func (v *Vault) clone() *Vault {
+	c := *v
+	c.Keys = map[string]keys.KeyPolicy{}
+	for pk, attr := range v.Keys {
+		c.Keys[pk] = attr
+	}
+	c.Payloads = map[uint64]*Payload{}
+	for txIndex, p := range v.Payloads {
+		cp := *p
+		cp.PubKeys = append([]string{}, p.PubKeys...)
+		cp.Signatures = append([][]byte{}, p.Signatures...)
+		c.Payloads[txIndex] = &cp
+	}
+	c.MethodDelays = map[string]uint64{}
+	for method, delay := range v.MethodDelays {
+		c.MethodDelays[method] = delay
+	}
+	c.Spent = map[uint64]cadence.UFix64{}
+	for h, amount := range v.Spent {
+		c.Spent[h] = amount
+	}
+	c.AllowedRecipients = map[flow.Address]bool{}
+	for address := range v.AllowedRecipients {
+		c.AllowedRecipients[address] = true
+	}
+	return &c
+}
+
+func downcastError(what string) error {
+	return fmt.Errorf("cannot downcast %s", what)
+}
+
+func stringArg(p *Payload, i int, what string) (string, error) {
+	a, err := p.arg(i)
+	if err != nil {
+		return "", err
+	}
+	s, ok := a.(cadence.String)
+	if !ok {
+		return "", downcastError(what)
+	}
+	return string(s), nil
+}
+
+func ufix64Arg(p *Payload, i int, what string) (cadence.UFix64, error) {
+	a, err := p.arg(i)
+	if err != nil {
+		return 0, err
+	}
+	u, ok := a.(cadence.UFix64)
+	if !ok {
+		return 0, downcastError(what)
+	}
+	return u, nil
+}
+
+func uint64Arg(p *Payload, i int, what string) (uint64, error) {
+	a, err := p.arg(i)
+	if err != nil {
+		return 0, err
+	}
+	u, ok := a.(cadence.UInt64)
+	if !ok {
+		return 0, downcastError(what)
+	}
+	return uint64(u), nil
+}
+
+func uint8Arg(p *Payload, i int, what string) (uint8, error) {
+	a, err := p.arg(i)
+	if err != nil {
+		return 0, err
+	}
+	u, ok := a.(cadence.UInt8)
+	if !ok {
+		return 0, downcastError(what)
+	}
+	return uint8(u), nil
+}
+
+func addressArg(p *Payload, i int, what string) (flow.Address, error) {
+	a, err := p.arg(i)
+	if err != nil {
+		return flow.EmptyAddress, err
+	}
+	address, ok := a.(cadence.Address)
+	if !ok {
+		return flow.EmptyAddress, downcastError(what)
+	}
+	return flow.BytesToAddress(address.Bytes()), nil
+}
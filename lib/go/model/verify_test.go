@@ -0,0 +1,254 @@
+package model
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/bjartek/go-with-the-flow/gwtf"
+	util "github.com/flow-hydraulics/onchain-multisig"
+	"github.com/flow-hydraulics/onchain-multisig/emulator"
+	"github.com/flow-hydraulics/onchain-multisig/keys"
+	"github.com/flow-hydraulics/onchain-multisig/signer"
+	"github.com/flow-hydraulics/onchain-multisig/vault"
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+// signature is a signature of a payload submitted to the vault as the signature of the key of `as`
+type signature struct {
+	by string
+	as string
+	// rejected is the error the vault is expected to reject the signature with, nil if it accepts it
+	rejected error
+}
+
+// verifyCase signs a transfer with `sigs`, changes the keys of the vault with `change`, then executes the transfer
+type verifyCase struct {
+	name   string
+	sigs   func(f vault.Fixture) []signature
+	change func(t *testing.T, f vault.Fixture)
+}
+
+func signedBy(accts ...string) []signature {
+	sigs := []signature{}
+	for _, acct := range accts {
+		sigs = append(sigs, signature{by: acct, as: acct})
+	}
+	return sigs
+}
+
+var verifyCases = []verifyCase{
+	{name: "single key", sigs: func(f vault.Fixture) []signature { return signedBy(f.Signers[3]) }},
+	{name: "several keys", sigs: func(f vault.Fixture) []signature { return signedBy(f.Signers[1], f.Signers[3], f.Signers[4]) }},
+	{name: "full weight", sigs: func(f vault.Fixture) []signature { return signedBy(f.Signers[0], f.Signers[1]) }},
+	{name: "duplicated key", sigs: func(f vault.Fixture) []signature {
+		return append(signedBy(f.Signers[1], f.Signers[3]), signature{by: f.Signers[1], as: f.Signers[1], rejected: ErrSignatureAdded})
+	}},
+	{name: "unregistered key", sigs: func(f vault.Fixture) []signature {
+		return append(signedBy(f.Signers[3]), signature{by: f.Payer, as: f.Payer, rejected: ErrUnregisteredKey})
+	}},
+	{name: "signature of another key", sigs: func(f vault.Fixture) []signature {
+		return append(signedBy(f.Signers[3]), signature{by: f.Signers[2], as: f.Signers[4], rejected: ErrInvalidSigner})
+	}},
+	{
+		name:   "removed key",
+		sigs:   func(f vault.Fixture) []signature { return signedBy(f.Signers[1], f.Signers[3]) },
+		change: func(t *testing.T, f vault.Fixture) { removeKey(t, f, f.Signers[1]) },
+	},
+	{
+		name:   "reconfigured key",
+		sigs:   func(f vault.Fixture) []signature { return signedBy(f.Signers[3], f.Signers[4]) },
+		change: func(t *testing.T, f vault.Fixture) { configureKey(t, f, f.Signers[3], "0.5") },
+	},
+	{
+		name:   "weight below one",
+		sigs:   func(f vault.Fixture) []signature { return signedBy(f.Signers[3]) },
+		change: func(t *testing.T, f vault.Fixture) { configureKey(t, f, f.Signers[3], "0.5") },
+	},
+	{
+		name: "all signers removed",
+		sigs: func(f vault.Fixture) []signature { return signedBy(f.Signers[3], f.Signers[4]) },
+		change: func(t *testing.T, f vault.Fixture) {
+			removeKey(t, f, f.Signers[3])
+			removeKey(t, f, f.Signers[4])
+		},
+	},
+}
+
+// TestVerifySignersMatchesContract checks that the weight of the signatures of a payload verified in Go
+// is the weight `PayloadDetails.verifySigners` executes the payload with
+func TestVerifySignersMatchesContract(t *testing.T) {
+	if testing.Short() {
+		t.Skip("verifying signers on the contract needs an emulator")
+	}
+	e, err := emulator.Start("../../..")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	// The parallel cases run after the test returns
+	t.Cleanup(e.Stop)
+	g := e.GoWithTheFlow()
+
+	for _, c := range verifyCases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			f := vault.NewTestFixture(t, g)
+			// Small transfers need only the weight of a valid signature set
+			execute(t, f, proposeSpendingLimit(t, f, "10.0", "1.0"))
+
+			domain, err := util.GetSignableDomain(g, f.Vault)
+			assert.NoError(t, err)
+			txIndex, err := util.GetTxIndex(g, f.Vault)
+			assert.NoError(t, err)
+			p := Payload{
+				TxIndex: txIndex + 1,
+				Method:  "transfer",
+				Args:    []cadence.Value{ufix64(t, "1.0"), cadence.BytesToAddress(g.Accounts[f.Payer].Address.Bytes())},
+			}
+			pks, sigs := submitSignatures(t, f, NewManager(domain), p, c.sigs(f))
+
+			if c.change != nil {
+				c.change(t, f)
+			}
+			weights, err := getSignerWeights(g, f.Vault)
+			assert.NoError(t, err)
+			weight := verifiedWeight(t, domain, p, weights, pks, sigs)
+
+			m := NewManager(domain)
+			for pk, w := range weights {
+				m.ConfigureKeys([]string{pk}, []cadence.UFix64{ufix64(t, w)}, []uint8{1})
+			}
+			modelWeight, err := m.VerifySigners(&p, pks, sigs)
+			assert.NoError(t, err)
+			assert.Equal(t, weight, modelWeight)
+
+			events, err := vault.MultiSig_VaultExecuteTx(g, p.TxIndex, f.Payer, f.Vault)
+			if weight == nil {
+				// `readyForExecution` keeps payloads that cannot be verified
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), ErrNotReady.Error())
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			executed := findEvent(events, "PayloadExecuted")
+			if assert.NotNil(t, executed) {
+				assert.Equal(t, weight.String(), executed.Fields["weight"])
+				// Signatures of removed keys are neglected
+				approving := []interface{}{}
+				for _, pk := range pks {
+					if _, ok := weights[pk]; ok {
+						approving = append(approving, pk)
+					}
+				}
+				assert.Equal(t, approving, executed.Fields["signers"])
+			}
+		})
+	}
+}
+
+// submitSignatures submits `sigs` of `p` and returns the keys and signatures the vault accepted.
+// The model `m` is only used for signable data and to check the errors of rejected signatures
+func submitSignatures(t *testing.T, f vault.Fixture, m *Manager, p Payload, sigs []signature) (pks []string, accepted [][]byte) {
+	signable, err := m.SignableData(p.TxIndex, p.Method, p.Args...)
+	assert.NoError(t, err)
+	roles := util.TxRoles{Proposer: f.Payer, Payer: f.Payer, Authorizers: []string{f.Payer}}
+	resourceAddr := f.G.FindAddress(f.Vault)
+
+	for i, s := range sigs {
+		sig := signBytes(t, util.GetSigner(f.G, s.by), signable)
+		pk := signer.PublicKeyHex(util.GetSigner(f.G, s.as))
+		if i == 0 {
+			_, err = util.SubmitNewPayload(f.G, roles, hex.EncodeToString(sig), p.TxIndex, p.Method, p.Args, pk, resourceAddr, util.DefaultVaultPaths.Signer, "0.0")
+		} else {
+			_, err = util.SubmitPayloadSignature(f.G, roles, hex.EncodeToString(sig), p.TxIndex, pk, resourceAddr, util.DefaultVaultPaths.Signer)
+		}
+		if s.rejected != nil {
+			if assert.Error(t, err, "signature of %s as %s", s.by, s.as) {
+				assert.Contains(t, err.Error(), s.rejected.Error())
+			}
+			continue
+		}
+		if !assert.NoError(t, err, "signature of %s as %s", s.by, s.as) {
+			t.FailNow()
+		}
+		pks = append(pks, pk)
+		accepted = append(accepted, sig)
+	}
+	return
+}
+
+// verifiedWeight returns the total weight in `weights` of the keys of `sigs` that Go verifies,
+// or nil if any of them is invalid or the total weight is less than 1.0
+func verifiedWeight(t *testing.T, domain []byte, p Payload, weights map[string]string, pks []string, sigs [][]byte) *cadence.UFix64 {
+	signable, err := NewManager(domain).SignableData(p.TxIndex, p.Method, p.Args...)
+	assert.NoError(t, err)
+	total := cadence.UFix64(0)
+	for i, pk := range pks {
+		w, ok := weights[pk]
+		if !ok {
+			continue
+		}
+		publicKey, err := crypto.DecodePublicKeyHex(crypto.ECDSA_P256, pk)
+		assert.NoError(t, err)
+		valid, err := util.VerifyPayloadSignature(publicKey, sigs[i], signable)
+		assert.NoError(t, err)
+		if !valid {
+			return nil
+		}
+		total += ufix64(t, w)
+	}
+	if total < ufix64(t, "1.0") {
+		return nil
+	}
+	return &total
+}
+
+func findEvent(events []*gwtf.FormatedEvent, name string) *gwtf.FormatedEvent {
+	for _, e := range events {
+		if strings.HasSuffix(e.Name, "."+name) {
+			return e
+		}
+	}
+	return nil
+}
+
+// proposeSpendingLimit adds a spending limit payload signed with full weight and returns its txIndex
+func proposeSpendingLimit(t *testing.T, f vault.Fixture, limit string, requiredWeight string) uint64 {
+	txIndex := nextTxIndex(t, f)
+	_, err := vault.MultiSig_SetSpendingLimit(f.G, limit, 1000, requiredWeight, txIndex, f.Signers[0], f.Vault, true)
+	assert.NoError(t, err)
+	return txIndex
+}
+
+func removeKey(t *testing.T, f vault.Fixture, acct string) {
+	txIndex := nextTxIndex(t, f)
+	_, err := keys.MultiSig_RemoveKey(f.G, acct, txIndex, f.Signers[0], f.Vault, true)
+	assert.NoError(t, err)
+	execute(t, f, txIndex)
+}
+
+func configureKey(t *testing.T, f vault.Fixture, acct string, weight string) {
+	txIndex := nextTxIndex(t, f)
+	_, err := keys.MultiSig_ConfigKey(f.G, acct, weight, txIndex, f.Signers[0], f.Vault, true)
+	assert.NoError(t, err)
+	execute(t, f, txIndex)
+}
+
+func nextTxIndex(t *testing.T, f vault.Fixture) uint64 {
+	txIndex, err := util.GetTxIndex(f.G, f.Vault)
+	assert.NoError(t, err)
+	return txIndex + 1
+}
+
+func execute(t *testing.T, f vault.Fixture, txIndex uint64) {
+	_, err := vault.MultiSig_VaultExecuteTx(f.G, txIndex, f.Payer, f.Vault)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+}
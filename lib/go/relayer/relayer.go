@@ -0,0 +1,221 @@
+// Package relayer submits the signatures of multisig signers to their vaults
+// from a pool of payer accounts, so that signers never need a funded Flow account.
+//
+// A submission is a detached signature with the payload it signs.
+// The relayer verifies the signature off chain against the key list of the vault,
+// then adds the payload if it is at the next txIndex of the vault, or the signature to the payload otherwise.
+// Each key is rate limited so that a key holder cannot spend the balance of the payers.
+// Deposits, whose payloads hold tokens of the account adding them, are rejected, see coordinator.Relayable.
+package relayer
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	util "github.com/flow-hydraulics/onchain-multisig"
+	"github.com/flow-hydraulics/onchain-multisig/coordinator"
+	"github.com/flow-hydraulics/onchain-multisig/encoder"
+	"github.com/onflow/cadence"
+	jsoncdc "github.com/onflow/cadence/encoding/json"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/crypto"
+)
+
+var (
+	ErrBadRequest  = errors.New("bad request")
+	ErrRateLimited = errors.New("rate limited")
+)
+
+type Submission struct {
+	Vault   string `json:"vault"`
+	TxIndex uint64 `json:"txIndex"`
+	Method  string `json:"method"`
+	// Args are JSON-Cadence encoded
+	Args      []json.RawMessage `json:"args"`
+	PublicKey string            `json:"publicKey"`
+	// Signature is the hex encoded signature of the signable data of the payload, made as in `util.SignPayload`
+	Signature string `json:"signature"`
+}
+
+type Action string
+
+const (
+	ActionAddNewPayload       Action = "addNewPayload"
+	ActionAddPayloadSignature Action = "addPayloadSignature"
+)
+
+type Result struct {
+	Action  Action `json:"action"`
+	TxIndex uint64 `json:"txIndex"`
+}
+
+// Relayer submits verified signatures with the next free payer of its pool
+type Relayer struct {
+	payers  chan coordinator.Chain
+	limiter *RateLimiter
+
+	mu sync.Mutex
+	// vaults serialises the submissions to each vault, so that a payload is only added once
+	vaults map[flow.Address]*sync.Mutex
+}
+
+// New returns a relayer paying with `payers`, each of which submits one transaction at a time.
+// `limiter` may be nil for no rate limit
+func New(payers []coordinator.Chain, limiter *RateLimiter) *Relayer {
+	r := &Relayer{
+		payers:  make(chan coordinator.Chain, len(payers)),
+		limiter: limiter,
+		vaults:  map[flow.Address]*sync.Mutex{},
+	}
+	for _, p := range payers {
+		r.payers <- p
+	}
+	return r
+}
+
+func (r *Relayer) vaultLock(vault flow.Address) *sync.Mutex {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.vaults[vault]; !ok {
+		r.vaults[vault] = &sync.Mutex{}
+	}
+	return r.vaults[vault]
+}
+
+// Submit verifies `s` and submits it to its vault
+func (r *Relayer) Submit(s Submission) (*Result, error) {
+	vault, err := parseAddress(s.Vault)
+	if err != nil {
+		return nil, err
+	}
+	if s.Method == "" {
+		return nil, fmt.Errorf("%w: method is required", ErrBadRequest)
+	}
+	if !coordinator.Relayable(s.Method) {
+		return nil, fmt.Errorf("%w: %s payloads hold tokens of the account adding them and must be added by their signers", ErrBadRequest, s.Method)
+	}
+	args := make([]cadence.Value, len(s.Args))
+	for i, arg := range s.Args {
+		args[i], err = jsoncdc.Decode(arg)
+		if err != nil {
+			return nil, fmt.Errorf("%w: arg %d is not JSON-Cadence: %s", ErrBadRequest, i, err)
+		}
+	}
+	publicKey := strings.TrimPrefix(s.PublicKey, "0x")
+
+	lock := r.vaultLock(vault)
+	lock.Lock()
+	defer lock.Unlock()
+	payer := <-r.payers
+	defer func() { r.payers <- payer }()
+
+	txIndex, err := payer.GetTxIndex(vault)
+	if err != nil {
+		return nil, err
+	}
+	if s.TxIndex == 0 || s.TxIndex > txIndex+1 {
+		return nil, fmt.Errorf("%w: txIndex %d is neither a payload of vault %s nor the next txIndex %d", ErrBadRequest, s.TxIndex, vault.Hex(), txIndex+1)
+	}
+	if err := verify(payer, vault, s.TxIndex, s.Method, args, publicKey, s.Signature); err != nil {
+		return nil, err
+	}
+	if r.limiter != nil && !r.limiter.Allow(publicKey) {
+		return nil, fmt.Errorf("%w: too many submissions by %s", ErrRateLimited, publicKey)
+	}
+
+	if s.TxIndex == txIndex+1 {
+		if err := payer.AddNewPayload(vault, s.TxIndex, s.Method, args, publicKey, s.Signature); err != nil {
+			return nil, err
+		}
+		return &Result{Action: ActionAddNewPayload, TxIndex: s.TxIndex}, nil
+	}
+	if err := payer.AddPayloadSignature(vault, s.TxIndex, publicKey, s.Signature); err != nil {
+		return nil, err
+	}
+	return &Result{Action: ActionAddPayloadSignature, TxIndex: s.TxIndex}, nil
+}
+
+// verify checks the signature is by a signer of the vault for the payload in the submission,
+// a payload already on chain with other args makes the submission fail on chain
+func verify(chain coordinator.Chain, vault flow.Address, txIndex uint64, method string, args []cadence.Value, publicKey string, sig string) error {
+	sigAlgo, ok, err := chain.GetSignerSigAlgo(vault, publicKey)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s is not a signer of vault %s", ErrBadRequest, publicKey, vault.Hex())
+	}
+	pk, err := crypto.DecodePublicKeyHex(sigAlgo, publicKey)
+	if err != nil {
+		return fmt.Errorf("%w: invalid public key: %s", ErrBadRequest, err)
+	}
+	sigBytes, err := hex.DecodeString(sig)
+	if err != nil {
+		return fmt.Errorf("%w: signature must be hex encoded", ErrBadRequest)
+	}
+	domain, err := chain.GetSignableDomain(vault)
+	if err != nil {
+		return err
+	}
+	signable, err := encoder.Encode(domain, txIndex, method, args...)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBadRequest, err)
+	}
+	valid, err := util.VerifyPayloadSignature(pk, sigBytes, signable)
+	if err != nil || !valid {
+		return fmt.Errorf("%w: invalid signature", ErrBadRequest)
+	}
+	return nil
+}
+
+// RateLimiter allows each key `max` submissions in any `period`
+type RateLimiter struct {
+	max    int
+	period time.Duration
+	now    func() time.Time
+
+	mu          sync.Mutex
+	submissions map[string][]time.Time
+}
+
+func NewRateLimiter(max int, period time.Duration) *RateLimiter {
+	return &RateLimiter{
+		max:         max,
+		period:      period,
+		now:         time.Now,
+		submissions: map[string][]time.Time{},
+	}
+}
+
+// Allow records a submission by `key` if it is within the limit of the key
+func (l *RateLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	recent := []time.Time{}
+	for _, t := range l.submissions[key] {
+		if now.Sub(t) < l.period {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= l.max {
+		l.submissions[key] = recent
+		return false
+	}
+	l.submissions[key] = append(recent, now)
+	return true
+}
+
+func parseAddress(s string) (flow.Address, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil || len(b) != flow.AddressLength {
+		return flow.EmptyAddress, fmt.Errorf("%w: invalid address %s", ErrBadRequest, s)
+	}
+	return flow.BytesToAddress(b), nil
+}
@@ -0,0 +1,221 @@
+package relayer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bjartek/go-with-the-flow/gwtf"
+	util "github.com/flow-hydraulics/onchain-multisig"
+	"github.com/flow-hydraulics/onchain-multisig/coordinator"
+	"github.com/flow-hydraulics/onchain-multisig/signer"
+	"github.com/flow-hydraulics/onchain-multisig/vault"
+	"github.com/onflow/cadence"
+	jsoncdc "github.com/onflow/cadence/encoding/json"
+	"github.com/onflow/flow-go-sdk/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+const vaultAcct = "vaulted-account"
+
+var payerAccts = []string{"owner", "non-registered-account"}
+
+func newTestServer(g *gwtf.GoWithTheFlow, limiter *RateLimiter) *httptest.Server {
+	payers := []coordinator.Chain{}
+	for _, acct := range payerAccts {
+		payers = append(payers, coordinator.NewFlowChain(g, acct, "../../.."))
+	}
+	return httptest.NewServer(NewHandler(New(payers, limiter)))
+}
+
+func submit(t *testing.T, url string, s Submission) (int, *Result) {
+	b, err := json.Marshal(s)
+	assert.NoError(t, err)
+	resp, err := http.Post(url+SubmissionsEndpoint, "application/json", bytes.NewReader(b))
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var msg bytes.Buffer
+		_, _ = msg.ReadFrom(resp.Body)
+		t.Log(msg.String())
+		return resp.StatusCode, nil
+	}
+	var result Result
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	return resp.StatusCode, &result
+}
+
+// transferSubmission signs a transfer payload from the vault with the signer of `signerAcct`
+func transferSubmission(t *testing.T, g *gwtf.GoWithTheFlow, txIndex uint64, amount string, to string, signerAcct string) Submission {
+	ufix64, err := cadence.NewUFix64(amount)
+	assert.NoError(t, err)
+	toAddr := cadence.BytesToAddress(g.Accounts[to].Address.Bytes())
+
+	signable, err := util.GetSignableDataFromScript(g, vaultAcct, txIndex, "transfer", ufix64, toAddr)
+	assert.NoError(t, err)
+	s := util.GetSigner(g, signerAcct)
+	sig, err := util.SignPayload(s, signable)
+	assert.NoError(t, err)
+
+	args := []json.RawMessage{}
+	for _, arg := range []cadence.Value{ufix64, toAddr} {
+		b, err := jsoncdc.Encode(arg)
+		assert.NoError(t, err)
+		args = append(args, b)
+	}
+	return Submission{
+		Vault:     util.GetAccountAddr(g, vaultAcct),
+		TxIndex:   txIndex,
+		Method:    "transfer",
+		Args:      args,
+		PublicKey: signer.PublicKeyHex(s),
+		Signature: sig,
+	}
+}
+
+func TestRelayedSignaturesAreExecuted(t *testing.T) {
+	g := emu.GoWithTheFlow()
+	server := newTestServer(g, nil)
+	defer server.Close()
+
+	txIndex, err := util.GetTxIndex(g, vaultAcct)
+	assert.NoError(t, err)
+	txIndex = txIndex + 1
+	initFromBalance, err := util.GetBalance(g, vaultAcct)
+	assert.NoError(t, err)
+
+	status, result := submit(t, server.URL, transferSubmission(t, g, txIndex, "1.0", "owner", vault.Acct500_2))
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, &Result{Action: ActionAddNewPayload, TxIndex: txIndex}, result)
+
+	status, result = submit(t, server.URL, transferSubmission(t, g, txIndex, "1.0", "owner", vault.Acct1000))
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, &Result{Action: ActionAddPayloadSignature, TxIndex: txIndex}, result)
+
+	_, err = vault.MultiSig_VaultExecuteTx(g, txIndex, "owner", vaultAcct)
+	assert.NoError(t, err)
+
+	postFromBalance, err := util.GetBalance(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.00000000", (initFromBalance - postFromBalance).String())
+}
+
+func TestRelayerRejectsInvalidSubmissions(t *testing.T) {
+	g := emu.GoWithTheFlow()
+	server := newTestServer(g, nil)
+	defer server.Close()
+
+	txIndex, err := util.GetTxIndex(g, vaultAcct)
+	assert.NoError(t, err)
+
+	// A signature for another payload
+	s := transferSubmission(t, g, txIndex+1, "1.0", "owner", vault.Acct1000)
+	s.Args = transferSubmission(t, g, txIndex+1, "2.0", "owner", vault.Acct1000).Args
+	status, _ := submit(t, server.URL, s)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// A key that is not a signer of the vault
+	seed := make([]byte, crypto.MinSeedLength)
+	_, err = rand.Read(seed)
+	assert.NoError(t, err)
+	unregistered, err := crypto.GeneratePrivateKey(crypto.ECDSA_P256, seed)
+	assert.NoError(t, err)
+	s = transferSubmission(t, g, txIndex+1, "1.0", "owner", vault.Acct1000)
+	s.PublicKey = signer.PublicKeyHex(signer.NewInMemorySigner(unregistered, crypto.SHA3_256))
+	status, _ = submit(t, server.URL, s)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// A txIndex after the next one
+	status, _ = submit(t, server.URL, transferSubmission(t, g, txIndex+2, "1.0", "owner", vault.Acct1000))
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	postTxIndex, err := util.GetTxIndex(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, txIndex, postTxIndex)
+}
+
+func TestRelayerRejectsDeposits(t *testing.T) {
+	g := emu.GoWithTheFlow()
+	server := newTestServer(g, nil)
+	defer server.Close()
+
+	txIndex, err := util.GetTxIndex(g, vaultAcct)
+	assert.NoError(t, err)
+	payerBalance, err := util.GetBalance(g, payerAccts[0])
+	assert.NoError(t, err)
+
+	amount, err := cadence.NewUFix64("10.0")
+	assert.NoError(t, err)
+	returnAddr := cadence.BytesToAddress(g.Accounts[payerAccts[0]].Address.Bytes())
+	signable, err := util.GetSignableDataFromScript(g, vaultAcct, txIndex+1, "deposit", amount, returnAddr)
+	assert.NoError(t, err)
+	s := util.GetSigner(g, vault.Acct1000)
+	sig, err := util.SignPayload(s, signable)
+	assert.NoError(t, err)
+	args := []json.RawMessage{}
+	for _, arg := range []cadence.Value{amount, returnAddr} {
+		b, err := jsoncdc.Encode(arg)
+		assert.NoError(t, err)
+		args = append(args, b)
+	}
+	status, _ := submit(t, server.URL, Submission{
+		Vault:     util.GetAccountAddr(g, vaultAcct),
+		TxIndex:   txIndex + 1,
+		Method:    "deposit",
+		Args:      args,
+		PublicKey: signer.PublicKeyHex(s),
+		Signature: sig,
+	})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	postTxIndex, err := util.GetTxIndex(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, txIndex, postTxIndex)
+	postPayerBalance, err := util.GetBalance(g, payerAccts[0])
+	assert.NoError(t, err)
+	assert.Equal(t, payerBalance, postPayerBalance)
+}
+
+func TestRelayerRateLimitsEachKey(t *testing.T) {
+	g := emu.GoWithTheFlow()
+	server := newTestServer(g, NewRateLimiter(1, time.Hour))
+	defer server.Close()
+
+	txIndex, err := util.GetTxIndex(g, vaultAcct)
+	assert.NoError(t, err)
+	txIndex = txIndex + 1
+
+	status, _ := submit(t, server.URL, transferSubmission(t, g, txIndex, "1.0", "owner", vault.Acct500_2))
+	assert.Equal(t, http.StatusOK, status)
+
+	status, _ = submit(t, server.URL, transferSubmission(t, g, txIndex+1, "1.0", "owner", vault.Acct500_2))
+	assert.Equal(t, http.StatusTooManyRequests, status)
+
+	// Other keys have their own limit
+	status, _ = submit(t, server.URL, transferSubmission(t, g, txIndex, "1.0", "owner", vault.Acct1000))
+	assert.Equal(t, http.StatusOK, status)
+
+	_, err = vault.MultiSig_VaultExecuteTx(g, txIndex, "owner", vaultAcct)
+	assert.NoError(t, err)
+}
+
+func TestRateLimiterWindow(t *testing.T) {
+	now := time.Now()
+	l := NewRateLimiter(2, time.Minute)
+	l.now = func() time.Time { return now }
+
+	assert.True(t, l.Allow("a"))
+	now = now.Add(30 * time.Second)
+	assert.True(t, l.Allow("a"))
+	assert.False(t, l.Allow("a"))
+	assert.True(t, l.Allow("b"))
+
+	// The first submission leaves the window
+	now = now.Add(31 * time.Second)
+	assert.True(t, l.Allow("a"))
+	assert.False(t, l.Allow("a"))
+}
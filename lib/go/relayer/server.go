@@ -0,0 +1,41 @@
+package relayer
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// POST {url}/submissions with a Submission relays it and returns a Result.
+// Errors are returned with a non 2xx status and the error message as the body,
+// 429 if the key of the submission is rate limited
+const SubmissionsEndpoint = "/submissions"
+
+// NewHandler serves the relayer API of `r`
+func NewHandler(r *Relayer) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(SubmissionsEndpoint, func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var s Submission
+		if err := json.NewDecoder(req.Body).Decode(&s); err != nil {
+			http.Error(w, "invalid submission", http.StatusBadRequest)
+			return
+		}
+		result, err := r.Submit(s)
+		switch {
+		case errors.Is(err, ErrBadRequest):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, ErrRateLimited):
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(result)
+		}
+	})
+	return mux
+}
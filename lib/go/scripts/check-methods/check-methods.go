@@ -0,0 +1,25 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/flow-hydraulics/onchain-multisig/checker"
+)
+
+func main() {
+	// The relative paths are the same as for scripts/deploy, which is run from lib/go
+	root := flag.String("root", "../..", "path to the root of the repository, where the contracts and scripts are")
+	flag.Parse()
+
+	report, err := checker.Check(*root)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(report)
+	if !report.OK() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/flow-hydraulics/onchain-multisig/upgrade"
+)
+
+func main() {
+	// The relative paths are the same as for scripts/deploy, which is run from lib/go
+	root := flag.String("root", "../..", "path to the root of the repository, a git repository for -rev")
+	contract := flag.String("contract", "contracts/OnChainMultiSig.cdc", "path of the new version of the contract, relative to the root")
+	old := flag.String("old", "", "path of the old version of the contract")
+	rev := flag.String("rev", "", "git revision of the old version of the contract, instead of -old")
+	flag.Parse()
+
+	newCode, err := ioutil.ReadFile(filepath.Join(*root, *contract))
+	if err != nil {
+		log.Fatal(err)
+	}
+	var oldCode []byte
+	switch {
+	case *old != "":
+		oldCode, err = ioutil.ReadFile(*old)
+	case *rev != "":
+		cmd := exec.Command("git", "show", fmt.Sprintf("%s:%s", *rev, filepath.ToSlash(*contract)))
+		cmd.Dir = *root
+		cmd.Stderr = os.Stderr
+		oldCode, err = cmd.Output()
+	default:
+		log.Fatal("either -old or -rev is required")
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	report, err := upgrade.Check(upgrade.ResolveImports(oldCode), upgrade.ResolveImports(newCode))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(report)
+	if !report.OK() {
+		os.Exit(1)
+	}
+}
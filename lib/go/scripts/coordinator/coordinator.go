@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bjartek/go-with-the-flow/gwtf"
+	util "github.com/flow-hydraulics/onchain-multisig"
+	"github.com/flow-hydraulics/onchain-multisig/coordinator"
+)
+
+func main() {
+	// The relative paths are the same as for scripts/deploy, which is run from lib/go
+	flowJSON := flag.String("flow", "../../flow.json", "path to flow.json")
+	root := flag.String("root", "../..", "path to the root of the repository, where the scripts and transactions are")
+	payer := flag.String("payer", "owner", "account in flow.json that pays for the submitted transactions")
+	dbPath := flag.String("db", "coordinator.db", "path to the proposals database")
+	addr := flag.String("addr", ":8080", "address to listen on")
+	flushInterval := flag.Duration("flush", 10*time.Second, "interval to retry failed submissions at")
+	ttl := flag.Duration("ttl", coordinator.DefaultProposalTTL, "time a proposal can stay pending before it expires, 0 for never")
+	txTimeout := flag.Duration("tx-timeout", util.DefaultTxTimeout, "time a submission waits for its transaction to be sealed")
+	flag.Parse()
+
+	g := gwtf.NewGoWithTheFlow(*flowJSON)
+	store, err := coordinator.OpenStore(*dbPath)
+	if err != nil {
+		log.Fatalf("Cannot open store: %s", err)
+	}
+	defer store.Close()
+
+	chain := coordinator.NewFlowChain(g, *payer, *root)
+	chain.TxTimeout = *txTimeout
+	c := coordinator.NewCoordinator(store, chain)
+	c.ProposalTTL = *ttl
+	if err := c.Reconcile(); err != nil {
+		log.Fatalf("Reconcile: %s", err)
+	}
+	go func() {
+		for range time.Tick(*flushInterval) {
+			if err := c.Flush(); err != nil {
+				log.Printf("Flush: %s", err)
+			}
+		}
+	}()
+
+	log.Printf("Coordinator listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, coordinator.NewHandler(c)))
+}
@@ -1,42 +1,79 @@
 package main
 
 import (
-	"encoding/hex"
+	"flag"
 	"log"
+	"os"
+	"path/filepath"
+	"sort"
 
 	"github.com/bjartek/go-with-the-flow/gwtf"
 	util "github.com/flow-hydraulics/onchain-multisig"
+	"github.com/flow-hydraulics/onchain-multisig/deploy"
+	"github.com/flow-hydraulics/onchain-multisig/upgrade"
 )
 
 func main() {
-	// This relative path to flow.json is different in tests as it is the main package
-	g := gwtf.NewGoWithTheFlow("../../flow.json")
+	// This relative path to the root of the repository is different in tests as it is the main package
+	root := flag.String("root", "../..", "path to the root of the repository, where the contracts and transactions are")
+	network := flag.String("network", "emulator", "network of the manifest to deploy")
+	manifest := flag.String("manifest", "", "path of the manifest, deploy.json in the root by default")
+	flowJSON := flag.String("flow", "", "path of the flow.json with the accounts, the one in the root by default")
+	host := flag.String("host", "", "access API to deploy to, the host of the network in the manifest by default")
+	out := flag.String("out", "", "path of the addresses file, deployments/<network>.json in the root by default")
+	flag.Parse()
 
-	contractCode := util.ParseCadenceTemplate("../../contracts/MultiSigFlowToken.cdc")
-	txFilename := "../../transactions/deploy_contract_with_auth.cdc"
-	code := util.ParseCadenceTemplate(txFilename)
-	encodedStr := hex.EncodeToString(contractCode)
-	g.CreateAccountPrintEvents(
-		"vaulted-account",
-		"w-1000",
-		"w-500-1",
-		"w-500-2",
-		"w-250-1",
-		"w-250-2",
-		"non-registered-account",
-	)
-	// The "owner" defined in flow.json is the owner of the contracts:
-	// - `MultiSigFlowToken`
-	// - `OnChainMultiSig`
-	e, err := g.TransactionFromFile(txFilename, code).
-		SignProposeAndPayAs("owner").
-		StringArgument("MultiSigFlowToken").
-		StringArgument(encodedStr).
-		Run()
+	if *manifest == "" {
+		*manifest = filepath.Join(*root, deploy.ManifestFile)
+	}
+	if *flowJSON == "" {
+		*flowJSON = filepath.Join(*root, "flow.json")
+	}
+	if *out == "" {
+		*out = filepath.Join(*root, "deployments", *network+".json")
+	}
+
+	m, err := deploy.LoadManifest(*manifest)
+	if err != nil {
+		log.Fatal(err)
+	}
+	n, ok := m[*network]
+	if !ok {
+		log.Fatalf("%s has no network %s", *manifest, *network)
+	}
+	g, err := gwtf.NewGoWithTheFlowError(*flowJSON)
+	if err != nil {
+		log.Fatal(err)
+	}
+	g.Address = n.Host
+	if *host != "" {
+		g.Address = *host
+	}
 
+	// The accounts created by an earlier deployment to the network are at their address in its addresses file
+	previous, err := deploy.LoadDeployment(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	d := &deploy.Deployer{G: g, Root: *root, Logf: log.Printf, CheckUpdate: upgrade.CheckUpdate}
+	deployment, err := d.Deploy(*network, n, previous)
 	if err != nil {
-		log.Fatal("Cannot deploy contract")
+		log.Fatal(err)
 	}
 
-	gwtf.PrintEvents(e, map[string][]string{})
+	if err := os.MkdirAll(filepath.Dir(*out), 0755); err != nil {
+		log.Fatal(err)
+	}
+	if err := deployment.Write(*out); err != nil {
+		log.Fatal(err)
+	}
+	names := []string{}
+	for name := range deployment.Hashes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		log.Printf("%s at %s has the code hash %s", name, deployment.Contracts[name], deployment.Hashes[name])
+	}
+	log.Printf("wrote the addresses to %s, render the templates with them with %s=%s", *out, util.AddressesEnv, *out)
 }
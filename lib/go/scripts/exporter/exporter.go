@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bjartek/go-with-the-flow/gwtf"
+	"github.com/flow-hydraulics/onchain-multisig/exporter"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/client"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	// The relative paths are the same as for scripts/deploy, which is run from lib/go
+	flowJSON := flag.String("flow", "../../flow.json", "path to flow.json")
+	root := flag.String("root", "../..", "path to the root of the repository, where the scripts and transactions are")
+	multiSig := flag.String("multisig", "owner", "account in flow.json the OnChainMultiSig contract is deployed to")
+	vaults := flag.String("vaults", "", "comma separated addresses of the vaults to export the metrics of")
+	interval := flag.Duration("interval", 15*time.Second, "interval to poll the vaults at")
+	addr := flag.String("addr", "localhost:9102", "address to serve /metrics on")
+	flag.Parse()
+
+	g := gwtf.NewGoWithTheFlow(*flowJSON)
+	acct, ok := g.Accounts[*multiSig]
+	if !ok {
+		log.Fatalf("Account %s is not an account in %s", *multiSig, *flowJSON)
+	}
+	addresses := []flow.Address{}
+	for _, vault := range strings.Split(*vaults, ",") {
+		if vault != "" {
+			addresses = append(addresses, flow.HexToAddress(vault))
+		}
+	}
+	if len(addresses) == 0 {
+		log.Fatal("No vaults to export the metrics of, set -vaults")
+	}
+	c, err := client.New(g.Address, grpc.WithInsecure())
+	if err != nil {
+		log.Fatalf("Cannot connect to %s: %s", g.Address, err)
+	}
+	defer c.Close()
+
+	e := exporter.New(g, c, acct.Address, *root, addresses)
+	go func() {
+		ticker := time.NewTicker(*interval)
+		for {
+			if err := e.Poll(context.Background()); err != nil {
+				log.Printf("Poll: %s", err)
+			}
+			<-ticker.C
+		}
+	}()
+
+	http.Handle("/metrics", e.Handler())
+	log.Printf("Exporting the metrics of %d vaults on %s/metrics", len(addresses), *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+
+	"github.com/flow-hydraulics/onchain-multisig/bindings/generator"
+)
+
+func main() {
+	// The relative paths are the same as for scripts/deploy, which is run from lib/go
+	root := flag.String("root", "../..", "path to the root of the repository, where the scripts and transactions are")
+	out := flag.String("out", "bindings/bindings_gen.go", "path of the generated Go file")
+	flag.Parse()
+
+	code, err := generator.Generate(*root)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(*out, code, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/flow-hydraulics/onchain-multisig/docs"
+)
+
+func main() {
+	// The relative paths are the same as for scripts/deploy, which is run from lib/go
+	root := flag.String("root", "../..", "path to the root of the repository, where the scripts and transactions are")
+	goRoot := flag.String("go", ".", "path to the Go module, whose packages are searched for the helpers of the files")
+	out := flag.String("out", "", "path of the generated reference, doc/REFERENCE.md in the root by default")
+	flag.Parse()
+
+	if *out == "" {
+		*out = filepath.Join(*root, docs.File)
+	}
+	reference, err := docs.Generate(*root, *goRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(*out), 0755); err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(*out, reference, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
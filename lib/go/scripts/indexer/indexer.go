@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bjartek/go-with-the-flow/gwtf"
+	"github.com/flow-hydraulics/onchain-multisig/indexer"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/client"
+	"google.golang.org/grpc"
+)
+
+const usage = `usage:
+  indexer index [-flow flow.json] [-db indexer.db] [-multisig owner] [-token owner] [-from height] [-follow interval]
+  indexer history [-db indexer.db] (-vault address | -key publicKey)
+  indexer activity [-db indexer.db] -vault address`
+
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatal(usage)
+	}
+	switch os.Args[1] {
+	case "index":
+		index(os.Args[2:])
+	case "history":
+		history(os.Args[2:])
+	case "activity":
+		activity(os.Args[2:])
+	default:
+		log.Fatal(usage)
+	}
+}
+
+func index(args []string) {
+	flags := flag.NewFlagSet("index", flag.ExitOnError)
+	// The relative paths are the same as for scripts/deploy, which is run from lib/go
+	flowJSON := flags.String("flow", "../../flow.json", "path to flow.json")
+	dbPath := flags.String("db", "indexer.db", "path to the index database")
+	multiSig := flags.String("multisig", "owner", "account in flow.json the OnChainMultiSig contract is deployed to")
+	token := flags.String("token", "owner", "account in flow.json the MultiSigFlowToken contract is deployed to")
+	from := flags.Uint64("from", 0, "block height to start at if nothing has been indexed yet")
+	follow := flags.Duration("follow", 0, "interval to keep indexing new blocks at, 0 stops at the latest sealed block")
+	_ = flags.Parse(args)
+
+	g := gwtf.NewGoWithTheFlow(*flowJSON)
+	acct, ok := g.Accounts[*multiSig]
+	if !ok {
+		log.Fatalf("Account %s is not an account in %s", *multiSig, *flowJSON)
+	}
+	tokenAcct, ok := g.Accounts[*token]
+	if !ok {
+		log.Fatalf("Account %s is not an account in %s", *token, *flowJSON)
+	}
+	c, err := client.New(g.Address, grpc.WithInsecure())
+	if err != nil {
+		log.Fatalf("Cannot connect to %s: %s", g.Address, err)
+	}
+	defer c.Close()
+	db, err := indexer.Open(*dbPath)
+	if err != nil {
+		log.Fatalf("Cannot open database: %s", err)
+	}
+	defer db.Close()
+
+	i := indexer.New(c, db, acct.Address, tokenAcct.Address)
+	for {
+		height, err := i.Sync(context.Background(), *from)
+		if err != nil {
+			log.Fatalf("Indexed up to height %d: %s", height, err)
+		}
+		log.Printf("Indexed up to height %d", height)
+		if *follow == 0 {
+			return
+		}
+		time.Sleep(*follow)
+	}
+}
+
+func history(args []string) {
+	flags := flag.NewFlagSet("history", flag.ExitOnError)
+	dbPath := flags.String("db", "indexer.db", "path to the index database")
+	vault := flags.String("vault", "", "address of the vault to list the payloads of")
+	key := flags.String("key", "", "public key to list the signed payloads of")
+	_ = flags.Parse(args)
+
+	db, err := indexer.Open(*dbPath)
+	if err != nil {
+		log.Fatalf("Cannot open database: %s", err)
+	}
+	defer db.Close()
+
+	var payloads []*indexer.Payload
+	switch {
+	case *vault != "":
+		payloads, err = db.History(flow.HexToAddress(*vault))
+	case *key != "":
+		payloads, err = db.SignedBy(*key)
+	default:
+		log.Fatal(usage)
+	}
+	if err != nil {
+		log.Fatalf("Cannot query history: %s", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VAULT\tRESOURCE\tTXINDEX\tMETHOD\tPROPOSED\tSTATUS\tCLOSED\tSIGNERS")
+	for _, p := range payloads {
+		closed := "-"
+		if p.ClosedHeight != nil {
+			closed = fmt.Sprint(*p.ClosedHeight)
+		}
+		signers := []string{}
+		for _, s := range p.Signatures {
+			pk := s.PublicKey
+			if len(pk) > 8 {
+				pk = pk[:8] + "…"
+			}
+			signers = append(signers, fmt.Sprintf("%s@%d", pk, s.Height))
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%d\t%s\t%s\t%s\n",
+			p.Vault, p.ResourceID, p.TxIndex, p.Method, p.ProposedHeight, p.Status, closed, strings.Join(signers, ", "))
+	}
+	w.Flush()
+}
+
+func activity(args []string) {
+	flags := flag.NewFlagSet("activity", flag.ExitOnError)
+	dbPath := flags.String("db", "indexer.db", "path to the index database")
+	vault := flags.String("vault", "", "address of the vault to list the withdrawals and deposits of")
+	_ = flags.Parse(args)
+	if *vault == "" {
+		log.Fatal(usage)
+	}
+
+	db, err := indexer.Open(*dbPath)
+	if err != nil {
+		log.Fatalf("Cannot open database: %s", err)
+	}
+	defer db.Close()
+
+	activity, err := db.Activity(flow.HexToAddress(*vault))
+	if err != nil {
+		log.Fatalf("Cannot query activity: %s", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "HEIGHT\tKIND\tAMOUNT\tTX")
+	for _, a := range activity {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", a.Height, a.Kind, a.Amount, a.Tx)
+	}
+	w.Flush()
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bjartek/go-with-the-flow/gwtf"
+	util "github.com/flow-hydraulics/onchain-multisig"
+	"github.com/flow-hydraulics/onchain-multisig/coordinator"
+	"github.com/flow-hydraulics/onchain-multisig/relayer"
+)
+
+func main() {
+	// The relative paths are the same as for scripts/deploy, which is run from lib/go
+	flowJSON := flag.String("flow", "../../flow.json", "path to flow.json")
+	root := flag.String("root", "../..", "path to the root of the repository, where the scripts and transactions are")
+	payers := flag.String("payers", "owner", "comma separated accounts in flow.json that pay for the submitted transactions")
+	limit := flag.Int("limit", 10, "submissions allowed per key in each period")
+	period := flag.Duration("period", time.Hour, "period of the rate limit")
+	addr := flag.String("addr", ":8081", "address to listen on")
+	txTimeout := flag.Duration("tx-timeout", util.DefaultTxTimeout, "time a submission waits for its transaction to be sealed")
+	flag.Parse()
+
+	g := gwtf.NewGoWithTheFlow(*flowJSON)
+	pool := []coordinator.Chain{}
+	for _, payer := range strings.Split(*payers, ",") {
+		if _, ok := g.Accounts[payer]; !ok {
+			log.Fatalf("Payer %s is not an account in %s", payer, *flowJSON)
+		}
+		chain := coordinator.NewFlowChain(g, payer, *root)
+		chain.TxTimeout = *txTimeout
+		pool = append(pool, chain)
+	}
+
+	r := relayer.New(pool, relayer.NewRateLimiter(*limit, *period))
+	log.Printf("Relayer listening on %s with %d payers", *addr, len(pool))
+	log.Fatal(http.ListenAndServe(*addr, relayer.NewHandler(r)))
+}
@@ -0,0 +1,21 @@
+package signer
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/onflow/flow-go-sdk/crypto"
+)
+
+// NewEnvSigner returns a signer for the hex encoded private key in the environment variable `name`
+func NewEnvSigner(name string, sigAlgo crypto.SignatureAlgorithm, hashAlgo crypto.HashAlgorithm) (*InMemorySigner, error) {
+	keyHex, ok := os.LookupEnv(name)
+	if !ok || keyHex == "" {
+		return nil, fmt.Errorf("environment variable %s is not set", name)
+	}
+	privateKey, err := crypto.DecodePrivateKeyHex(sigAlgo, keyHex)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode private key in %s: %w", name, err)
+	}
+	return NewInMemorySigner(privateKey, hashAlgo), nil
+}
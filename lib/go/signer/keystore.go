@@ -0,0 +1,194 @@
+package signer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/onflow/flow-go-sdk/crypto"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Version 1 keystores only authenticated their public key
+const keystoreVersion = 2
+
+// Scrypt cost parameters for keystore files,
+// LightScryptN is only meant for tests
+const (
+	StandardScryptN = 1 << 18
+	LightScryptN    = 1 << 12
+	scryptR         = 8
+	scryptP         = 1
+	scryptKeyLen    = 32
+)
+
+// Keystore is the JSON format of an encrypted private key file.
+// The private key is encrypted with AES-256-GCM using a key derived from the passphrase with scrypt,
+// the other fields are authenticated as additional data, see `additionalData`
+type Keystore struct {
+	Version   int            `json:"version"`
+	PublicKey string         `json:"publicKey"`
+	SigAlgo   string         `json:"sigAlgo"`
+	HashAlgo  string         `json:"hashAlgo"`
+	Crypto    KeystoreCrypto `json:"crypto"`
+}
+
+type KeystoreCrypto struct {
+	KDF        string       `json:"kdf"`
+	KDFParams  ScryptParams `json:"kdfparams"`
+	Cipher     string       `json:"cipher"`
+	Nonce      string       `json:"nonce"`
+	Ciphertext string       `json:"ciphertext"`
+}
+
+type ScryptParams struct {
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+	Salt string `json:"salt"`
+}
+
+// EncryptKey encrypts `privateKey` with `passphrase` into a keystore
+func EncryptKey(privateKey crypto.PrivateKey, hashAlgo crypto.HashAlgorithm, passphrase string, scryptN int) (*Keystore, error) {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	params := ScryptParams{N: scryptN, R: scryptR, P: scryptP, Salt: hex.EncodeToString(salt)}
+	gcm, err := keystoreCipher(passphrase, params)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	k := &Keystore{
+		Version:   keystoreVersion,
+		PublicKey: privateKey.PublicKey().String()[2:],
+		SigAlgo:   privateKey.Algorithm().String(),
+		HashAlgo:  hashAlgo.String(),
+		Crypto: KeystoreCrypto{
+			KDF:       "scrypt",
+			KDFParams: params,
+			Cipher:    "aes-256-gcm",
+			Nonce:     hex.EncodeToString(nonce),
+		},
+	}
+	ad, err := k.additionalData()
+	if err != nil {
+		return nil, err
+	}
+	k.Crypto.Ciphertext = hex.EncodeToString(gcm.Seal(nil, nonce, privateKey.Encode(), ad))
+	return k, nil
+}
+
+// additionalData returns the fields of the keystore other than the nonce and the ciphertext,
+// which are authenticated with the private key so that they cannot be changed in the file
+func (k *Keystore) additionalData() ([]byte, error) {
+	return json.Marshal(struct {
+		Version   int          `json:"version"`
+		PublicKey string       `json:"publicKey"`
+		SigAlgo   string       `json:"sigAlgo"`
+		HashAlgo  string       `json:"hashAlgo"`
+		KDF       string       `json:"kdf"`
+		KDFParams ScryptParams `json:"kdfparams"`
+		Cipher    string       `json:"cipher"`
+	}{k.Version, k.PublicKey, k.SigAlgo, k.HashAlgo, k.Crypto.KDF, k.Crypto.KDFParams, k.Crypto.Cipher})
+}
+
+// Decrypt returns the private key in the keystore, it errors if the passphrase is incorrect,
+// the keystore has been changed or its private key does not match its public key
+func (k *Keystore) Decrypt(passphrase string) (crypto.PrivateKey, error) {
+	if k.Version != keystoreVersion {
+		return nil, fmt.Errorf("unsupported keystore version %d", k.Version)
+	}
+	if k.Crypto.KDF != "scrypt" || k.Crypto.Cipher != "aes-256-gcm" {
+		return nil, fmt.Errorf("unsupported keystore kdf %s or cipher %s", k.Crypto.KDF, k.Crypto.Cipher)
+	}
+	gcm, err := keystoreCipher(passphrase, k.Crypto.KDFParams)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hex.DecodeString(k.Crypto.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := hex.DecodeString(k.Crypto.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, errors.New("invalid keystore nonce")
+	}
+	ad, err := k.additionalData()
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := gcm.Open(nil, nonce, ciphertext, ad)
+	if err != nil {
+		return nil, errors.New("could not decrypt keystore, the passphrase may be incorrect or the keystore changed")
+	}
+	if crypto.StringToHashAlgorithm(k.HashAlgo) == crypto.UnknownHashAlgorithm {
+		return nil, fmt.Errorf("unsupported keystore hash algorithm %s", k.HashAlgo)
+	}
+	privateKey, err := crypto.DecodePrivateKey(crypto.StringToSignatureAlgorithm(k.SigAlgo), encoded)
+	if err != nil {
+		return nil, err
+	}
+	if privateKey.PublicKey().String()[2:] != k.PublicKey {
+		return nil, errors.New("keystore private key does not match its public key")
+	}
+	return privateKey, nil
+}
+
+// WriteKeystore encrypts `privateKey` with `passphrase` into the keystore file at `path`
+func WriteKeystore(path string, privateKey crypto.PrivateKey, hashAlgo crypto.HashAlgorithm, passphrase string, scryptN int) error {
+	k, err := EncryptKey(privateKey, hashAlgo, passphrase, scryptN)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(k, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0600)
+}
+
+// NewKeystoreSigner returns a signer for the private key in the keystore file at `path`
+func NewKeystoreSigner(path string, passphrase string) (*InMemorySigner, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var k Keystore
+	if err := json.Unmarshal(b, &k); err != nil {
+		return nil, fmt.Errorf("could not parse keystore %s: %w", path, err)
+	}
+	privateKey, err := k.Decrypt(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return NewInMemorySigner(privateKey, crypto.StringToHashAlgorithm(k.HashAlgo)), nil
+}
+
+func keystoreCipher(passphrase string, params ScryptParams) (cipher.AEAD, error) {
+	salt, err := hex.DecodeString(params.Salt)
+	if err != nil {
+		return nil, err
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, params.N, params.R, params.P, scryptKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
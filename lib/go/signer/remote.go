@@ -0,0 +1,185 @@
+package signer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/flow-hydraulics/onchain-multisig/encoder"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/crypto"
+)
+
+// Remote signer protocol
+//
+// GET  {url}/public-key returns a KeyResponse
+// POST {url}/sign with a SignRequest returns a SignResponse
+//
+// The service only signs the signable data of multisig payloads, see `encoder.Validate`,
+// and prefixes it with the user domain tag itself, so that it cannot be made to sign other messages,
+// e.g. Flow transactions.
+// Errors are returned with a non 2xx status and the error message as the body
+const (
+	PublicKeyEndpoint = "/public-key"
+	SignEndpoint      = "/sign"
+)
+
+type KeyResponse struct {
+	PublicKey string `json:"publicKey"`
+	SigAlgo   string `json:"sigAlgo"`
+	HashAlgo  string `json:"hashAlgo"`
+}
+
+type SignRequest struct {
+	// Payload is the hex encoded signable data of a multisig payload, without the user domain tag
+	Payload string `json:"payload"`
+}
+
+type SignResponse struct {
+	Signature string `json:"signature"`
+}
+
+// RemoteSigner signs by sending messages to a remote signer service
+type RemoteSigner struct {
+	url       string
+	client    *http.Client
+	publicKey crypto.PublicKey
+	hashAlgo  crypto.HashAlgorithm
+}
+
+// NewRemoteSigner returns a signer for the remote signer service at `url`,
+// `client` may be configured for the authentication the service requires
+func NewRemoteSigner(url string, client *http.Client) (*RemoteSigner, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	s := &RemoteSigner{url: strings.TrimSuffix(url, "/"), client: client}
+
+	resp, err := client.Get(s.url + PublicKeyEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	var key KeyResponse
+	if err := decodeResponse(resp, &key); err != nil {
+		return nil, err
+	}
+	s.publicKey, err = crypto.DecodePublicKeyHex(crypto.StringToSignatureAlgorithm(key.SigAlgo), key.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode remote signer public key: %w", err)
+	}
+	s.hashAlgo = crypto.StringToHashAlgorithm(key.HashAlgo)
+	return s, nil
+}
+
+// Sign signs a message of the user domain tag followed by the signable data of a multisig payload,
+// as `SignPayload` signs it. The service adds the tag again, so only the signable data is sent.
+// The signature is verified with the public key of the service before it is returned
+func (s *RemoteSigner) Sign(message []byte) ([]byte, error) {
+	if !bytes.HasPrefix(message, flow.UserDomainTag[:]) {
+		return nil, errors.New("remote signers only sign multisig payloads with the user domain tag")
+	}
+	body, err := json.Marshal(SignRequest{Payload: hex.EncodeToString(message[len(flow.UserDomainTag):])})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Post(s.url+SignEndpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	var res SignResponse
+	if err := decodeResponse(resp, &res); err != nil {
+		return nil, err
+	}
+	sig, err := hex.DecodeString(res.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode remote signer signature: %w", err)
+	}
+	hasher, err := crypto.NewHasher(s.hashAlgo)
+	if err != nil {
+		return nil, err
+	}
+	valid, err := s.publicKey.Verify(sig, message, hasher)
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		return nil, errors.New("remote signer returned a signature that does not verify with its public key")
+	}
+	return sig, nil
+}
+
+func (s *RemoteSigner) PublicKey() crypto.PublicKey {
+	return s.publicKey
+}
+
+func (s *RemoteSigner) SigAlgo() crypto.SignatureAlgorithm {
+	return s.publicKey.Algorithm()
+}
+
+func (s *RemoteSigner) HashAlgo() crypto.HashAlgorithm {
+	return s.hashAlgo
+}
+
+func decodeResponse(resp *http.Response, v interface{}) error {
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var msg bytes.Buffer
+		_, _ = msg.ReadFrom(resp.Body)
+		return fmt.Errorf("remote signer returned %s: %s", resp.Status, strings.TrimSpace(msg.String()))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// NewRemoteSignerHandler serves the remote signer protocol for `s`,
+// e.g. as a stub remote signer in tests.
+// It rejects requests that are not the signable data of a multisig payload
+func NewRemoteSignerHandler(s Signer) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PublicKeyEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, KeyResponse{
+			PublicKey: PublicKeyHex(s),
+			SigAlgo:   s.SigAlgo().String(),
+			HashAlgo:  s.HashAlgo().String(),
+		})
+	})
+	mux.HandleFunc(SignEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req SignRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid sign request", http.StatusBadRequest)
+			return
+		}
+		payload, err := hex.DecodeString(req.Payload)
+		if err != nil {
+			http.Error(w, "payload must be hex encoded", http.StatusBadRequest)
+			return
+		}
+		if err := encoder.Validate(payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sig, err := s.Sign(append(flow.UserDomainTag[:], payload...))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, SignResponse{Signature: hex.EncodeToString(sig)})
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
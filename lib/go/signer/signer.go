@@ -0,0 +1,54 @@
+// Package signer provides the keys multisig signers sign payloads with.
+//
+// A Signer may hold its private key in memory, decrypt it from a keystore file,
+// read it from an environment variable or delegate signing to a remote service,
+// so that the multisig helpers never need the private keys in flow.json.
+package signer
+
+import (
+	"github.com/onflow/flow-go-sdk/crypto"
+)
+
+// Signer signs messages with a single key
+type Signer interface {
+	crypto.Signer
+	PublicKey() crypto.PublicKey
+	SigAlgo() crypto.SignatureAlgorithm
+	HashAlgo() crypto.HashAlgorithm
+}
+
+// PublicKeyHex returns the hex encoded public key of `s` as it is stored in the multisig key list
+func PublicKeyHex(s Signer) string {
+	return s.PublicKey().String()[2:]
+}
+
+// InMemorySigner signs with a private key held in memory
+type InMemorySigner struct {
+	privateKey crypto.PrivateKey
+	hashAlgo   crypto.HashAlgorithm
+	signer     crypto.InMemorySigner
+}
+
+func NewInMemorySigner(privateKey crypto.PrivateKey, hashAlgo crypto.HashAlgorithm) *InMemorySigner {
+	return &InMemorySigner{
+		privateKey: privateKey,
+		hashAlgo:   hashAlgo,
+		signer:     crypto.NewInMemorySigner(privateKey, hashAlgo),
+	}
+}
+
+func (s *InMemorySigner) Sign(message []byte) ([]byte, error) {
+	return s.signer.Sign(message)
+}
+
+func (s *InMemorySigner) PublicKey() crypto.PublicKey {
+	return s.privateKey.PublicKey()
+}
+
+func (s *InMemorySigner) SigAlgo() crypto.SignatureAlgorithm {
+	return s.privateKey.Algorithm()
+}
+
+func (s *InMemorySigner) HashAlgo() crypto.HashAlgorithm {
+	return s.hashAlgo
+}
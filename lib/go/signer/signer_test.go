@@ -0,0 +1,198 @@
+package signer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flow-hydraulics/onchain-multisig/encoder"
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+// the key of the emulator account in flow.json
+const testKeyHex = "1cd391b90c98671d3f07c7104f016c4704242704d8a7ad7d2126c6d5331516e8"
+
+func testKey(t *testing.T) crypto.PrivateKey {
+	k, err := crypto.DecodePrivateKeyHex(crypto.ECDSA_P256, testKeyHex)
+	assert.NoError(t, err)
+	return k
+}
+
+func assertSignatureVerifies(t *testing.T, s Signer) {
+	message := []byte("signable data")
+	sig, err := s.Sign(message)
+	assert.NoError(t, err)
+
+	hasher, err := crypto.NewHasher(s.HashAlgo())
+	assert.NoError(t, err)
+	valid, err := s.PublicKey().Verify(sig, message, hasher)
+	assert.NoError(t, err)
+	assert.True(t, valid)
+}
+
+func TestInMemorySigner(t *testing.T) {
+	k := testKey(t)
+	s := NewInMemorySigner(k, crypto.SHA3_256)
+	assert.Equal(t, k.PublicKey().String()[2:], PublicKeyHex(s))
+	assert.Equal(t, crypto.ECDSA_P256, s.SigAlgo())
+	assertSignatureVerifies(t, s)
+}
+
+func TestKeystoreSigner(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keystore")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "key.json")
+
+	k := testKey(t)
+	err = WriteKeystore(path, k, crypto.SHA3_256, "passphrase", LightScryptN)
+	assert.NoError(t, err)
+
+	b, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), testKeyHex)
+
+	s, err := NewKeystoreSigner(path, "passphrase")
+	assert.NoError(t, err)
+	assert.True(t, k.PublicKey().Equals(s.PublicKey()))
+	assert.Equal(t, crypto.SHA3_256, s.HashAlgo())
+	assertSignatureVerifies(t, s)
+
+	_, err = NewKeystoreSigner(path, "wrong passphrase")
+	assert.Error(t, err)
+}
+
+func TestKeystoreMetadataIsAuthenticated(t *testing.T) {
+	other, err := crypto.GeneratePrivateKey(crypto.ECDSA_P256, make([]byte, crypto.MinSeedLength))
+	assert.NoError(t, err)
+
+	changes := map[string]func(k *Keystore){
+		"public key": func(k *Keystore) { k.PublicKey = other.PublicKey().String()[2:] },
+		"sig algo":   func(k *Keystore) { k.SigAlgo = crypto.ECDSA_secp256k1.String() },
+		"hash algo":  func(k *Keystore) { k.HashAlgo = crypto.SHA2_256.String() },
+		"kdf params": func(k *Keystore) { k.Crypto.KDFParams.R = 1 },
+	}
+	for name, change := range changes {
+		ks, err := EncryptKey(testKey(t), crypto.SHA3_256, "passphrase", LightScryptN)
+		assert.NoError(t, err)
+		change(ks)
+
+		_, err = ks.Decrypt("passphrase")
+		assert.Error(t, err, name)
+	}
+}
+
+func TestKeystoreKeyMustMatchItsPublicKey(t *testing.T) {
+	other, err := crypto.GeneratePrivateKey(crypto.ECDSA_P256, make([]byte, crypto.MinSeedLength))
+	assert.NoError(t, err)
+
+	// A keystore written with the public key of another key, authenticated with it
+	ks, err := EncryptKey(testKey(t), crypto.SHA3_256, "passphrase", LightScryptN)
+	assert.NoError(t, err)
+	ks.PublicKey = other.PublicKey().String()[2:]
+	gcm, err := keystoreCipher("passphrase", ks.Crypto.KDFParams)
+	assert.NoError(t, err)
+	nonce, err := hex.DecodeString(ks.Crypto.Nonce)
+	assert.NoError(t, err)
+	ad, err := ks.additionalData()
+	assert.NoError(t, err)
+	ks.Crypto.Ciphertext = hex.EncodeToString(gcm.Seal(nil, nonce, testKey(t).Encode(), ad))
+
+	_, err = ks.Decrypt("passphrase")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "does not match its public key")
+	}
+}
+
+func TestEnvSigner(t *testing.T) {
+	name := "ONCHAIN_MULTISIG_TEST_KEY"
+	_, err := NewEnvSigner(name, crypto.ECDSA_P256, crypto.SHA3_256)
+	assert.Error(t, err)
+
+	os.Setenv(name, testKeyHex)
+	defer os.Unsetenv(name)
+	s, err := NewEnvSigner(name, crypto.ECDSA_P256, crypto.SHA3_256)
+	assert.NoError(t, err)
+	assert.True(t, testKey(t).PublicKey().Equals(s.PublicKey()))
+	assertSignatureVerifies(t, s)
+}
+
+// testPayload returns the signable data of a payload prefixed with the user domain tag, as `SignPayload` signs it
+func testPayload(t *testing.T) []byte {
+	signable, err := encoder.Encode(append([]byte(encoder.DomainPrefix), make([]byte, 24)...), 1, "withdraw", cadence.UFix64(100000000))
+	assert.NoError(t, err)
+	return append(flow.UserDomainTag[:], signable...)
+}
+
+func TestRemoteSigner(t *testing.T) {
+	k := testKey(t)
+	server := httptest.NewServer(NewRemoteSignerHandler(NewInMemorySigner(k, crypto.SHA3_256)))
+	defer server.Close()
+
+	s, err := NewRemoteSigner(server.URL, nil)
+	assert.NoError(t, err)
+	assert.True(t, k.PublicKey().Equals(s.PublicKey()))
+	assert.Equal(t, crypto.ECDSA_P256, s.SigAlgo())
+	assert.Equal(t, crypto.SHA3_256, s.HashAlgo())
+
+	message := testPayload(t)
+	sig, err := s.Sign(message)
+	assert.NoError(t, err)
+	valid, err := k.PublicKey().Verify(sig, message, crypto.NewSHA3_256())
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	_, err = NewRemoteSigner(server.URL+"/not-a-signer", nil)
+	assert.Error(t, err)
+}
+
+func TestRemoteSignerOnlySignsPayloads(t *testing.T) {
+	server := httptest.NewServer(NewRemoteSignerHandler(NewInMemorySigner(testKey(t), crypto.SHA3_256)))
+	defer server.Close()
+	s, err := NewRemoteSigner(server.URL, nil)
+	assert.NoError(t, err)
+
+	// Messages without the user domain tag, e.g. of transactions, are not sent
+	_, err = s.Sign(append(flow.TransactionDomainTag[:], []byte("transaction")...))
+	assert.Error(t, err)
+
+	// and the service rejects data that is not the signable data of a payload
+	for _, payload := range [][]byte{[]byte("signable data"), flow.TransactionDomainTag[:]} {
+		body, err := json.Marshal(SignRequest{Payload: hex.EncodeToString(payload)})
+		assert.NoError(t, err)
+		resp, err := http.Post(server.URL+SignEndpoint, "application/json", bytes.NewReader(body))
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestRemoteSignerVerifiesSignatures(t *testing.T) {
+	other, err := crypto.GeneratePrivateKey(crypto.ECDSA_P256, make([]byte, crypto.MinSeedLength))
+	assert.NoError(t, err)
+
+	// A service that signs with another key than the one it advertises
+	advertised := NewRemoteSignerHandler(NewInMemorySigner(testKey(t), crypto.SHA3_256))
+	signing := NewRemoteSignerHandler(NewInMemorySigner(other, crypto.SHA3_256))
+	mux := http.NewServeMux()
+	mux.Handle(PublicKeyEndpoint, advertised)
+	mux.Handle(SignEndpoint, signing)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	s, err := NewRemoteSigner(server.URL, nil)
+	assert.NoError(t, err)
+	_, err = s.Sign(testPayload(t))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "does not verify")
+	}
+}
@@ -9,46 +9,8 @@ fi
 # errexit + xtrace
 set -ex
 
-OS_NAME=$(uname -s | awk '{print tolower($0)}')
-CPU_ARCH=$(uname -m)
-PROJECT_ROOT=$(pwd)
-EXEC_PATH="$PROJECT_ROOT"/.github/flow-"$OS_NAME"-"$CPU_ARCH"
-
-shopt -s expand_aliases
-alias flow='$EXEC_PATH -f $PROJECT_ROOT/flow.json'
-
-# Run the emulator with the config in ./flow.json
-if [ "${NETWORK}" == "emulator" ]; then
-  # setting block-time of 1s to emulate testnet + mainnet tempo
-  flow emulator -b 1s &
-  EMULATOR_PID=$!
-
-  function tearDown {
-    kill $EMULATOR_PID
-  }
-
-  trap tearDown EXIT
-  sleep 1
-  SIGNER=emulator-account
-  OWNER_PK="46acb0e0918e09a50fc2a6b12f14fc00822ad7dac6c6fd92427ec675b9745cbe5ae93d790e6fdd0683d7dd17b6156cc4201def8d6a992807796a5ce4a789005f"
-  # we create the first account and transfer flow tokens to it
-  # the first account is the owner of the OnChainMultiSig contract
-  flow accounts create --network="$NETWORK" --key="$OWNER_PK" --signer="$SIGNER"
-  flow transactions send ./transactions/transfer_flow_tokens_emulator.cdc \
-    --arg=UFix64:100.0 \
-    --arg=Address:0x"$TOKEN_ACCOUNT_ADDRESS" \
-    --signer="$SIGNER" \
-    --network="$NETWORK"
-fi
-
-flow project deploy --network="$NETWORK" --update
-
-
-# NOW we switch to the go folder, where commands _can_ be run in place.
+# The tests of each package boot their own emulator with the contracts deployed, see lib/go/emulator,
+# so the packages run independently and in any order.
 cd lib/go
 go clean -testcache
-
-go run scripts/deploy/deploy.go
-go test ./vault -v
-go test ./access-checks -v
-go test ./keys -v
+go test ./... -v
@@ -0,0 +1,300 @@
+// Package upgrade checks that a new version of a contract can replace the old one with its stored values.
+//
+// The resources and structs of a contract stored in accounts, such as the `Manager` of a vault, its
+// `PayloadDetails` and their `PubKeyAttr`s, are loaded with the declarations of the updated contract. So an update
+// may not add fields to them, change the types of their fields, remove declarations or change their kinds or
+// conformances. Check reports every change of the fields and nested declarations between the two versions, which of
+// them break stored values, and the error of the validator of Cadence that rejects the update on chain.
+package upgrade
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/onflow/cadence/runtime"
+	"github.com/onflow/cadence/runtime/ast"
+	"github.com/onflow/cadence/runtime/common"
+	"github.com/onflow/cadence/runtime/parser2"
+)
+
+// The kinds of changes
+const (
+	FieldAdded          = "field added"
+	FieldRemoved        = "field removed"
+	FieldTypeChanged    = "field type changed"
+	DeclarationAdded    = "declaration added"
+	DeclarationRemoved  = "declaration removed"
+	KindChanged         = "kind changed"
+	ConformancesChanged = "conformances changed"
+	EnumCasesChanged    = "enum cases changed"
+)
+
+// Change is a change of a declaration of the contract
+type Change struct {
+	// Decl is the qualified name of the declaration, e.g. OnChainMultiSig.PayloadDetails
+	Decl string
+	// Field is the changed field of the declaration, if any
+	Field string
+	Kind  string
+	// Old and New describe the declaration or field in each version, empty if it is not in the version
+	Old string
+	New string
+	// Breaking is true if values stored with the old version cannot be loaded with the new one
+	Breaking bool
+	// Line is the line of the change in the new version, or in the old one if it was removed
+	Line int
+}
+
+func (c Change) String() string {
+	name := c.Decl
+	if c.Field != "" {
+		name += "." + c.Field
+	}
+	s := fmt.Sprintf("line %d: %s: %s", c.Line, name, c.Kind)
+	switch {
+	// A declaration may have no conformances or enum cases in a version
+	case c.Old != "" && c.New != "", c.Kind == ConformancesChanged, c.Kind == EnumCasesChanged:
+		s += fmt.Sprintf(" from %s to %s", c.Old, c.New)
+	case c.Old != "":
+		s += fmt.Sprintf(" (%s)", c.Old)
+	case c.New != "":
+		s += fmt.Sprintf(" (%s)", c.New)
+	}
+	if c.Breaking {
+		s += ", breaks stored values"
+	}
+	return s
+}
+
+// Report is the result of a check
+type Report struct {
+	Contract string
+	Changes  []Change
+	// Err is the error of the Cadence validator of contract updates, nil if it accepts the update
+	Err error
+}
+
+// Breaking returns the changes which break stored values
+func (r Report) Breaking() (changes []Change) {
+	for _, c := range r.Changes {
+		if c.Breaking {
+			changes = append(changes, c)
+		}
+	}
+	return
+}
+
+// OK returns true if the new version can replace the old one
+func (r Report) OK() bool {
+	return r.Err == nil && len(r.Breaking()) == 0
+}
+
+func (r Report) String() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%s: %d changes of stored declarations, %d breaking\n", r.Contract, len(r.Changes), len(r.Breaking()))
+	for _, c := range r.Changes {
+		fmt.Fprintln(b, c)
+	}
+	if r.Err != nil {
+		fmt.Fprintf(b, "the update is rejected: %s\n", r.Err)
+		if err, ok := r.Err.(*runtime.ContractUpdateError); ok {
+			for _, e := range err.Errors {
+				fmt.Fprintf(b, "- %s\n", e)
+			}
+		}
+	}
+	return b.String()
+}
+
+// placeholder matches the placeholders of the addresses of imports in the contracts
+var placeholder = regexp.MustCompile(`{{\s*\.\w+\s*}}`)
+
+// ResolveImports replaces the placeholders of the addresses of the imports in `code` with the zero address,
+// as the addresses do not matter to the declarations of the contract
+func ResolveImports(code []byte) []byte {
+	return placeholder.ReplaceAll(code, []byte("0000000000000000"))
+}
+
+// Check compares the code of the old and new versions of a contract, whose imports must be resolved
+func Check(oldCode []byte, newCode []byte) (r Report, err error) {
+	oldProgram, err := parser2.ParseProgram(string(oldCode))
+	if err != nil {
+		return r, fmt.Errorf("old version: %w", err)
+	}
+	newProgram, err := parser2.ParseProgram(string(newCode))
+	if err != nil {
+		return r, fmt.Errorf("new version: %w", err)
+	}
+	oldDecl, err := rootDeclaration(oldProgram)
+	if err != nil {
+		return r, fmt.Errorf("old version: %w", err)
+	}
+	newDecl, err := rootDeclaration(newProgram)
+	if err != nil {
+		return r, fmt.Errorf("new version: %w", err)
+	}
+
+	r.Contract = newDecl.DeclarationIdentifier().Identifier
+	r.Changes = compare(r.Contract, oldDecl, newDecl)
+
+	location := common.AddressLocation{Name: r.Contract}
+	r.Err = runtime.NewContractUpdateValidator(location, r.Contract, oldProgram, newProgram).Validate()
+	return
+}
+
+// CheckUpdate returns an error with the report of Check if the contract `name` cannot be updated from `oldCode`
+// to `newCode`, it is the CheckUpdate of deploy.Deployer
+func CheckUpdate(name string, oldCode []byte, newCode []byte) error {
+	r, err := Check(oldCode, newCode)
+	if err != nil {
+		return err
+	}
+	if r.Contract != name {
+		return fmt.Errorf("the new version is the contract %s, not %s", r.Contract, name)
+	}
+	if !r.OK() {
+		return errors.New(r.String())
+	}
+	return nil
+}
+
+func rootDeclaration(program *ast.Program) (ast.Declaration, error) {
+	if decl := program.SoleContractDeclaration(); decl != nil {
+		return decl, nil
+	}
+	if decl := program.SoleContractInterfaceDeclaration(); decl != nil {
+		return decl, nil
+	}
+	return nil, fmt.Errorf("no contract")
+}
+
+// compare returns the changes from `oldDecl` to `newDecl`, named `name`, and to their nested declarations
+func compare(name string, oldDecl ast.Declaration, newDecl ast.Declaration) (changes []Change) {
+	line := newDecl.DeclarationIdentifier().Pos.Line
+	if oldDecl.DeclarationKind() != newDecl.DeclarationKind() {
+		return []Change{{
+			Decl: name, Kind: KindChanged, Breaking: true, Line: line,
+			Old: oldDecl.DeclarationKind().Name(), New: newDecl.DeclarationKind().Name(),
+		}}
+	}
+
+	oldFields := oldDecl.DeclarationMembers().FieldsByIdentifier()
+	for _, field := range newDecl.DeclarationMembers().Fields() {
+		oldField, ok := oldFields[field.Identifier.Identifier]
+		if !ok {
+			changes = append(changes, Change{
+				Decl: name, Field: field.Identifier.Identifier, Kind: FieldAdded,
+				New: fieldType(field), Breaking: true, Line: field.StartPos.Line,
+			})
+			continue
+		}
+		if oldType, newType := fieldType(oldField), fieldType(field); unqualified(name, oldType) != unqualified(name, newType) {
+			changes = append(changes, Change{
+				Decl: name, Field: field.Identifier.Identifier, Kind: FieldTypeChanged,
+				Old: oldType, New: newType, Breaking: true, Line: field.StartPos.Line,
+			})
+		}
+	}
+	newFields := newDecl.DeclarationMembers().FieldsByIdentifier()
+	for _, field := range oldDecl.DeclarationMembers().Fields() {
+		if _, ok := newFields[field.Identifier.Identifier]; !ok {
+			// The values of removed fields are left unused in storage
+			changes = append(changes, Change{
+				Decl: name, Field: field.Identifier.Identifier, Kind: FieldRemoved,
+				Old: fieldType(field), Line: field.StartPos.Line,
+			})
+		}
+	}
+
+	if oldComposite, ok := oldDecl.(*ast.CompositeDeclaration); ok {
+		newComposite := newDecl.(*ast.CompositeDeclaration)
+		if oldConformances, newConformances := conformances(oldComposite), conformances(newComposite); oldConformances != newConformances {
+			changes = append(changes, Change{
+				Decl: name, Kind: ConformancesChanged, Old: oldConformances, New: newConformances, Breaking: true, Line: line,
+			})
+		}
+	}
+
+	if oldCases, newCases := enumCases(oldDecl), enumCases(newDecl); oldCases != "" && !strings.HasPrefix(newCases+",", oldCases+",") {
+		changes = append(changes, Change{
+			Decl: name, Kind: EnumCasesChanged, Old: oldCases, New: newCases, Breaking: true, Line: line,
+		})
+	}
+
+	oldNested := nested(oldDecl)
+	newNested := nested(newDecl)
+	for _, id := range sortedKeys(newNested) {
+		decl := newNested[id]
+		if oldNested[id] == nil {
+			changes = append(changes, Change{
+				Decl: name + "." + id, Kind: DeclarationAdded, New: decl.DeclarationKind().Name(),
+				Line: decl.DeclarationIdentifier().Pos.Line,
+			})
+			continue
+		}
+		changes = append(changes, compare(name+"."+id, oldNested[id], decl)...)
+	}
+	for _, id := range sortedKeys(oldNested) {
+		if decl := oldNested[id]; newNested[id] == nil {
+			changes = append(changes, Change{
+				Decl: name + "." + id, Kind: DeclarationRemoved, Old: decl.DeclarationKind().Name(), Breaking: true,
+				Line: decl.DeclarationIdentifier().Pos.Line,
+			})
+		}
+	}
+	return
+}
+
+func fieldType(field *ast.FieldDeclaration) string {
+	if field.TypeAnnotation.IsResource {
+		return "@" + field.TypeAnnotation.Type.String()
+	}
+	return field.TypeAnnotation.Type.String()
+}
+
+// unqualified removes the name of the contract from the types of `t`,
+// as the nested declarations of a contract may be named with or without it
+func unqualified(decl string, t string) string {
+	contract := strings.SplitN(decl, ".", 2)[0]
+	return strings.ReplaceAll(t, contract+".", "")
+}
+
+func conformances(decl *ast.CompositeDeclaration) string {
+	names := []string{}
+	for _, c := range decl.Conformances {
+		names = append(names, c.String())
+	}
+	return strings.Join(names, ", ")
+}
+
+func enumCases(decl ast.Declaration) string {
+	names := []string{}
+	for _, c := range decl.DeclarationMembers().EnumCases() {
+		names = append(names, c.Identifier.Identifier)
+	}
+	return strings.Join(names, ",")
+}
+
+// nested returns the composite and interface declarations nested in `decl` by name
+func nested(decl ast.Declaration) map[string]ast.Declaration {
+	decls := map[string]ast.Declaration{}
+	for id, d := range decl.DeclarationMembers().CompositesByIdentifier() {
+		decls[id] = d
+	}
+	for id, d := range decl.DeclarationMembers().InterfacesByIdentifier() {
+		decls[id] = d
+	}
+	return decls
+}
+
+func sortedKeys(decls map[string]ast.Declaration) []string {
+	keys := []string{}
+	for key := range decls {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
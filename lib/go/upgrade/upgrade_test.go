@@ -0,0 +1,297 @@
+package upgrade
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bjartek/go-with-the-flow/gwtf"
+	util "github.com/flow-hydraulics/onchain-multisig"
+	"github.com/flow-hydraulics/onchain-multisig/deploy"
+	"github.com/flow-hydraulics/onchain-multisig/emulator"
+	"github.com/flow-hydraulics/onchain-multisig/keys"
+	"github.com/flow-hydraulics/onchain-multisig/signer"
+	"github.com/flow-hydraulics/onchain-multisig/vault"
+	"github.com/onflow/cadence"
+	"github.com/stretchr/testify/assert"
+)
+
+const v1 = `pub contract C {
+    pub resource R: I {
+        pub var a: UInt64
+        pub let b: @{UInt64: C.S}
+        init() { self.a = 0; self.b <- {} }
+        destroy() { destroy self.b }
+    }
+    pub resource interface I {}
+    pub struct S {
+        pub let x: String
+        init() { self.x = "" }
+    }
+    pub enum E: UInt8 {
+        pub case first
+        pub case second
+    }
+}
+`
+
+func TestCheck(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		v2      string
+		changes []string
+	}{
+		{"unchanged", v1, nil},
+		{"functions and qualified types", strings.Replace(strings.Replace(v1,
+			"@{UInt64: C.S}", "@{UInt64: S}", 1),
+			"pub case second", "pub case second\n        pub case third\n        pub fun f() {}", 1), nil},
+		{"field added", strings.Replace(v1, "pub var a: UInt64", "pub var a: UInt64\n        pub var c: Int", 1),
+			[]string{"line 4: C.R.c: field added (Int), breaks stored values"}},
+		{"field removed", strings.Replace(v1, "pub let x: String", "", 1),
+			[]string{"line 10: C.S.x: field removed (String)"}},
+		{"field type changed", strings.Replace(v1, "pub var a: UInt64", "pub var a: UInt32", 1),
+			[]string{"line 3: C.R.a: field type changed from UInt64 to UInt32, breaks stored values"}},
+		{"declaration added", strings.Replace(v1, "pub resource interface I {}", "pub resource interface I {}\n    pub struct T {}", 1),
+			[]string{"line 9: C.T: declaration added (structure)"}},
+		{"declaration removed", strings.Replace(strings.Replace(v1, "pub resource interface I {}", "", 1), "R: I", "R", 1),
+			[]string{"line 2: C.R: conformances changed from I to , breaks stored values",
+				"line 8: C.I: declaration removed (resource interface), breaks stored values"}},
+		{"kind changed", strings.Replace(v1, "pub resource interface I {}", "pub struct interface I {}", 1),
+			[]string{"line 8: C.I: kind changed from resource interface to structure interface, breaks stored values"}},
+		{"enum cases reordered", strings.Replace(strings.Replace(v1, "pub case first", "pub case tmp", 1),
+			"pub case second", "pub case first", 1),
+			[]string{"line 13: C.E: enum cases changed from first,second to tmp,first, breaks stored values"}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			r, err := Check([]byte(v1), []byte(c.v2))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			changes := []string{}
+			for _, change := range r.Changes {
+				changes = append(changes, change.String())
+			}
+			assert.ElementsMatch(t, c.changes, changes)
+			// The changes are breaking exactly when Cadence rejects the update
+			assert.Equal(t, len(r.Breaking()) == 0, r.Err == nil, r.String())
+			assert.Equal(t, r.Err == nil, r.OK())
+		})
+	}
+}
+
+func TestInvalidCodeErrors(t *testing.T) {
+	_, err := Check([]byte(v1), []byte("pub contract C {"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "new version")
+	}
+	_, err = Check([]byte("pub struct S {}"), []byte(v1))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "old version")
+	}
+}
+
+// TestContractsUpdateDeployedVersions checks that the contracts of the working tree can update the versions
+// deployed on the networks, copied to testdata/deployed
+func TestContractsUpdateDeployedVersions(t *testing.T) {
+	for _, name := range []string{"OnChainMultiSig", "MultiSigFlowToken"} {
+		t.Run(name, func(t *testing.T) {
+			deployed, err := ioutil.ReadFile(filepath.Join("testdata", "deployed", name+".cdc"))
+			assert.NoError(t, err)
+			current, err := ioutil.ReadFile(filepath.Join("../../../contracts", name+".cdc"))
+			assert.NoError(t, err)
+			report, err := Check(ResolveImports(deployed), ResolveImports(current))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, name, report.Contract)
+			assert.Empty(t, report.Breaking(), report.String())
+			assert.True(t, report.OK(), report.String())
+		})
+	}
+}
+
+// newRoot returns a copy of the repository with `code` as the OnChainMultiSig contract
+func newRoot(t *testing.T, code string) string {
+	root := t.TempDir()
+	for _, dir := range []string{"contracts", "transactions"} {
+		assert.NoError(t, os.Mkdir(filepath.Join(root, dir), 0755))
+	}
+	for _, file := range []string{"flow.json", "contracts/MultiSigFlowToken.cdc", "transactions/deploy_contract_with_auth.cdc"} {
+		b, err := ioutil.ReadFile(filepath.Join("../../..", file))
+		assert.NoError(t, err)
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(root, file), b, 0644))
+	}
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(root, "contracts/OnChainMultiSig.cdc"), []byte(code), 0644))
+	return root
+}
+
+// upgradeTo checks the update of OnChainMultiSig to `code` and deploys it
+func upgradeTo(t *testing.T, code string) (Report, error) {
+	old, err := ioutil.ReadFile("../../../contracts/OnChainMultiSig.cdc")
+	assert.NoError(t, err)
+	report, err := Check(old, []byte(code))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	m, err := deploy.LoadManifest("../../../" + deploy.ManifestFile)
+	assert.NoError(t, err)
+	d := &deploy.Deployer{G: emu.GoWithTheFlow(), Root: newRoot(t, code), CheckUpdate: CheckUpdate}
+	_, err = d.Deploy(emulator.Network, m[emulator.Network], nil)
+	return report, err
+}
+
+// deployedRoot returns a copy of the repository with the deployed versions of the contracts in testdata/deployed
+func deployedRoot(t *testing.T) string {
+	root := t.TempDir()
+	for _, dir := range []string{"contracts", "transactions"} {
+		assert.NoError(t, os.Mkdir(filepath.Join(root, dir), 0755))
+	}
+	files := []string{"flow.json", deploy.ManifestFile, "transactions/deploy_contract_with_auth.cdc", "transactions/transfer_flow_tokens_emulator.cdc"}
+	for _, file := range files {
+		b, err := ioutil.ReadFile(filepath.Join("../../..", file))
+		assert.NoError(t, err)
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(root, file), b, 0644))
+	}
+	for _, name := range []string{"OnChainMultiSig", "MultiSigFlowToken"} {
+		b, err := ioutil.ReadFile(filepath.Join("testdata", "deployed", name+".cdc"))
+		assert.NoError(t, err)
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(root, "contracts", name+".cdc"), b, 0644))
+	}
+	return root
+}
+
+// sendDeployed sends the transaction `name` of the deployed version in testdata/deployed/transactions as `acct`
+func sendDeployed(t *testing.T, g *gwtf.GoWithTheFlow, name string, acct string, args ...cadence.Value) {
+	filename := filepath.Join("testdata", "deployed", "transactions", name)
+	_, err := util.SendTransaction(g, util.AccountRoles(acct), filename, util.ParseCadenceTemplate(filename), args...)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+}
+
+// addDeployedPayload adds a payload signed by `signerAcct` with the add_new_payload.cdc of the deployed version,
+// whose signable data is the txIndex, the method and the args without encoding
+func addDeployedPayload(t *testing.T, g *gwtf.GoWithTheFlow, vaultAcct string, txIndex uint64, method string, signerAcct string, args ...cadence.Value) {
+	signable := make([]byte, 8)
+	binary.BigEndian.PutUint64(signable, txIndex)
+	signable = append(signable, method...)
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case cadence.String:
+			signable = append(signable, v...)
+		case cadence.UFix64:
+			signable = append(signable, v.ToBigEndianBytes()...)
+		case cadence.Address:
+			signable = append(signable, v.Bytes()...)
+		default:
+			t.Fatalf("%T is not signable in the deployed version", arg)
+		}
+	}
+	s := util.GetSigner(g, signerAcct)
+	sig, err := util.SignPayload(s, signable)
+	assert.NoError(t, err)
+	sendDeployed(t, g, "add_new_payload.cdc", signerAcct,
+		cadence.String(sig),
+		cadence.UInt64(txIndex),
+		cadence.String(method),
+		cadence.NewArray(args),
+		cadence.String(signer.PublicKeyHex(s)),
+		cadence.BytesToAddress(g.Accounts[vaultAcct].Address.Bytes()),
+		cadence.UFix64(0))
+}
+
+func TestPendingPayloadsExecuteAfterUpgrade(t *testing.T) {
+	e, err := emulator.Start(deployedRoot(t))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer e.Stop()
+	g := e.GoWithTheFlow()
+	vaultAcct, payer := "vaulted-account", "owner"
+	signers := []string{vault.Acct1000, vault.Acct500_1, vault.Acct500_2, vault.Acct250_1, vault.Acct250_2}
+
+	// A vault created with the deployed contracts
+	pks, weights, sigAlgos := []cadence.Value{}, []cadence.Value{}, []cadence.Value{}
+	for i, acct := range signers {
+		weight, err := cadence.NewUFix64(vault.DefaultWeights[i])
+		assert.NoError(t, err)
+		pks = append(pks, cadence.String(signer.PublicKeyHex(util.GetSigner(g, acct))))
+		weights = append(weights, weight)
+		sigAlgos = append(sigAlgos, cadence.NewUInt8(1))
+	}
+	sendDeployed(t, g, "create_vault.cdc", vaultAcct, cadence.NewArray(pks), cadence.NewArray(weights), cadence.NewArray(sigAlgos))
+	amount, err := cadence.NewUFix64("10.0")
+	assert.NoError(t, err)
+	sendDeployed(t, g, "account_signer_token_transfer.cdc", payer, amount, cadence.BytesToAddress(g.Accounts[vaultAcct].Address.Bytes()))
+
+	// A transfer signed by 500 of the 1000 required, and the removal of a key signed by 250
+	transfer, removeKey := uint64(1), uint64(2)
+	payerAddr := cadence.BytesToAddress(g.Accounts[payer].Address.Bytes())
+	addDeployedPayload(t, g, vaultAcct, transfer, "transfer", signers[1], amount, payerAddr)
+	removed := signer.PublicKeyHex(util.GetSigner(g, signers[4]))
+	addDeployedPayload(t, g, vaultAcct, removeKey, "removeKey", signers[3], cadence.String(removed))
+	payerBalance, err := util.GetBalance(g, payer)
+	assert.NoError(t, err)
+
+	// The contracts are updated to the working tree
+	m, err := deploy.LoadManifest("../../../" + deploy.ManifestFile)
+	assert.NoError(t, err)
+	d := &deploy.Deployer{G: g, Root: "../../..", CheckUpdate: CheckUpdate}
+	_, err = d.Deploy(emulator.Network, m[emulator.Network], nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// The pending payloads are loaded with the working tree, signed and executed
+	_, err = vault.MultiSig_Transfer(g, "10.0", payer, transfer, signers[2], vaultAcct, false)
+	assert.NoError(t, err)
+	_, err = vault.MultiSig_VaultExecuteTx(g, transfer, payer, vaultAcct)
+	assert.NoError(t, err)
+	postPayerBalance, err := util.GetBalance(g, payer)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.00000000", (postPayerBalance - payerBalance).String())
+
+	_, err = keys.MultiSig_RemoveKey(g, signers[4], removeKey, signers[1], vaultAcct, false)
+	assert.NoError(t, err)
+	_, err = keys.MultiSig_RemoveKey(g, signers[4], removeKey, signers[2], vaultAcct, false)
+	assert.NoError(t, err)
+	_, err = vault.MultiSig_VaultExecuteTx(g, removeKey, payer, vaultAcct)
+	assert.NoError(t, err)
+	storeKeys, err := util.GetStoreKeys(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.NotContains(t, storeKeys, removed)
+	assert.Len(t, storeKeys, 4)
+}
+
+func TestUpgradesBreakingStoredValuesAreRefused(t *testing.T) {
+	code, err := ioutil.ReadFile("../../../contracts/OnChainMultiSig.cdc")
+	assert.NoError(t, err)
+	current := string(code)
+
+	// v2 adds a function and a struct, which stored values do not depend on
+	last := strings.LastIndex(current, "}")
+	v2 := current[:last] + "    pub fun version(): UInt8 {\n        return 2\n    }\n\n    pub struct Upgraded {}\n}\n"
+	report, err := upgradeTo(t, v2)
+	assert.NoError(t, err)
+	assert.True(t, report.OK(), report.String())
+	assert.Equal(t, []Change{{Decl: "OnChainMultiSig.Upgraded", Kind: DeclarationAdded, New: "structure",
+		Line: strings.Count(v2[:strings.Index(v2, "pub struct Upgraded")], "\n") + 1}}, report.Changes)
+
+	// v3 adds a field to the PubKeyAttr stored for each key, which the chain rejects as the checker does.
+	// The emulator does not validate updates, so the deployment is refused by CheckUpdate
+	v3 := strings.Replace(strings.Replace(v2,
+		"pub let sigAlgo: UInt8;", "pub let sigAlgo: UInt8;\n        pub let version: UInt8;", 1),
+		"self.sigAlgo = sa;", "self.sigAlgo = sa;\n            self.version = 3;", 1)
+	report, err = upgradeTo(t, v3)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "found new field `version` in `PubKeyAttr`")
+	}
+	assert.False(t, report.OK())
+	if assert.Len(t, report.Breaking(), 1) {
+		assert.Equal(t, "OnChainMultiSig.PubKeyAttr", report.Breaking()[0].Decl)
+		assert.Equal(t, FieldAdded, report.Breaking()[0].Kind)
+	}
+}
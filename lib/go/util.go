@@ -2,55 +2,175 @@ package util
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"regexp"
+	"sync"
 	"testing"
 	"time"
 
 	"text/template"
 
 	"github.com/bjartek/go-with-the-flow/gwtf"
+	"github.com/flow-hydraulics/onchain-multisig/encoder"
+	"github.com/flow-hydraulics/onchain-multisig/signer"
 	"github.com/onflow/cadence"
+	jsoncdc "github.com/onflow/cadence/encoding/json"
 	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/client"
 	"github.com/onflow/flow-go-sdk/crypto"
+	"github.com/onflow/flow-go-sdk/templates"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
 )
 
+// Addresses are the addresses of the contracts the Cadence templates import, as hex without a prefix
 type Addresses struct {
 	FungibleToken     string
 	MultiSigFlowToken string
 	OnChainMultiSig   string
 }
 
+// EmulatorAddresses are the addresses of the contracts on the emulator, with the accounts in flow.json
+var EmulatorAddresses = Addresses{"ee82856bf20e2aa6", "01cf0e2f2f715450", "01cf0e2f2f715450"}
+
+// AddressesEnv is the environment variable with the path of an addresses file written by scripts/deploy.
+// The templates are rendered with the addresses in the file instead of EmulatorAddresses when it is set
+const AddressesEnv = "MULTISIG_ADDRESSES"
+
+// VaultPaths are the paths of a multisig vault of an account, see `MultiSigFlowToken.VaultPaths`
+type VaultPaths struct {
+	Name     string
+	Storage  cadence.Path
+	Receiver cadence.Path
+	Balance  cadence.Path
+	Signer   cadence.Path
+}
+
+// DefaultVaultPaths are the paths of the vault created by create_vault.cdc, the paths of the `MultiSigFlowToken` contract
+var DefaultVaultPaths = VaultPaths{
+	Name:     "default",
+	Storage:  cadence.Path{Domain: "storage", Identifier: "vault"},
+	Receiver: cadence.Path{Domain: "public", Identifier: "vaultReceive"},
+	Balance:  cadence.Path{Domain: "public", Identifier: "vaultBalance"},
+	Signer:   cadence.Path{Domain: "public", Identifier: "vaultMultiSigner"},
+}
+
+// vaultName matches the names of the vaults, which are part of the identifiers of their paths
+var vaultName = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// NamedVaultPaths returns the paths of the vault `name` to create with create_named_vault.cdc,
+// the identifiers of the default paths suffixed with the name
+func NamedVaultPaths(name string) (p VaultPaths, err error) {
+	if !vaultName.MatchString(name) || name == DefaultVaultPaths.Name {
+		return p, fmt.Errorf("invalid vault name %q", name)
+	}
+	suffixed := func(path cadence.Path) cadence.Path {
+		return cadence.Path{Domain: path.Domain, Identifier: path.Identifier + "_" + name}
+	}
+	return VaultPaths{
+		Name:     name,
+		Storage:  suffixed(DefaultVaultPaths.Storage),
+		Receiver: suffixed(DefaultVaultPaths.Receiver),
+		Balance:  suffixed(DefaultVaultPaths.Balance),
+		Signer:   suffixed(DefaultVaultPaths.Signer),
+	}, nil
+}
+
+// NewVaultPathsFromCadence returns the paths of a `MultiSigFlowToken.VaultPaths` value
+func NewVaultPathsFromCadence(v cadence.Value) (p VaultPaths, err error) {
+	s, ok := v.(cadence.Struct)
+	if !ok || len(s.Fields) != 5 {
+		return p, errors.New("not a VaultPaths struct")
+	}
+	name, ok := s.Fields[0].(cadence.String)
+	if !ok {
+		return p, errors.New("VaultPaths name is not a String")
+	}
+	paths := []*cadence.Path{&p.Storage, &p.Receiver, &p.Balance, &p.Signer}
+	for i, path := range paths {
+		if *path, ok = s.Fields[i+1].(cadence.Path); !ok {
+			return p, errors.New("VaultPaths path is not a Path")
+		}
+	}
+	p.Name = string(name)
+	return
+}
+
 type TestEvent struct {
 	Name   string
-	Fields map[string]string
+	Fields map[string]interface{}
 }
 
 var addresses Addresses
 
-func ParseCadenceTemplate(templatePath string) []byte {
-	fb, err := ioutil.ReadFile(templatePath)
+var (
+	templateAddressesOnce sync.Once
+	templateAddresses     Addresses
+	templateAddressesErr  error
+)
+
+// LoadAddresses reads the addresses of the contracts in an addresses file written by scripts/deploy
+func LoadAddresses(path string) (a Addresses, err error) {
+	b, err := ioutil.ReadFile(path)
 	if err != nil {
-		panic(err)
+		return
+	}
+	var file struct {
+		Contracts Addresses `json:"contracts"`
+	}
+	if err = json.Unmarshal(b, &file); err != nil {
+		return a, fmt.Errorf("%s: %w", path, err)
+	}
+	a = file.Contracts
+	if a.FungibleToken == "" || a.MultiSigFlowToken == "" || a.OnChainMultiSig == "" {
+		return a, fmt.Errorf("%s: the address of a contract is missing", path)
 	}
+	return
+}
 
+// RenderCadenceTemplate returns the Cadence template at `templatePath` with the addresses of the contracts it imports
+func RenderCadenceTemplate(templatePath string, a Addresses) ([]byte, error) {
+	fb, err := ioutil.ReadFile(templatePath)
+	if err != nil {
+		return nil, err
+	}
 	tmpl, err := template.New("Template").Parse(string(fb))
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-
-	// Addresss for emulator are
-	addresses = Addresses{"ee82856bf20e2aa6", "01cf0e2f2f715450", "01cf0e2f2f715450"}
 	buf := &bytes.Buffer{}
-	err = tmpl.Execute(buf, addresses)
+	if err := tmpl.Execute(buf, a); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ParseCadenceTemplate renders the Cadence template at `templatePath` with the addresses in the file of AddressesEnv,
+// or with EmulatorAddresses if it is not set. It panics if the template cannot be rendered
+func ParseCadenceTemplate(templatePath string) []byte {
+	templateAddressesOnce.Do(func() {
+		templateAddresses = EmulatorAddresses
+		if path := os.Getenv(AddressesEnv); path != "" {
+			templateAddresses, templateAddressesErr = LoadAddresses(path)
+		}
+	})
+	if templateAddressesErr != nil {
+		panic(templateAddressesErr)
+	}
+
+	addresses = templateAddresses
+	code, err := RenderCadenceTemplate(templatePath, addresses)
 	if err != nil {
 		panic(err)
 	}
-
-	return buf.Bytes()
+	return code
 }
 
 func ParseTestEvents(events []flow.Event) (formatedEvents []*gwtf.FormatedEvent) {
@@ -64,11 +184,19 @@ func ParseTestEvents(events []flow.Event) (formatedEvents []*gwtf.FormatedEvent)
 func NewExpectedEvent(contract string, name string) TestEvent {
 	return TestEvent{
 		Name:   "A." + addresses.MultiSigFlowToken + "." + contract + "." + name,
-		Fields: map[string]string{},
+		Fields: map[string]interface{}{},
 	}
 }
 
-func (te TestEvent) AddField(fieldName string, fieldValue string) TestEvent {
+// AddField adds an expected field, as formatted by gwtf, i.e. a string or, for arrays, a []string
+func (te TestEvent) AddField(fieldName string, fieldValue interface{}) TestEvent {
+	if values, ok := fieldValue.([]string); ok {
+		array := []interface{}{}
+		for _, v := range values {
+			array = append(array, v)
+		}
+		fieldValue = array
+	}
 	te.Fields[fieldName] = fieldValue
 	return te
 }
@@ -92,6 +220,66 @@ func GetAccountAddr(g *gwtf.GoWithTheFlow, name string) string {
 	return address.String()
 }
 
+// serviceMu serializes the transactions of the service account, which propose with its only key
+var serviceMu sync.Mutex
+
+// CreateAccount creates an account with the key of `acct` in `g` and `contracts`, paid for by the service account
+func CreateAccount(g *gwtf.GoWithTheFlow, acct string, contracts ...templates.Contract) (address flow.Address, err error) {
+	account, ok := g.Accounts[acct]
+	if !ok {
+		err = fmt.Errorf("unknown account %s", acct)
+		return
+	}
+	tx := templates.CreateAccount([]*flow.AccountKey{account.NewAccountKey()}, contracts, g.Service.Address)
+	builder := g.TransactionFromFile("create_account", tx.Script).SignProposeAndPayAsService()
+	for _, arg := range tx.Arguments {
+		value, err := jsoncdc.Decode(arg)
+		if err != nil {
+			return address, err
+		}
+		builder = builder.Argument(value)
+	}
+
+	serviceMu.Lock()
+	events, err := builder.Run()
+	serviceMu.Unlock()
+	if err != nil {
+		return
+	}
+	for _, event := range events {
+		if event.Type == flow.EventAccountCreated {
+			return flow.AccountCreatedEvent(event).Address(), nil
+		}
+	}
+	err = fmt.Errorf("no account created for %s", acct)
+	return
+}
+
+// NewAccount creates an account with a new key, which is added to the accounts of `g` as `acct`
+func NewAccount(g *gwtf.GoWithTheFlow, acct string) (err error) {
+	seed := make([]byte, crypto.MinSeedLength)
+	if _, err = rand.Read(seed); err != nil {
+		return
+	}
+	privateKey, err := crypto.GeneratePrivateKey(crypto.ECDSA_P256, seed)
+	if err != nil {
+		return
+	}
+	account := gwtf.GoWithTheFlowAccount{
+		SigAlgo:    crypto.ECDSA_P256,
+		HashAlgo:   crypto.SHA3_256,
+		PrivateKey: privateKey,
+	}
+	g.Accounts[acct] = account
+	account.Address, err = CreateAccount(g, acct)
+	if err != nil {
+		delete(g.Accounts, acct)
+		return
+	}
+	g.Accounts[acct] = account
+	return
+}
+
 func ReadCadenceCode(ContractPath string) []byte {
 	b, err := ioutil.ReadFile(ContractPath)
 	if err != nil {
@@ -111,7 +299,7 @@ func GetTotalSupply(g *gwtf.GoWithTheFlow) (result cadence.UFix64, err error) {
 func GetBalance(g *gwtf.GoWithTheFlow, account string) (result cadence.UFix64, err error) {
 	filename := "../../../scripts/get_balance.cdc"
 	script := ParseCadenceTemplate(filename)
-	value, err := g.ScriptFromFile(filename, script).AccountArgument(account).RunReturns()
+	value, err := g.ScriptFromFile(filename, script).AccountArgument(account).Argument(DefaultVaultPaths.Balance).RunReturns()
 	if err != nil {
 		return
 	}
@@ -122,7 +310,7 @@ func GetBalance(g *gwtf.GoWithTheFlow, account string) (result cadence.UFix64, e
 func GetStoreKeys(g *gwtf.GoWithTheFlow, account string) (result []string, err error) {
 	filename := "../../../scripts/get_store_keys.cdc"
 	script := ParseCadenceTemplate(filename)
-	value, err := g.ScriptFromFile(filename, script).AccountArgument(account).RunReturns()
+	value, err := g.ScriptFromFile(filename, script).AccountArgument(account).Argument(DefaultVaultPaths.Signer).RunReturns()
 	if err != nil {
 		return
 	}
@@ -133,9 +321,10 @@ func GetStoreKeys(g *gwtf.GoWithTheFlow, account string) (result []string, err e
 func GetKeyWeight(g *gwtf.GoWithTheFlow, resourceAcct string, signerAcct string) (result cadence.UFix64, err error) {
 	filename := "../../../scripts/get_key_weight.cdc"
 	script := ParseCadenceTemplate(filename)
-	signerPubKey := g.Accounts[signerAcct].PrivateKey.PublicKey().String()[2:]
+	signerPubKey := GetSigner(g, signerAcct).PublicKey().String()[2:]
 	value, err := g.ScriptFromFile(filename, script).
 		AccountArgument(resourceAcct).
+		Argument(DefaultVaultPaths.Signer).
 		StringArgument(signerPubKey).
 		RunReturns()
 	if err != nil {
@@ -148,7 +337,7 @@ func GetKeyWeight(g *gwtf.GoWithTheFlow, resourceAcct string, signerAcct string)
 func GetTxIndex(g *gwtf.GoWithTheFlow, account string) (result uint64, err error) {
 	filename := "../../../scripts/get_store_tx_index.cdc"
 	script := ParseCadenceTemplate(filename)
-	value, err := g.ScriptFromFile(filename, script).AccountArgument(account).RunReturns()
+	value, err := g.ScriptFromFile(filename, script).AccountArgument(account).Argument(DefaultVaultPaths.Signer).RunReturns()
 	if err != nil {
 		return
 	}
@@ -159,7 +348,7 @@ func GetTxIndex(g *gwtf.GoWithTheFlow, account string) (result uint64, err error
 func GetVaultUUID(g *gwtf.GoWithTheFlow, account string) (r uint64, err error) {
 	filename := "../../../scripts/get_vault_uuid.cdc"
 	script := ParseCadenceTemplate(filename)
-	value, err := g.ScriptFromFile(filename, script).AccountArgument(account).RunReturns()
+	value, err := g.ScriptFromFile(filename, script).AccountArgument(account).Argument(DefaultVaultPaths.Signer).RunReturns()
 	if err != nil {
 		return
 	}
@@ -170,6 +359,81 @@ func GetVaultUUID(g *gwtf.GoWithTheFlow, account string) (r uint64, err error) {
 	return
 }
 
+func GetBlockHeight(g *gwtf.GoWithTheFlow) (result uint64, err error) {
+	filename := "../../../scripts/get_block_height.cdc"
+	script := ParseCadenceTemplate(filename)
+	value, err := g.ScriptFromFile(filename, script).RunReturns()
+	if err != nil {
+		return
+	}
+	result = value.ToGoValue().(uint64)
+	return
+}
+
+func GetMethodDelay(g *gwtf.GoWithTheFlow, account string, method string) (result uint64, err error) {
+	filename := "../../../scripts/get_method_delay.cdc"
+	script := ParseCadenceTemplate(filename)
+	value, err := g.ScriptFromFile(filename, script).
+		AccountArgument(account).
+		Argument(DefaultVaultPaths.Signer).
+		StringArgument(method).
+		RunReturns()
+	if err != nil {
+		return
+	}
+	result = value.ToGoValue().(uint64)
+	return
+}
+
+// GetTimelockRemaining returns the number of blocks left before the payload at `txIndex` can be executed,
+// `started` is false if the payload has not reached the required weight for its timelock to start
+func GetTimelockRemaining(g *gwtf.GoWithTheFlow, account string, txIndex uint64) (remaining uint64, started bool, err error) {
+	filename := "../../../scripts/get_timelock_remaining.cdc"
+	script := ParseCadenceTemplate(filename)
+	value, err := g.ScriptFromFile(filename, script).
+		AccountArgument(account).
+		Argument(DefaultVaultPaths.Signer).
+		UInt64Argument(txIndex).
+		RunReturns()
+	if err != nil {
+		return
+	}
+	optional, ok := value.(cadence.Optional)
+	if !ok {
+		err = errors.New("returned not optional")
+		return
+	}
+	if optional.Value == nil {
+		return
+	}
+	return optional.Value.ToGoValue().(uint64), true, nil
+}
+
+// AdvanceBlocks sends empty transactions until the block height has increased by at least `blocks`
+func AdvanceBlocks(g *gwtf.GoWithTheFlow, blocks uint64, payerAcct string) (err error) {
+	txFilename := "../../../transactions/advance_block.cdc"
+	txScript := ParseCadenceTemplate(txFilename)
+
+	start, err := GetBlockHeight(g)
+	if err != nil {
+		return
+	}
+	height := start
+	for height < start+blocks {
+		_, err = g.TransactionFromFile(txFilename, txScript).
+			SignProposeAndPayAs(payerAcct).
+			Run()
+		if err != nil {
+			return
+		}
+		height, err = GetBlockHeight(g)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
 func ConvertCadenceByteArray(a cadence.Value) (b []uint8) {
 	// type assertion of interface
 	i := a.ToGoValue().([]interface{})
@@ -191,14 +455,167 @@ func ConvertCadenceStringArray(a cadence.Value) (b []string) {
 	return
 }
 
+// TxRoles are the accounts in flow.json that propose, pay for and authorize a transaction
+type TxRoles struct {
+	Proposer    string
+	Payer       string
+	Authorizers []string
+}
+
+// AccountRoles has `acct` propose, pay for and authorize a transaction, as the gwtf transaction builder does
+func AccountRoles(acct string) TxRoles {
+	return TxRoles{Proposer: acct, Payer: acct, Authorizers: []string{acct}}
+}
+
+// DefaultTxTimeout is the time SendTransaction waits for a transaction to be sealed
+const DefaultTxTimeout = 5 * time.Minute
+
+// SendTransaction sends the transaction in `code` with `roles` and waits up to DefaultTxTimeout for it to be sealed.
+// Each account signs with the first key of the account, the payer signs the envelope
+// and the other accounts sign the payload
+func SendTransaction(g *gwtf.GoWithTheFlow, roles TxRoles, filename string, code []byte, args ...cadence.Value) (events []flow.Event, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTxTimeout)
+	defer cancel()
+	return SendTransactionWithContext(ctx, g, roles, filename, code, args...)
+}
+
+// SendTransactionWithContext is SendTransaction waiting until `ctx` is done,
+// it returns an error wrapping the error of `ctx` if the transaction is not sealed by then
+func SendTransactionWithContext(ctx context.Context, g *gwtf.GoWithTheFlow, roles TxRoles, filename string, code []byte, args ...cadence.Value) (events []flow.Event, err error) {
+	defer func() {
+		if err != nil && ctx.Err() != nil {
+			err = fmt.Errorf("transaction %s was not sealed: %w", filename, ctx.Err())
+		}
+	}()
+	c, err := client.New(g.Address, grpc.WithInsecure())
+	if err != nil {
+		return
+	}
+	defer c.Close()
+
+	accountKeys := map[string]*flow.AccountKey{}
+	accountKey := func(acct string) (*flow.AccountKey, error) {
+		if key, ok := accountKeys[acct]; ok {
+			return key, nil
+		}
+		account, err := c.GetAccount(ctx, g.Accounts[acct].Address)
+		if err != nil {
+			return nil, err
+		}
+		accountKeys[acct] = account.Keys[0]
+		return account.Keys[0], nil
+	}
+	accountSigner := func(acct string) (*flow.AccountKey, crypto.Signer, error) {
+		key, err := accountKey(acct)
+		if err != nil {
+			return nil, nil, err
+		}
+		return key, crypto.NewInMemorySigner(g.Accounts[acct].PrivateKey, key.HashAlgo), nil
+	}
+
+	proposerKey, err := accountKey(roles.Proposer)
+	if err != nil {
+		return
+	}
+	block, err := c.GetLatestBlockHeader(ctx, true)
+	if err != nil {
+		return
+	}
+	tx := flow.NewTransaction().
+		SetScript(code).
+		SetReferenceBlockID(block.ID).
+		SetGasLimit(g.Gas).
+		SetProposalKey(g.Accounts[roles.Proposer].Address, proposerKey.Index, proposerKey.SequenceNumber).
+		SetPayer(g.Accounts[roles.Payer].Address)
+	for _, acct := range roles.Authorizers {
+		tx.AddAuthorizer(g.Accounts[acct].Address)
+	}
+	for _, arg := range args {
+		if err = tx.AddArgument(arg); err != nil {
+			return
+		}
+	}
+
+	// Accounts only sign once, with the envelope if they are also the payer
+	payloadSigners := append([]string{roles.Proposer}, roles.Authorizers...)
+	signed := map[string]bool{roles.Payer: true}
+	for _, acct := range payloadSigners {
+		if signed[acct] {
+			continue
+		}
+		signed[acct] = true
+		key, s, err := accountSigner(acct)
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.SignPayload(g.Accounts[acct].Address, key.Index, s); err != nil {
+			return nil, err
+		}
+	}
+	key, s, err := accountSigner(roles.Payer)
+	if err != nil {
+		return
+	}
+	if err = tx.SignEnvelope(g.Accounts[roles.Payer].Address, key.Index, s); err != nil {
+		return
+	}
+
+	if err = c.SendTransaction(ctx, *tx); err != nil {
+		return
+	}
+	result, err := c.GetTransactionResult(ctx, tx.ID())
+	for err == nil && result.Status != flow.TransactionStatusSealed {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-time.After(time.Second):
+			result, err = c.GetTransactionResult(ctx, tx.ID())
+		}
+	}
+	if err != nil {
+		return
+	}
+	if result.Error != nil {
+		err = fmt.Errorf("transaction %s failed: %w", filename, result.Error)
+		return
+	}
+	return result.Events, nil
+}
+
 // Multisig utility functions
 
-// Signing payload offline
-func SignPayloadOffline(g *gwtf.GoWithTheFlow, message []byte, signingAcct string) (sig string, err error) {
-	s := g.Accounts[signingAcct]
-	signer := crypto.NewInMemorySigner(s.PrivateKey, s.HashAlgo)
+var (
+	signersMu sync.RWMutex
+	signers   = map[string]signer.Signer{}
+)
+
+// SetSigner sets the signer the multisig helpers sign with for `acct`, nil resets it
+func SetSigner(acct string, s signer.Signer) {
+	signersMu.Lock()
+	defer signersMu.Unlock()
+	if s == nil {
+		delete(signers, acct)
+		return
+	}
+	signers[acct] = s
+}
+
+// GetSigner returns the signer the multisig helpers sign with for `acct`,
+// which is the key of the account in flow.json unless another signer has been set with `SetSigner`
+func GetSigner(g *gwtf.GoWithTheFlow, acct string) signer.Signer {
+	signersMu.RLock()
+	defer signersMu.RUnlock()
+	if s, ok := signers[acct]; ok {
+		return s
+	}
+	a := g.Accounts[acct]
+	return signer.NewInMemorySigner(a.PrivateKey, a.HashAlgo)
+}
+
+// Signing payload with a signer
+func SignPayload(s signer.Signer, message []byte) (sig string, err error) {
 	message = append(flow.UserDomainTag[:], message...)
-	sigbytes, err := signer.Sign(message)
+	sigbytes, err := s.Sign(message)
 	if err != nil {
 		return
 	}
@@ -207,12 +624,63 @@ func SignPayloadOffline(g *gwtf.GoWithTheFlow, message []byte, signingAcct strin
 	return
 }
 
+// Signing payload offline
+func SignPayloadOffline(g *gwtf.GoWithTheFlow, message []byte, signingAcct string) (sig string, err error) {
+	return SignPayload(GetSigner(g, signingAcct), message)
+}
+
+// VerifyPayloadSignature verifies a signature made with `SignPayload`, as the `Manager` resource does
+func VerifyPayloadSignature(publicKey crypto.PublicKey, sig []byte, message []byte) (bool, error) {
+	message = append(flow.UserDomainTag[:], message...)
+	return publicKey.Verify(sig, message, crypto.NewSHA3_256())
+}
+
+// GetSignableDomain returns the signable domain of the multisig vault at the default paths of `resourceAcct`
+func GetSignableDomain(g *gwtf.GoWithTheFlow, resourceAcct string) (result []byte, err error) {
+	return GetSignableDomainAt(g, resourceAcct, DefaultVaultPaths.Signer)
+}
+
+// GetSignableDomainAt returns the signable domain of the multisig vault of `resourceAcct` linked at `signerPath`
+func GetSignableDomainAt(g *gwtf.GoWithTheFlow, resourceAcct string, signerPath cadence.Path) (result []byte, err error) {
+	filename := "../../../scripts/calc_signable_domain.cdc"
+	script := ParseCadenceTemplate(filename)
+	value, err := g.ScriptFromFile(filename, script).AccountArgument(resourceAcct).Argument(signerPath).RunReturns()
+	if err != nil {
+		return
+	}
+	result = ConvertCadenceByteArray(value)
+	return
+}
+
+// GetSignableDataFromScript returns the signable data of a payload for the multisig vault at the default paths of
+// `resourceAcct`, with the txIndex, method and args encoded by the contract
 func GetSignableDataFromScript(
 	g *gwtf.GoWithTheFlow,
+	resourceAcct string,
 	txIndex uint64,
 	method string,
 	args ...cadence.Value,
 ) (signable []byte, err error) {
+	return GetSignableDataAt(g, resourceAcct, DefaultVaultPaths.Signer, txIndex, method, args...)
+}
+
+// GetSignableDataAt returns the signable data of a payload for the multisig vault of `resourceAcct` linked at
+// `signerPath`, as GetSignableDataFromScript does
+func GetSignableDataAt(
+	g *gwtf.GoWithTheFlow,
+	resourceAcct string,
+	signerPath cadence.Path,
+	txIndex uint64,
+	method string,
+	args ...cadence.Value,
+) (signable []byte, err error) {
+	domain, err := GetSignableDomainAt(g, resourceAcct, signerPath)
+	if err != nil {
+		return
+	}
+	signable = append(signable, encoder.Version)
+	signable = append(signable, encoder.EncodeField(encoder.TagDomain, domain)...)
+
 	filename := "../../../scripts/calc_signable_data.cdc"
 	script := ParseCadenceTemplate(filename)
 
@@ -247,43 +715,80 @@ func MultiSig_VaultNewPayload(
 	signerAcct string,
 	resourceAcct string,
 	withdrawAmount string,
+) (events []*gwtf.FormatedEvent, err error) {
+	signerPubKey := signer.PublicKeyHex(GetSigner(g, signerAcct))
+	return SubmitNewPayload(g, AccountRoles(signerAcct), sig, txIndex, method, args, signerPubKey, g.FindAddress(resourceAcct), DefaultVaultPaths.Signer, withdrawAmount)
+}
+
+func MultiSig_VaultAddPayloadSignature(
+	g *gwtf.GoWithTheFlow,
+	sig string,
+	txIndex uint64,
+	signerAcct string,
+	resourceAcct string,
+) (events []*gwtf.FormatedEvent, err error) {
+	signerPubKey := signer.PublicKeyHex(GetSigner(g, signerAcct))
+	return SubmitPayloadSignature(g, AccountRoles(signerAcct), sig, txIndex, signerPubKey, g.FindAddress(resourceAcct), DefaultVaultPaths.Signer)
+}
+
+// SubmitNewPayload adds a new payload signed by the multisig key `signerPubKey` to the vault of `resourceAddr`
+// linked at `signerPath`.
+// The account authorizing the transaction is the one a non zero `withdrawAmount` is withdrawn from,
+// which need not be the one paying for it
+func SubmitNewPayload(
+	g *gwtf.GoWithTheFlow,
+	roles TxRoles,
+	sig string,
+	txIndex uint64,
+	method string,
+	args []cadence.Value,
+	signerPubKey string,
+	resourceAddr cadence.Address,
+	signerPath cadence.Path,
+	withdrawAmount string,
 ) (events []*gwtf.FormatedEvent, err error) {
 	txFilename := "../../../transactions/add_new_payload.cdc"
 	txScript := ParseCadenceTemplate(txFilename)
 
-	signerPubKey := g.Accounts[signerAcct].PrivateKey.PublicKey().String()
-	e, err := g.TransactionFromFile(txFilename, txScript).
-		SignProposeAndPayAs(signerAcct).
-		StringArgument(sig).
-		UInt64Argument(txIndex).
-		StringArgument(method).
-		Argument(cadence.NewArray(args)).
-		StringArgument(signerPubKey[2:]).
-		AccountArgument(resourceAcct).
-		UFix64Argument(withdrawAmount).
-		Run()
+	amount, err := cadence.NewUFix64(withdrawAmount)
+	if err != nil {
+		return
+	}
+	e, err := SendTransaction(g, roles, txFilename, txScript,
+		cadence.String(sig),
+		cadence.UInt64(txIndex),
+		cadence.String(method),
+		cadence.NewArray(args),
+		cadence.String(signerPubKey),
+		resourceAddr,
+		signerPath,
+		amount,
+	)
 	events = ParseTestEvents(e)
 	return
 }
 
-func MultiSig_VaultAddPayloadSignature(
+// SubmitPayloadSignature adds a signature of the multisig key `signerPubKey` to a payload of the vault of
+// `resourceAddr` linked at `signerPath`
+func SubmitPayloadSignature(
 	g *gwtf.GoWithTheFlow,
+	roles TxRoles,
 	sig string,
 	txIndex uint64,
-	signerAcct string,
-	resourceAcct string,
+	signerPubKey string,
+	resourceAddr cadence.Address,
+	signerPath cadence.Path,
 ) (events []*gwtf.FormatedEvent, err error) {
 	txFilename := "../../../transactions/add_payload_signature.cdc"
 	txScript := ParseCadenceTemplate(txFilename)
 
-	signerPubKey := g.Accounts[signerAcct].PrivateKey.PublicKey().String()
-	e, err := g.TransactionFromFile(txFilename, txScript).
-		SignProposeAndPayAs(signerAcct).
-		StringArgument(sig).
-		UInt64Argument(txIndex).
-		StringArgument(signerPubKey[2:]).
-		AccountArgument(resourceAcct).
-		Run()
+	e, err := SendTransaction(g, roles, txFilename, txScript,
+		cadence.String(sig),
+		cadence.UInt64(txIndex),
+		cadence.String(signerPubKey),
+		resourceAddr,
+		signerPath,
+	)
 	events = ParseTestEvents(e)
 	return
 }
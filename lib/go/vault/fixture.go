@@ -0,0 +1,96 @@
+package vault
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bjartek/go-with-the-flow/gwtf"
+	util "github.com/flow-hydraulics/onchain-multisig"
+	"github.com/onflow/cadence"
+	"github.com/stretchr/testify/assert"
+)
+
+// ApprovalWeight is the full approval weight of MultiSigFlowToken
+const ApprovalWeight = "1000.0"
+
+var (
+	// ownerMu serializes the transfers from the owner, which propose with its only key
+	ownerMu  sync.Mutex
+	fixtures uint64
+)
+
+// Fixture is a vault in an account of its own, with signer accounts of its own,
+// so that a test using it neither depends on nor changes the state of other tests
+type Fixture struct {
+	G *gwtf.GoWithTheFlow
+	// Vault is the account of the vault
+	Vault string
+	// Signers are the accounts of the keys of the vault, Signers[i] has the weight weights[i] of NewFixture
+	Signers []string
+	// Payer is an account with an empty vault and no key in the vault,
+	// to pay for executions, which deposit returned resources to the payer, and as a recipient
+	Payer string
+}
+
+// NewFixture creates a vault funded with `balance` from the owner, with a key of a new account for each of `weights`.
+// The accounts are added to the accounts of `g` with names unique to the fixture
+func NewFixture(g *gwtf.GoWithTheFlow, balance string, weights ...string) (f Fixture, err error) {
+	id := atomic.AddUint64(&fixtures, 1)
+	f = Fixture{
+		G:     g,
+		Vault: fmt.Sprintf("vault-%d", id),
+		Payer: fmt.Sprintf("payer-%d", id),
+	}
+	for i := range weights {
+		f.Signers = append(f.Signers, fmt.Sprintf("signer-%d-%d", id, i))
+	}
+
+	for _, acct := range append([]string{f.Vault, f.Payer}, f.Signers...) {
+		if err = util.NewAccount(g, acct); err != nil {
+			return
+		}
+	}
+	if _, err = AddVaultWithKeys(g, f.Vault, f.Signers, weights); err != nil {
+		return
+	}
+	if _, err = AddVaultWithKeys(g, f.Payer, nil, nil); err != nil {
+		return
+	}
+	err = f.Fund(f.Vault, balance)
+	return
+}
+
+// NewTestFixture is NewFixture for tests, with a balance of 100.0 and `DefaultWeights` if no weights are given,
+// it fails the test if the fixture cannot be created
+func NewTestFixture(t *testing.T, g *gwtf.GoWithTheFlow, weights ...string) Fixture {
+	if len(weights) == 0 {
+		weights = DefaultWeights
+	}
+	f, err := NewFixture(g, "100.0", weights...)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return f
+}
+
+// Fund transfers `amount` from the owner to the vault of `acct`
+func (f Fixture) Fund(acct string, amount string) (err error) {
+	ownerMu.Lock()
+	defer ownerMu.Unlock()
+	_, err = AccountSignerTransferTokens(f.G, amount, "owner", acct)
+	return
+}
+
+// MOfN returns the weights of `n` keys of which any `m` have the approval weight, and any `m - 1` do not
+func MOfN(m int, n int) []string {
+	threshold, _ := cadence.NewUFix64(ApprovalWeight)
+	// Rounded up, as the weights of m keys are rounded down to less than the approval weight otherwise
+	w := cadence.UFix64((uint64(threshold) + uint64(m) - 1) / uint64(m))
+	weights := []string{}
+	for i := 0; i < n; i++ {
+		weights = append(weights, w.String())
+	}
+	return weights
+}
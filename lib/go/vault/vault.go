@@ -1,8 +1,12 @@
 package vault
 
 import (
+	"errors"
+	"sort"
+
 	"github.com/bjartek/go-with-the-flow/gwtf"
 	util "github.com/flow-hydraulics/onchain-multisig"
+	"github.com/flow-hydraulics/onchain-multisig/bindings"
 	"github.com/onflow/cadence"
 )
 
@@ -12,44 +16,137 @@ const Acct500_2 = "w-500-2"
 const Acct250_1 = "w-250-1"
 const Acct250_2 = "w-250-2"
 
+// DefaultWeights are the weights of the keys of Acct1000, Acct500_1, Acct500_2, Acct250_1 and Acct250_2
+var DefaultWeights = []string{"1000.0", "500.0", "500.0", "250.0", "250.0"}
+
+// client returns the bindings of the transactions and scripts for the test packages
+func client(g *gwtf.GoWithTheFlow) *bindings.Client {
+	return bindings.New(g, "../../..")
+}
+
+// AddVaultToAccount adds a vault to `vaultAcct` with the keys of the signer accounts in flow.json
 func AddVaultToAccount(
 	g *gwtf.GoWithTheFlow,
 	vaultAcct string,
+) (events []*gwtf.FormatedEvent, err error) {
+	signerAccts := []string{Acct1000, Acct500_1, Acct500_2, Acct250_1, Acct250_2}
+	return AddVaultWithKeys(g, vaultAcct, signerAccts, DefaultWeights)
+}
+
+// AddVaultWithKeys adds a vault to `vaultAcct` with the key of each of `signerAccts` with the weight in `weights`,
+// it fails if the account already has a vault, which is moved to another vault with MultiSig_Migrate and MigrateVault
+func AddVaultWithKeys(
+	g *gwtf.GoWithTheFlow,
+	vaultAcct string,
+	signerAccts []string,
+	weights []string,
 ) (events []*gwtf.FormatedEvent, err error) {
 	txFilename := "../../../transactions/create_vault.cdc"
 	txScript := util.ParseCadenceTemplate(txFilename)
 
-	pk1000 := g.Accounts[Acct1000].PrivateKey.PublicKey().String()
-	pk500_1 := g.Accounts[Acct500_1].PrivateKey.PublicKey().String()
-	pk500_2 := g.Accounts[Acct500_2].PrivateKey.PublicKey().String()
-	pk250_1 := g.Accounts[Acct250_1].PrivateKey.PublicKey().String()
-	pk250_2 := g.Accounts[Acct250_2].PrivateKey.PublicKey().String()
+	keys, err := signerKeys(g, signerAccts, weights)
+	if err != nil {
+		return
+	}
+	e, err := g.TransactionFromFile(txFilename, txScript).
+		SignProposeAndPayAs(vaultAcct).
+		Argument(keys[0]).
+		Argument(keys[1]).
+		Argument(keys[2]).
+		Run()
+	events = util.ParseTestEvents(e)
+	return
+}
 
-	w1000, _ := cadence.NewUFix64("1000.0")
-	w500, _ := cadence.NewUFix64("500.0")
-	w250, _ := cadence.NewUFix64("250.0")
+// AddNamedVault adds a vault at `paths` to `vaultAcct` with the key of each of `signerAccts` with the weight in
+// `weights`, next to its other vaults. It fails if anything is stored or linked at the paths
+func AddNamedVault(
+	g *gwtf.GoWithTheFlow,
+	vaultAcct string,
+	paths util.VaultPaths,
+	signerAccts []string,
+	weights []string,
+) (events []*gwtf.FormatedEvent, err error) {
+	txFilename := "../../../transactions/create_named_vault.cdc"
+	txScript := util.ParseCadenceTemplate(txFilename)
 
-	multiSigPubKeys := []cadence.Value{
-		cadence.String(pk1000[2:]),
-		cadence.String(pk500_1[2:]),
-		cadence.String(pk500_2[2:]),
-		cadence.String(pk250_1[2:]),
-		cadence.String(pk250_2[2:]),
+	keys, err := signerKeys(g, signerAccts, weights)
+	if err != nil {
+		return
 	}
-	multiSigKeyWeights := []cadence.Value{w1000, w500, w500, w250, w250}
-	sa := cadence.NewUInt8(1)
-	multiSigAlgos := []cadence.Value{sa, sa, sa, sa, sa}
-
 	e, err := g.TransactionFromFile(txFilename, txScript).
 		SignProposeAndPayAs(vaultAcct).
-		Argument(cadence.NewArray(multiSigPubKeys)).
-		Argument(cadence.NewArray(multiSigKeyWeights)).
-		Argument(cadence.NewArray(multiSigAlgos)).
+		StringArgument(paths.Name).
+		Argument(paths.Storage).
+		Argument(paths.Receiver).
+		Argument(paths.Balance).
+		Argument(paths.Signer).
+		Argument(keys[0]).
+		Argument(keys[1]).
+		Argument(keys[2]).
 		Run()
 	events = util.ParseTestEvents(e)
 	return
 }
 
+// signerKeys returns the public keys, weights and signature algorithms of the keys of `signerAccts`
+// as the arguments of the transactions creating vaults
+func signerKeys(g *gwtf.GoWithTheFlow, signerAccts []string, weights []string) ([]cadence.Value, error) {
+	if len(signerAccts) != len(weights) {
+		return nil, errors.New("one weight is required per signer")
+	}
+	multiSigPubKeys := []cadence.Value{}
+	multiSigKeyWeights := []cadence.Value{}
+	multiSigAlgos := []cadence.Value{}
+	for i, acct := range signerAccts {
+		w, err := cadence.NewUFix64(weights[i])
+		if err != nil {
+			return nil, err
+		}
+		pk := util.GetSigner(g, acct).PublicKey().String()
+		multiSigPubKeys = append(multiSigPubKeys, cadence.String(pk[2:]))
+		multiSigKeyWeights = append(multiSigKeyWeights, w)
+		multiSigAlgos = append(multiSigAlgos, cadence.NewUInt8(1))
+	}
+	return []cadence.Value{
+		cadence.NewArray(multiSigPubKeys),
+		cadence.NewArray(multiSigKeyWeights),
+		cadence.NewArray(multiSigAlgos),
+	}, nil
+}
+
+// GetVaults returns the paths of the vaults of `acct`, the default vault first and the named vaults by name
+func GetVaults(g *gwtf.GoWithTheFlow, acct string) (vaults []util.VaultPaths, err error) {
+	values, err := client(g).GetVaults(g.Accounts[acct].Address)
+	if err != nil {
+		return
+	}
+	for _, v := range values {
+		paths, err := util.NewVaultPathsFromCadence(v)
+		if err != nil {
+			return nil, err
+		}
+		vaults = append(vaults, paths)
+	}
+	sort.SliceStable(vaults, func(i, j int) bool {
+		if vaults[i].Name == util.DefaultVaultPaths.Name || vaults[j].Name == util.DefaultVaultPaths.Name {
+			return vaults[i].Name == util.DefaultVaultPaths.Name && vaults[j].Name != util.DefaultVaultPaths.Name
+		}
+		return vaults[i].Name < vaults[j].Name
+	})
+	return
+}
+
+// SetupVault adds a vault with the signer keys to `vaultAcct` and funds it with `amount` from the owner,
+// for the tests of the packages that do not create their own vault
+func SetupVault(g *gwtf.GoWithTheFlow, vaultAcct string, amount string) (err error) {
+	if _, err = AddVaultToAccount(g, vaultAcct); err != nil {
+		return
+	}
+	_, err = AccountSignerTransferTokens(g, amount, "owner", vaultAcct)
+	return
+}
+
 func AccountSignerTransferTokens(
 	g *gwtf.GoWithTheFlow,
 	amount string,
@@ -63,6 +160,7 @@ func AccountSignerTransferTokens(
 		SignProposeAndPayAs(fromAcct).
 		UFix64Argument(amount).
 		AccountArgument(toAcct).
+		Argument(util.DefaultVaultPaths.Receiver).
 		Run()
 	events = util.ParseTestEvents(e)
 	return
@@ -84,7 +182,7 @@ func MultiSig_Transfer(
 		return nil, err
 	}
 	toAddr := cadence.BytesToAddress(g.Accounts[to].Address.Bytes())
-	signable, err := util.GetSignableDataFromScript(g, txIndex, method, ufix64, toAddr)
+	signable, err := util.GetSignableDataFromScript(g, vaultAcct, txIndex, method, ufix64, toAddr)
 	if err != nil {
 		return
 	}
@@ -101,6 +199,38 @@ func MultiSig_Transfer(
 	}
 }
 
+// MultiSig_Withdraw withdraws `amount` from the vault, the tokens are deposited to the executor of the payload
+func MultiSig_Withdraw(
+	g *gwtf.GoWithTheFlow,
+	amount string,
+	txIndex uint64,
+	signerAcct string,
+	vaultAcct string,
+	newPaylaod bool,
+) (events []*gwtf.FormatedEvent, err error) {
+
+	method := "withdraw"
+	ufix64, err := cadence.NewUFix64(amount)
+	if err != nil {
+		return nil, err
+	}
+	signable, err := util.GetSignableDataFromScript(g, vaultAcct, txIndex, method, ufix64)
+	if err != nil {
+		return
+	}
+
+	sig, err := util.SignPayloadOffline(g, signable, signerAcct)
+	if err != nil {
+		return
+	}
+	if newPaylaod {
+		args := []cadence.Value{ufix64}
+		return util.MultiSig_VaultNewPayload(g, sig, txIndex, method, args, signerAcct, vaultAcct, "0.0")
+	} else {
+		return util.MultiSig_VaultAddPayloadSignature(g, sig, txIndex, signerAcct, vaultAcct)
+	}
+}
+
 func MultiSig_Deposit(
 	g *gwtf.GoWithTheFlow,
 	amount string,
@@ -115,7 +245,9 @@ func MultiSig_Deposit(
 	if err != nil {
 		return nil, err
 	}
-	signable, err := util.GetSignableDataFromScript(g, txIndex, method, ufix64)
+	// The tokens are withdrawn from the signer, and returned to it if the payload is removed
+	signerAddr := cadence.BytesToAddress(g.Accounts[signerAcct].Address.Bytes())
+	signable, err := util.GetSignableDataFromScript(g, vaultAcct, txIndex, method, ufix64, signerAddr)
 	if err != nil {
 		return
 	}
@@ -125,7 +257,7 @@ func MultiSig_Deposit(
 		return
 	}
 	if newPaylaod {
-		args := []cadence.Value{ufix64}
+		args := []cadence.Value{ufix64, signerAddr}
 		return util.MultiSig_VaultNewPayload(g, sig, txIndex, method, args, signerAcct, vaultAcct, amount)
 	} else {
 		return util.MultiSig_VaultAddPayloadSignature(g, sig, txIndex, signerAcct, vaultAcct)
@@ -142,7 +274,7 @@ func MultiSig_RemoveVaultedPayload(
 ) (events []*gwtf.FormatedEvent, err error) {
 
 	method := "removePayload"
-	signable, err := util.GetSignableDataFromScript(g, txIndex, method, cadence.UInt64(indexToRemove))
+	signable, err := util.GetSignableDataFromScript(g, vaultAcct, txIndex, method, cadence.UInt64(indexToRemove))
 	if err != nil {
 		return
 	}
@@ -159,20 +291,322 @@ func MultiSig_RemoveVaultedPayload(
 	}
 }
 
+// MultiSig_Migrate signs the migration of the vault of `vaultAcct` to the vault with the uuid `vaultId`,
+// which is executed by the owner with MigrateVault
+func MultiSig_Migrate(
+	g *gwtf.GoWithTheFlow,
+	vaultId uint64,
+	txIndex uint64,
+	signerAcct string,
+	vaultAcct string,
+	newPaylaod bool,
+) (events []*gwtf.FormatedEvent, err error) {
+
+	method := "migrate"
+	signable, err := util.GetSignableDataFromScript(g, vaultAcct, txIndex, method, cadence.UInt64(vaultId))
+	if err != nil {
+		return
+	}
+
+	sig, err := util.SignPayloadOffline(g, signable, signerAcct)
+	if err != nil {
+		return
+	}
+	if newPaylaod {
+		args := []cadence.Value{cadence.UInt64(vaultId)}
+		return util.MultiSig_VaultNewPayload(g, sig, txIndex, method, args, signerAcct, vaultAcct, "0.0")
+	} else {
+		return util.MultiSig_VaultAddPayloadSignature(g, sig, txIndex, signerAcct, vaultAcct)
+	}
+}
+
+// MigrateVault executes the `migrate` payload at `txIndex` of the vault of `vaultAcct` at `from`,
+// moving its balance and keys to its vault at `to`. It is signed by `vaultAcct`
+func MigrateVault(
+	g *gwtf.GoWithTheFlow,
+	vaultAcct string,
+	txIndex uint64,
+	from util.VaultPaths,
+	to util.VaultPaths,
+) (events []*gwtf.FormatedEvent, err error) {
+	e, err := client(g).MigrateVault(util.AccountRoles(vaultAcct), txIndex, from.Storage, to.Storage)
+	events = util.ParseTestEvents(e)
+	return
+}
+
 func MultiSig_VaultExecuteTx(
 	g *gwtf.GoWithTheFlow,
 	index uint64,
 	payerAcct string,
 	vaultAcct string,
 ) (events []*gwtf.FormatedEvent, err error) {
-	txFilename := "../../../transactions/executeTx.cdc"
-	txScript := util.ParseCadenceTemplate(txFilename)
-
-	e, err := g.TransactionFromFile(txFilename, txScript).
-		SignProposeAndPayAs(payerAcct).
-		AccountArgument(vaultAcct).
-		UInt64Argument(index).
-		Run()
+	e, err := client(g).ExecuteTx(util.AccountRoles(payerAcct), g.Accounts[vaultAcct].Address, util.DefaultVaultPaths.Signer, index)
 	events = util.ParseTestEvents(e)
 	return
 }
+
+// SpendingLimit mirrors `MultiSigFlowToken.SpendingLimit`
+type SpendingLimit struct {
+	Limit cadence.UFix64
+	// Period is the number of blocks the limit applies to
+	Period         uint64
+	RequiredWeight cadence.UFix64
+}
+
+// GetSpendingLimit returns nil if the vault has no spending limit
+func GetSpendingLimit(g *gwtf.GoWithTheFlow, vaultAcct string) (result *SpendingLimit, err error) {
+	value, err := client(g).GetSpendingLimit(g.Accounts[vaultAcct].Address, util.DefaultVaultPaths.Signer)
+	if err != nil {
+		return
+	}
+	optional, ok := value.(cadence.Optional)
+	if !ok {
+		err = errors.New("returned not optional")
+		return
+	}
+	if optional.Value == nil {
+		return
+	}
+	s, ok := optional.Value.(cadence.Struct)
+	if !ok || len(s.Fields) != 3 {
+		err = errors.New("returned not SpendingLimit")
+		return
+	}
+	result = &SpendingLimit{
+		Limit:          s.Fields[0].(cadence.UFix64),
+		Period:         uint64(s.Fields[1].(cadence.UInt64)),
+		RequiredWeight: s.Fields[2].(cadence.UFix64),
+	}
+	return
+}
+
+func GetRemainingSpendingLimit(g *gwtf.GoWithTheFlow, vaultAcct string) (result cadence.UFix64, err error) {
+	return client(g).GetRemainingSpendingLimit(g.Accounts[vaultAcct].Address, util.DefaultVaultPaths.Signer)
+}
+
+func MultiSig_SetSpendingLimit(
+	g *gwtf.GoWithTheFlow,
+	limit string,
+	period uint64,
+	requiredWeight string,
+	txIndex uint64,
+	signerAcct string,
+	vaultAcct string,
+	newPayload bool,
+) (events []*gwtf.FormatedEvent, err error) {
+
+	method := "setSpendingLimit"
+	cLimit, err := cadence.NewUFix64(limit)
+	if err != nil {
+		return nil, err
+	}
+	cWeight, err := cadence.NewUFix64(requiredWeight)
+	if err != nil {
+		return nil, err
+	}
+	cPeriod := cadence.UInt64(period)
+	signable, err := util.GetSignableDataFromScript(g, vaultAcct, txIndex, method, cLimit, cPeriod, cWeight)
+	if err != nil {
+		return
+	}
+
+	sig, err := util.SignPayloadOffline(g, signable, signerAcct)
+	if err != nil {
+		return
+	}
+	if newPayload {
+		args := []cadence.Value{cLimit, cPeriod, cWeight}
+		return util.MultiSig_VaultNewPayload(g, sig, txIndex, method, args, signerAcct, vaultAcct, "0.0")
+	} else {
+		return util.MultiSig_VaultAddPayloadSignature(g, sig, txIndex, signerAcct, vaultAcct)
+	}
+}
+
+func MultiSig_RemoveSpendingLimit(
+	g *gwtf.GoWithTheFlow,
+	txIndex uint64,
+	signerAcct string,
+	vaultAcct string,
+	newPayload bool,
+) (events []*gwtf.FormatedEvent, err error) {
+
+	method := "removeSpendingLimit"
+	signable, err := util.GetSignableDataFromScript(g, vaultAcct, txIndex, method)
+	if err != nil {
+		return
+	}
+
+	sig, err := util.SignPayloadOffline(g, signable, signerAcct)
+	if err != nil {
+		return
+	}
+	if newPayload {
+		return util.MultiSig_VaultNewPayload(g, sig, txIndex, method, []cadence.Value{}, signerAcct, vaultAcct, "0.0")
+	} else {
+		return util.MultiSig_VaultAddPayloadSignature(g, sig, txIndex, signerAcct, vaultAcct)
+	}
+}
+
+func MultiSig_SetMethodDelay(
+	g *gwtf.GoWithTheFlow,
+	delayedMethod string,
+	delay uint64,
+	txIndex uint64,
+	signerAcct string,
+	vaultAcct string,
+	newPayload bool,
+) (events []*gwtf.FormatedEvent, err error) {
+
+	method := "setMethodDelay"
+	cMethod := cadence.NewString(delayedMethod)
+	cDelay := cadence.UInt64(delay)
+	signable, err := util.GetSignableDataFromScript(g, vaultAcct, txIndex, method, cMethod, cDelay)
+	if err != nil {
+		return
+	}
+
+	sig, err := util.SignPayloadOffline(g, signable, signerAcct)
+	if err != nil {
+		return
+	}
+	if newPayload {
+		args := []cadence.Value{cMethod, cDelay}
+		return util.MultiSig_VaultNewPayload(g, sig, txIndex, method, args, signerAcct, vaultAcct, "0.0")
+	} else {
+		return util.MultiSig_VaultAddPayloadSignature(g, sig, txIndex, signerAcct, vaultAcct)
+	}
+}
+
+// GetAllowedRecipients returns the addresses in the recipient allowlist of the vault
+func GetAllowedRecipients(g *gwtf.GoWithTheFlow, vaultAcct string) (result []string, err error) {
+	addresses, err := client(g).GetAllowedRecipients(g.Accounts[vaultAcct].Address, util.DefaultVaultPaths.Signer)
+	for _, address := range addresses {
+		result = append(result, cadence.BytesToAddress(address.Bytes()).String())
+	}
+	return
+}
+
+// IsAllowlistEnabled returns true if multisig transfers and withdrawals from the vault are restricted by its allowlist
+func IsAllowlistEnabled(g *gwtf.GoWithTheFlow, vaultAcct string) (bool, error) {
+	return client(g).IsAllowlistEnabled(g.Accounts[vaultAcct].Address, util.DefaultVaultPaths.Signer)
+}
+
+func GetUnlistedRecipientWeight(g *gwtf.GoWithTheFlow, vaultAcct string) (result cadence.UFix64, err error) {
+	return client(g).GetUnlistedRecipientWeight(g.Accounts[vaultAcct].Address, util.DefaultVaultPaths.Signer)
+}
+
+func MultiSig_AddRecipient(
+	g *gwtf.GoWithTheFlow,
+	recipientAcct string,
+	txIndex uint64,
+	signerAcct string,
+	vaultAcct string,
+	newPayload bool,
+) (events []*gwtf.FormatedEvent, err error) {
+
+	method := "addRecipient"
+	recipientAddr := cadence.BytesToAddress(g.Accounts[recipientAcct].Address.Bytes())
+	signable, err := util.GetSignableDataFromScript(g, vaultAcct, txIndex, method, recipientAddr)
+	if err != nil {
+		return
+	}
+
+	sig, err := util.SignPayloadOffline(g, signable, signerAcct)
+	if err != nil {
+		return
+	}
+	if newPayload {
+		args := []cadence.Value{recipientAddr}
+		return util.MultiSig_VaultNewPayload(g, sig, txIndex, method, args, signerAcct, vaultAcct, "0.0")
+	} else {
+		return util.MultiSig_VaultAddPayloadSignature(g, sig, txIndex, signerAcct, vaultAcct)
+	}
+}
+
+func MultiSig_RemoveRecipient(
+	g *gwtf.GoWithTheFlow,
+	recipientAcct string,
+	txIndex uint64,
+	signerAcct string,
+	vaultAcct string,
+	newPayload bool,
+) (events []*gwtf.FormatedEvent, err error) {
+
+	method := "removeRecipient"
+	recipientAddr := cadence.BytesToAddress(g.Accounts[recipientAcct].Address.Bytes())
+	signable, err := util.GetSignableDataFromScript(g, vaultAcct, txIndex, method, recipientAddr)
+	if err != nil {
+		return
+	}
+
+	sig, err := util.SignPayloadOffline(g, signable, signerAcct)
+	if err != nil {
+		return
+	}
+	if newPayload {
+		args := []cadence.Value{recipientAddr}
+		return util.MultiSig_VaultNewPayload(g, sig, txIndex, method, args, signerAcct, vaultAcct, "0.0")
+	} else {
+		return util.MultiSig_VaultAddPayloadSignature(g, sig, txIndex, signerAcct, vaultAcct)
+	}
+}
+
+// MultiSig_DisableAllowlist removes all the addresses from the allowlist and disables it,
+// allowing transfers and withdrawals to any address
+func MultiSig_DisableAllowlist(
+	g *gwtf.GoWithTheFlow,
+	txIndex uint64,
+	signerAcct string,
+	vaultAcct string,
+	newPayload bool,
+) (events []*gwtf.FormatedEvent, err error) {
+
+	method := "disableAllowlist"
+	signable, err := util.GetSignableDataFromScript(g, vaultAcct, txIndex, method)
+	if err != nil {
+		return
+	}
+
+	sig, err := util.SignPayloadOffline(g, signable, signerAcct)
+	if err != nil {
+		return
+	}
+	if newPayload {
+		return util.MultiSig_VaultNewPayload(g, sig, txIndex, method, []cadence.Value{}, signerAcct, vaultAcct, "0.0")
+	} else {
+		return util.MultiSig_VaultAddPayloadSignature(g, sig, txIndex, signerAcct, vaultAcct)
+	}
+}
+
+// MultiSig_SetUnlistedRecipientWeight sets the approval weight required for transfers to addresses
+// outside of the allowlist, "0.0" rejects such transfers
+func MultiSig_SetUnlistedRecipientWeight(
+	g *gwtf.GoWithTheFlow,
+	weight string,
+	txIndex uint64,
+	signerAcct string,
+	vaultAcct string,
+	newPayload bool,
+) (events []*gwtf.FormatedEvent, err error) {
+
+	method := "setUnlistedRecipientWeight"
+	cWeight, err := cadence.NewUFix64(weight)
+	if err != nil {
+		return nil, err
+	}
+	signable, err := util.GetSignableDataFromScript(g, vaultAcct, txIndex, method, cWeight)
+	if err != nil {
+		return
+	}
+
+	sig, err := util.SignPayloadOffline(g, signable, signerAcct)
+	if err != nil {
+		return
+	}
+	if newPayload {
+		args := []cadence.Value{cWeight}
+		return util.MultiSig_VaultNewPayload(g, sig, txIndex, method, args, signerAcct, vaultAcct, "0.0")
+	} else {
+		return util.MultiSig_VaultAddPayloadSignature(g, sig, txIndex, signerAcct, vaultAcct)
+	}
+}
@@ -2,52 +2,113 @@ package vault
 
 import (
 	"fmt"
+	"net/http/httptest"
+	"path/filepath"
 	"strconv"
 	"testing"
 
-	"github.com/bjartek/go-with-the-flow/gwtf"
 	util "github.com/flow-hydraulics/onchain-multisig"
+	"github.com/flow-hydraulics/onchain-multisig/encoder"
 	"github.com/flow-hydraulics/onchain-multisig/keys"
+	"github.com/flow-hydraulics/onchain-multisig/signer"
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestAddVaultToAccount(t *testing.T) {
-	g := gwtf.NewGoWithTheFlow("../../../flow.json")
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := NewTestFixture(t, g)
+	vaultAcct := f.Vault
 
-	_, err := AddVaultToAccount(g, "vaulted-account")
+	balance, err := util.GetBalance(g, vaultAcct)
 	assert.NoError(t, err)
+	assert.Equal(t, "100.00000000", balance.String())
 
-	balance, err := util.GetBalance(g, "vaulted-account")
+	keys, err := util.GetStoreKeys(g, vaultAcct)
 	assert.NoError(t, err)
-	assert.Equal(t, balance.String(), "0.00000000")
+	assert.Len(t, keys, 5)
+
+	txIndex, err := util.GetTxIndex(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, txIndex, uint64(0))
+
+	// Adding a vault again fails and leaves the vault as it was
+	_, err = AddVaultWithKeys(g, vaultAcct, f.Signers[1:3], []string{"500.0", "500.0"})
+	assert.Error(t, err)
+
+	balance, err = util.GetBalance(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, "100.00000000", balance.String())
 
-	keys, err := util.GetStoreKeys(g, "vaulted-account")
+	keys, err = util.GetStoreKeys(g, vaultAcct)
 	assert.NoError(t, err)
 	assert.Len(t, keys, 5)
+}
 
-	txIndex, err := util.GetTxIndex(g, "vaulted-account")
+func TestMOfNFixture(t *testing.T) {
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := NewTestFixture(t, g, MOfN(2, 3)...)
+	transferAmount := "1.00000000"
+
+	weight, err := util.GetKeyWeight(g, f.Vault, f.Signers[0])
 	assert.NoError(t, err)
-	assert.Equal(t, txIndex, uint64(0))
+	assert.Equal(t, "500.00000000", weight.String())
 
-	transferAmount := "100.00000000"
-	_, err = AccountSignerTransferTokens(g, transferAmount, "owner", "vaulted-account")
+	_, err = MultiSig_Transfer(g, transferAmount, f.Payer, 1, f.Signers[2], f.Vault, true)
 	assert.NoError(t, err)
 
-	balanceA, err := util.GetBalance(g, "vaulted-account")
+	// 1 of 3 is not enough
+	_, err = MultiSig_VaultExecuteTx(g, 1, f.Payer, f.Vault)
+	assert.Error(t, err)
+
+	_, err = MultiSig_Transfer(g, transferAmount, f.Payer, 1, f.Signers[0], f.Vault, false)
 	assert.NoError(t, err)
-	assert.Equal(t, transferAmount, balanceA.String())
+
+	_, err = MultiSig_VaultExecuteTx(g, 1, f.Payer, f.Vault)
+	assert.NoError(t, err)
+
+	balance, err := util.GetBalance(g, f.Payer)
+	assert.NoError(t, err)
+	assert.Equal(t, transferAmount, balance.String())
+}
+
+func TestMOfN(t *testing.T) {
+	assert.Equal(t, []string{"1000.00000000"}, MOfN(1, 1))
+	assert.Equal(t, []string{"500.00000000", "500.00000000", "500.00000000"}, MOfN(2, 3))
+	// Rounded up so that 3 keys have the approval weight
+	assert.Equal(t, []string{"333.33333334", "333.33333334", "333.33333334"}, MOfN(3, 3))
+}
+
+// addPendingTransfer adds a transfer payload signed by `signerAcct` to the vault of the fixture
+// and checks that it is at the next txIndex
+func addPendingTransfer(t *testing.T, f Fixture, amount string, signerAcct string) (txIndex uint64) {
+	initTxIndex, err := util.GetTxIndex(f.G, f.Vault)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_Transfer(f.G, amount, f.Payer, initTxIndex+uint64(1), signerAcct, f.Vault, true)
+	assert.NoError(t, err)
+
+	txIndex, err = util.GetTxIndex(f.G, f.Vault)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), txIndex-initTxIndex)
+	return
 }
 
 func TestAddNewPendingTransferPayloadWithFullMultiSigAccount(t *testing.T) {
-	g := gwtf.NewGoWithTheFlow("../../../flow.json")
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := NewTestFixture(t, g)
 	transferAmount := "15.5"
-	transferTo := "owner"
-	vaultAcct := "vaulted-account"
+	vaultAcct := f.Vault
+	acct1000 := f.Signers[0]
 
 	initTxIndex, err := util.GetTxIndex(g, vaultAcct)
 	assert.NoError(t, err)
 
-	events, err := MultiSig_Transfer(g, transferAmount, transferTo, initTxIndex+uint64(1), Acct1000, vaultAcct, true)
+	events, err := MultiSig_Transfer(g, transferAmount, f.Payer, initTxIndex+uint64(1), acct1000, vaultAcct, true)
 	assert.NoError(t, err)
 
 	postTxIndex, err := util.GetTxIndex(g, vaultAcct)
@@ -60,21 +121,26 @@ func TestAddNewPendingTransferPayloadWithFullMultiSigAccount(t *testing.T) {
 	util.NewExpectedEvent("OnChainMultiSig", "NewPayloadAdded").
 		AddField("resourceId", strconv.Itoa(int(uuid))).
 		AddField("txIndex", strconv.Itoa(int(postTxIndex))).
+		AddField("method", "transfer").
+		AddField("publicKey", signer.PublicKeyHex(util.GetSigner(g, acct1000))).
 		AssertEqual(t, events[0])
 
 	fmt.Println("postTxindex: ", postTxIndex)
 }
 
 func TestAddNewPendingTransferPayloadUnknowAcct(t *testing.T) {
-	g := gwtf.NewGoWithTheFlow("../../../flow.json")
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := NewTestFixture(t, g)
 	transferAmount := "15.5000000"
-	transferTo := "owner"
-	vaultAcct := "vaulted-account"
+	vaultAcct := f.Vault
+	// The payer has no key in the vault
+	unknownAcct := f.Payer
 
 	initTxIndex, err := util.GetTxIndex(g, vaultAcct)
 	assert.NoError(t, err)
 
-	_, err = MultiSig_Transfer(g, transferAmount, transferTo, initTxIndex+uint64(1), "non-registered-account", vaultAcct, true)
+	_, err = MultiSig_Transfer(g, transferAmount, f.Payer, initTxIndex+uint64(1), unknownAcct, vaultAcct, true)
 	assert.Error(t, err)
 
 	postTxIndex, err := util.GetTxIndex(g, vaultAcct)
@@ -83,18 +149,19 @@ func TestAddNewPendingTransferPayloadUnknowAcct(t *testing.T) {
 }
 
 func TestExecutePendingTransnferFromFullAcctOnlyOnce(t *testing.T) {
-	g := gwtf.NewGoWithTheFlow("../../../flow.json")
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := NewTestFixture(t, g)
 	transferAmount := "15.50000000"
-	payerAcct := "owner"
-	vaultAcct := "vaulted-account"
+	payerAcct := f.Payer
+	vaultAcct := f.Vault
 
-	initFromBalance, err := util.GetBalance(g, vaultAcct)
-	assert.NoError(t, err)
+	txIndex := addPendingTransfer(t, f, transferAmount, f.Signers[0])
 
-	initTxIndex, err := util.GetTxIndex(g, vaultAcct)
+	initFromBalance, err := util.GetBalance(g, vaultAcct)
 	assert.NoError(t, err)
 
-	_, err = MultiSig_VaultExecuteTx(g, initTxIndex, payerAcct, vaultAcct)
+	_, err = MultiSig_VaultExecuteTx(g, txIndex, payerAcct, vaultAcct)
 	assert.NoError(t, err)
 
 	postFromBalance, err := util.GetBalance(g, vaultAcct)
@@ -102,35 +169,29 @@ func TestExecutePendingTransnferFromFullAcctOnlyOnce(t *testing.T) {
 
 	assert.Equal(t, transferAmount, (initFromBalance - postFromBalance).String())
 
-	_, err = MultiSig_VaultExecuteTx(g, initTxIndex, payerAcct, vaultAcct)
+	_, err = MultiSig_VaultExecuteTx(g, txIndex, payerAcct, vaultAcct)
 	assert.Error(t, err)
 }
 
 func TestExecutePayloadWithMultipleSig(t *testing.T) {
-	g := gwtf.NewGoWithTheFlow("../../../flow.json")
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := NewTestFixture(t, g)
 	transferAmount := "15.50000000"
-	transferTo := "owner"
-	payerAcct := "owner"
+	transferTo := f.Payer
+	payerAcct := f.Payer
+	vaultAcct := f.Vault
+	acct500_1, acct500_2, acct250_1 := f.Signers[1], f.Signers[2], f.Signers[3]
 
 	//
 	// First add a payload; total authorised weight is 500
 	//
-	vaultAcct := "vaulted-account"
-
-	initTxIndex, err := util.GetTxIndex(g, vaultAcct)
-	assert.NoError(t, err)
-
-	_, err = MultiSig_Transfer(g, transferAmount, transferTo, initTxIndex+uint64(1), Acct500_1, vaultAcct, true)
-	assert.NoError(t, err)
-
-	postTxIndex, err := util.GetTxIndex(g, vaultAcct)
-	assert.NoError(t, err)
-	assert.Equal(t, uint64(1), postTxIndex-initTxIndex)
+	postTxIndex := addPendingTransfer(t, f, transferAmount, acct500_1)
 
 	//
 	// Add another signature; total weight now is 500 + 250
 	//
-	events, err := MultiSig_Transfer(g, transferAmount, transferTo, postTxIndex, Acct250_1, vaultAcct, false)
+	events, err := MultiSig_Transfer(g, transferAmount, transferTo, postTxIndex, acct250_1, vaultAcct, false)
 	assert.NoError(t, err)
 
 	uuid, err := util.GetVaultUUID(g, vaultAcct)
@@ -138,6 +199,8 @@ func TestExecutePayloadWithMultipleSig(t *testing.T) {
 	util.NewExpectedEvent("OnChainMultiSig", "NewPayloadSigAdded").
 		AddField("resourceId", strconv.Itoa(int(uuid))).
 		AddField("txIndex", strconv.Itoa(int(postTxIndex))).
+		AddField("method", "transfer").
+		AddField("publicKey", signer.PublicKeyHex(util.GetSigner(g, acct250_1))).
 		AssertEqual(t, events[0])
 
 	// This should fail because the weight is less than 1000
@@ -147,144 +210,149 @@ func TestExecutePayloadWithMultipleSig(t *testing.T) {
 	//
 	// Add another signature; total weight now is 500 + 250 + 500
 	//
-	_, err = MultiSig_Transfer(g, transferAmount, transferTo, postTxIndex, Acct500_2, vaultAcct, false)
+	_, err = MultiSig_Transfer(g, transferAmount, transferTo, postTxIndex, acct500_2, vaultAcct, false)
 	assert.NoError(t, err)
 
 	initFromBalance, err := util.GetBalance(g, vaultAcct)
 	assert.NoError(t, err)
 
-	_, err = MultiSig_VaultExecuteTx(g, postTxIndex, payerAcct, vaultAcct)
+	events, err = MultiSig_VaultExecuteTx(g, postTxIndex, payerAcct, vaultAcct)
 	assert.NoError(t, err)
 
+	util.NewExpectedEvent("OnChainMultiSig", "PayloadExecuted").
+		AddField("resourceId", strconv.Itoa(int(uuid))).
+		AddField("txIndex", strconv.Itoa(int(postTxIndex))).
+		AddField("method", "transfer").
+		AddField("signers", []string{
+			signer.PublicKeyHex(util.GetSigner(g, acct500_1)),
+			signer.PublicKeyHex(util.GetSigner(g, acct250_1)),
+			signer.PublicKeyHex(util.GetSigner(g, acct500_2)),
+		}).
+		AddField("weight", "1250.00000000").
+		AssertEqual(t, events[0])
+
 	postFromBalance, err := util.GetBalance(g, vaultAcct)
 	assert.NoError(t, err)
 	assert.Equal(t, transferAmount, (initFromBalance - postFromBalance).String())
 }
 
 func TestRemovedAcctWeightsDoNotCount(t *testing.T) {
-	g := gwtf.NewGoWithTheFlow("../../../flow.json")
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := NewTestFixture(t, g)
 	transferAmount := "15.50000000"
-	transferTo := "owner"
+	transferTo := f.Payer
+	payerAcct := f.Payer
+	vaultAcct := f.Vault
+	acct1000, acct500_1, acct500_2, acct250_1, acct250_2 := f.Signers[0], f.Signers[1], f.Signers[2], f.Signers[3], f.Signers[4]
 
 	//
 	// First add a payload; total authorised weight is 500
 	//
-	vaultAcct := "vaulted-account"
-
-	initTxIndex, err := util.GetTxIndex(g, vaultAcct)
-	assert.NoError(t, err)
-
-	_, err = MultiSig_Transfer(g, transferAmount, transferTo, initTxIndex+uint64(1), Acct500_1, vaultAcct, true)
-	assert.NoError(t, err)
-
-	transferTxIndex, err := util.GetTxIndex(g, vaultAcct)
-	assert.NoError(t, err)
-	assert.Equal(t, uint64(1), transferTxIndex-initTxIndex)
+	transferTxIndex := addPendingTransfer(t, f, transferAmount, acct500_1)
 
 	//
 	// Add another signature; total weight now is 500 + 250
 	//
-	_, err = MultiSig_Transfer(g, transferAmount, transferTo, transferTxIndex, Acct250_1, vaultAcct, false)
+	_, err := MultiSig_Transfer(g, transferAmount, transferTo, transferTxIndex, acct250_1, vaultAcct, false)
 	assert.NoError(t, err)
 
 	//
 	// Add another signature; total weight now is 500 + 250 + 250 = 1000
 	//
-	_, err = MultiSig_Transfer(g, transferAmount, transferTo, transferTxIndex, Acct250_2, vaultAcct, false)
+	_, err = MultiSig_Transfer(g, transferAmount, transferTo, transferTxIndex, acct250_2, vaultAcct, false)
 	assert.NoError(t, err)
 
 	//
-	// Now we remove the key for Acct250_1
+	// Now we remove the key for acct250_2
 	//
 
 	removeTxIndex := transferTxIndex + 1
 
-	_, err = keys.MultiSig_RemoveKey(g, Acct250_2, removeTxIndex, Acct1000, vaultAcct, true)
+	_, err = keys.MultiSig_RemoveKey(g, acct250_2, removeTxIndex, acct1000, vaultAcct, true)
 	assert.NoError(t, err)
 
-	_, err = MultiSig_VaultExecuteTx(g, removeTxIndex, "owner", vaultAcct)
+	_, err = MultiSig_VaultExecuteTx(g, removeTxIndex, payerAcct, vaultAcct)
 	assert.NoError(t, err)
 
-	// There should not have enough weight now as Acct250_2 has been removed
-	_, err = MultiSig_VaultExecuteTx(g, transferTxIndex, "owner", vaultAcct)
+	// There should not have enough weight now as acct250_2 has been removed
+	_, err = MultiSig_VaultExecuteTx(g, transferTxIndex, payerAcct, vaultAcct)
 	assert.Error(t, err)
 
 	//
 	// Add another signature; total weight now is 500 + 250 + 250 - 250 + 500 > 1000
 	//
-	_, err = MultiSig_Transfer(g, transferAmount, transferTo, transferTxIndex, Acct500_2, vaultAcct, false)
+	_, err = MultiSig_Transfer(g, transferAmount, transferTo, transferTxIndex, acct500_2, vaultAcct, false)
 	assert.NoError(t, err)
 
 	// There should now be enough weight
-	_, err = MultiSig_VaultExecuteTx(g, transferTxIndex, "owner", vaultAcct)
+	_, err = MultiSig_VaultExecuteTx(g, transferTxIndex, payerAcct, vaultAcct)
 	assert.NoError(t, err)
 }
+
 func TestSameAcctCannotAddMultipleSigPerTxIndex(t *testing.T) {
-	g := gwtf.NewGoWithTheFlow("../../../flow.json")
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := NewTestFixture(t, g)
 	transferAmount := "15.50000000"
-	transferTo := "owner"
+	transferTo := f.Payer
+	vaultAcct := f.Vault
+	acct500_1, acct250_1 := f.Signers[1], f.Signers[3]
 
 	//
 	// First add a payload; total authorised weight is 500
 	//
-	vaultAcct := "vaulted-account"
-
-	initTxIndex, err := util.GetTxIndex(g, vaultAcct)
-	assert.NoError(t, err)
-
-	_, err = MultiSig_Transfer(g, transferAmount, transferTo, initTxIndex+uint64(1), Acct500_1, vaultAcct, true)
-	assert.NoError(t, err)
-
-	postTxIndex, err := util.GetTxIndex(g, vaultAcct)
-	assert.NoError(t, err)
-	assert.Equal(t, uint64(1), postTxIndex-initTxIndex)
+	postTxIndex := addPendingTransfer(t, f, transferAmount, acct500_1)
 
 	//
 	// Add another signature; total weight now is 500 + 250
 	//
-	_, err = MultiSig_Transfer(g, transferAmount, transferTo, postTxIndex, Acct250_1, vaultAcct, false)
+	_, err := MultiSig_Transfer(g, transferAmount, transferTo, postTxIndex, acct250_1, vaultAcct, false)
 	assert.NoError(t, err)
 
 	// Same account cannot add signature again
-	_, err = MultiSig_Transfer(g, transferAmount, transferTo, postTxIndex, Acct250_1, vaultAcct, false)
+	_, err = MultiSig_Transfer(g, transferAmount, transferTo, postTxIndex, acct250_1, vaultAcct, false)
 	assert.Error(t, err)
 
 }
 
 func TestDepositWithFullMultiSigAccount(t *testing.T) {
-	g := gwtf.NewGoWithTheFlow("../../../flow.json")
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := NewTestFixture(t, g)
 	transferAmount := "15.50000000"
-	vaultAcct := "vaulted-account"
+	vaultAcct := f.Vault
+	acct1000 := f.Signers[0]
 
 	initTxIndex, err := util.GetTxIndex(g, vaultAcct)
 	assert.NoError(t, err)
 
-	_, err = AddVaultToAccount(g, Acct1000)
+	_, err = AddVaultWithKeys(g, acct1000, nil, nil)
 	assert.NoError(t, err)
 
 	// give one of the multisig signers some tokens
 	seedAmount := "100.00000000"
-	_, err = AccountSignerTransferTokens(g, seedAmount, "owner", Acct1000)
+	err = f.Fund(acct1000, seedAmount)
 	assert.NoError(t, err)
 
-	balanceA, err := util.GetBalance(g, Acct1000)
+	balanceA, err := util.GetBalance(g, acct1000)
 	assert.NoError(t, err)
 
 	balanceAV, err := util.GetBalance(g, vaultAcct)
 	assert.NoError(t, err)
 
-	// transferAmount will come out of the acct who adds the payload, Acct1000
-	_, err = MultiSig_Deposit(g, transferAmount, initTxIndex+uint64(1), Acct1000, vaultAcct, true)
+	// transferAmount will come out of the acct who adds the payload, acct1000
+	_, err = MultiSig_Deposit(g, transferAmount, initTxIndex+uint64(1), acct1000, vaultAcct, true)
 	assert.NoError(t, err)
 
-	balanceB, err := util.GetBalance(g, Acct1000)
+	balanceB, err := util.GetBalance(g, acct1000)
 	assert.NoError(t, err)
 
 	postTxIndex, err := util.GetTxIndex(g, vaultAcct)
 	assert.NoError(t, err)
 	assert.Equal(t, uint64(1), postTxIndex-initTxIndex)
 
-	events, err := MultiSig_VaultExecuteTx(g, postTxIndex, "owner", vaultAcct)
+	events, err := MultiSig_VaultExecuteTx(g, postTxIndex, f.Payer, vaultAcct)
 	assert.NoError(t, err)
 
 	balanceBV, err := util.GetBalance(g, vaultAcct)
@@ -299,41 +367,901 @@ func TestDepositWithFullMultiSigAccount(t *testing.T) {
 }
 
 func TestRemoveResourceWithFullMultiSigAccount(t *testing.T) {
-	g := gwtf.NewGoWithTheFlow("../../../flow.json")
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := NewTestFixture(t, g)
 	transferAmount := "15.50000000"
-	vaultAcct := "vaulted-account"
+	vaultAcct := f.Vault
+	acct1000 := f.Signers[0]
+
+	_, err := AddVaultWithKeys(g, acct1000, nil, nil)
+	assert.NoError(t, err)
+	err = f.Fund(acct1000, "100.0")
+	assert.NoError(t, err)
 
 	initTxIndex, err := util.GetTxIndex(g, vaultAcct)
 	assert.NoError(t, err)
 
-	balanceA, err := util.GetBalance(g, Acct1000)
+	balanceA, err := util.GetBalance(g, acct1000)
 	assert.NoError(t, err)
 
-	ownerBalanceA, err := util.GetBalance(g, "owner")
+	payerBalanceA, err := util.GetBalance(g, f.Payer)
 	assert.NoError(t, err)
 
-	// transferAmount will come out of the acct who adds the payload, Acct1000
-	_, err = MultiSig_Deposit(g, transferAmount, initTxIndex+uint64(1), Acct1000, vaultAcct, true)
+	// transferAmount will come out of the acct who adds the payload, acct1000
+	_, err = MultiSig_Deposit(g, transferAmount, initTxIndex+uint64(1), acct1000, vaultAcct, true)
 	assert.NoError(t, err)
 
-	balanceB, err := util.GetBalance(g, Acct1000)
+	balanceB, err := util.GetBalance(g, acct1000)
 	assert.NoError(t, err)
 
 	indexToRemove, err := util.GetTxIndex(g, vaultAcct)
 	assert.NoError(t, err)
 
-	_, err = MultiSig_RemoveVaultedPayload(g, indexToRemove+uint64(1), indexToRemove, Acct1000, vaultAcct, true)
+	_, err = MultiSig_RemoveVaultedPayload(g, indexToRemove+uint64(1), indexToRemove, acct1000, vaultAcct, true)
 	assert.NoError(t, err)
 
-	_, err = MultiSig_VaultExecuteTx(g, indexToRemove+uint64(1), "owner", vaultAcct)
+	vaultBalanceB, err := util.GetBalance(g, vaultAcct)
 	assert.NoError(t, err)
 
-	_, err = MultiSig_VaultExecuteTx(g, indexToRemove, "owner", vaultAcct)
+	// The removed deposit is returned to its proposer, not to the account executing the removal
+	events, err := MultiSig_VaultExecuteTx(g, indexToRemove+uint64(1), f.Payer, vaultAcct)
+	assert.NoError(t, err)
+
+	uuid, err := util.GetVaultUUID(g, vaultAcct)
+	assert.NoError(t, err)
+	util.NewExpectedEvent("OnChainMultiSig", "PayloadRemoved").
+		AddField("resourceId", strconv.Itoa(int(uuid))).
+		AddField("txIndex", strconv.Itoa(int(indexToRemove))).
+		AddField("method", "deposit").
+		AssertEqual(t, events[1])
+
+	_, err = MultiSig_VaultExecuteTx(g, indexToRemove, f.Payer, vaultAcct)
 	assert.Error(t, err)
 
-	ownerBalanceB, err := util.GetBalance(g, "owner")
+	balanceC, err := util.GetBalance(g, acct1000)
+	assert.NoError(t, err)
+	payerBalanceB, err := util.GetBalance(g, f.Payer)
+	assert.NoError(t, err)
+	vaultBalanceC, err := util.GetBalance(g, vaultAcct)
 	assert.NoError(t, err)
 
 	assert.Equal(t, transferAmount, (balanceA - balanceB).String())
-	assert.Equal(t, transferAmount, (ownerBalanceB - ownerBalanceA).String())
+	assert.Equal(t, balanceA, balanceC)
+	assert.Equal(t, payerBalanceA, payerBalanceB)
+	assert.Equal(t, vaultBalanceB, vaultBalanceC)
+}
+
+func TestRemovedDepositIsKeptWithoutProposerReceiver(t *testing.T) {
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := NewTestFixture(t, g)
+	acct1000 := f.Signers[0]
+
+	_, err := AddVaultWithKeys(g, acct1000, nil, nil)
+	assert.NoError(t, err)
+	err = f.Fund(acct1000, "100.0")
+	assert.NoError(t, err)
+
+	_, err = MultiSig_Deposit(g, "15.5", 1, acct1000, f.Vault, true)
+	assert.NoError(t, err)
+
+	// The proposer unlinks its receiver, which does not block the removal:
+	// the tokens are deposited to the vault rather than returned to the executor
+	unlink := []byte("transaction { prepare(acct: AuthAccount) { acct.unlink(/public/vaultReceive) } }")
+	_, err = util.SendTransaction(g, util.AccountRoles(acct1000), "unlink_receiver.cdc", unlink)
+	assert.NoError(t, err)
+	_, err = MultiSig_RemoveVaultedPayload(g, 2, 1, acct1000, f.Vault, true)
+	assert.NoError(t, err)
+	_, err = MultiSig_VaultExecuteTx(g, 2, f.Payer, f.Vault)
+	assert.NoError(t, err)
+
+	// and no further deposit can be proposed without a receiver
+	_, err = MultiSig_Deposit(g, "1.0", 3, acct1000, f.Vault, true)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "The proposer has no receiver to return the vault to")
+	}
+
+	proposerBalance, err := util.GetBalance(g, acct1000)
+	assert.NoError(t, err)
+	assert.Equal(t, "84.50000000", proposerBalance.String())
+	payerBalance, err := util.GetBalance(g, f.Payer)
+	assert.NoError(t, err)
+	assert.Equal(t, "0.00000000", payerBalance.String())
+	vaultBalance, err := util.GetBalance(g, f.Vault)
+	assert.NoError(t, err)
+	assert.Equal(t, "115.50000000", vaultBalance.String())
+}
+
+func TestDepositsAreReturnedToTheirProposer(t *testing.T) {
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := NewTestFixture(t, g)
+	acct1000 := f.Signers[0]
+
+	_, err := AddVaultWithKeys(g, acct1000, nil, nil)
+	assert.NoError(t, err)
+	err = f.Fund(acct1000, "100.0")
+	assert.NoError(t, err)
+
+	imports := fmt.Sprintf(`import FungibleToken from 0x%s
+import MultiSigFlowToken from 0x%s
+import OnChainMultiSig from 0x%s
+`, util.EmulatorAddresses.FungibleToken, util.EmulatorAddresses.MultiSigFlowToken, util.EmulatorAddresses.OnChainMultiSig)
+	args := []cadence.Value{
+		cadence.BytesToAddress(g.Accounts[f.Vault].Address.Bytes()),
+		cadence.BytesToAddress(g.Accounts[f.Payer].Address.Bytes()),
+	}
+
+	// A payload holding a vault cannot be added with another address to return it to than its proposer's
+	addNewPayload := []byte(imports + `transaction(addr: Address, returnTo: Address) { prepare(acct: AuthAccount) {
+    let from = acct.borrow<&MultiSigFlowToken.Vault>(from: MultiSigFlowToken.VaultStoragePath)!
+    let vault <- from.withdraw(amount: 10.0)
+    let p <- OnChainMultiSig.createPayload(txIndex: 1, method: "deposit", args: [10.0 as UFix64, returnTo as Address] as [AnyStruct], rsc: <-vault)
+    getAccount(addr).getCapability(MultiSigFlowToken.VaultPubSigner).borrow<&MultiSigFlowToken.Vault{OnChainMultiSig.PublicSigner}>()!
+        .addNewPayload(payload: <-p, publicKey: "", sig: [])
+} }`)
+	_, err = util.SendTransaction(g, util.AccountRoles(acct1000), "add_vault_payload.cdc", addNewPayload, args...)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Payloads holding a vault must be added with addNewPayloadFrom")
+	}
+	addNewPayloadFrom := []byte(imports + `transaction(addr: Address, returnTo: Address) { prepare(acct: AuthAccount) {
+    let from = acct.borrow<&MultiSigFlowToken.Vault>(from: MultiSigFlowToken.VaultStoragePath)!
+    getAccount(addr).getCapability(MultiSigFlowToken.VaultPubSigner).borrow<&MultiSigFlowToken.Vault{OnChainMultiSig.PublicSigner}>()!
+        .addNewPayloadFrom(txIndex: 1, method: "deposit", args: [10.0 as UFix64, returnTo as Address] as [AnyStruct], publicKey: "", sig: [], from: from)
+} }`)
+	_, err = util.SendTransaction(g, util.AccountRoles(acct1000), "add_vault_payload_from.cdc", addNewPayloadFrom, args...)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Second argument must be the address of the proposer")
+	}
+
+	txIndex, err := util.GetTxIndex(g, f.Vault)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), txIndex)
+	balance, err := util.GetBalance(g, acct1000)
+	assert.NoError(t, err)
+	assert.Equal(t, "100.00000000", balance.String())
+}
+
+func TestSpendingLimitLowersRequiredWeightUntilBudgetIsSpent(t *testing.T) {
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := NewTestFixture(t, g)
+	transferTo := f.Payer
+	payerAcct := f.Payer
+	vaultAcct := f.Vault
+	acct1000, acct500_1, acct500_2 := f.Signers[0], f.Signers[1], f.Signers[2]
+	period := uint64(20)
+
+	limit, err := GetSpendingLimit(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Nil(t, limit)
+
+	// Setting a spending limit requires the full approval weight
+	txIndex, err := util.GetTxIndex(g, vaultAcct)
+	assert.NoError(t, err)
+	txIndex = txIndex + 1
+
+	_, err = MultiSig_SetSpendingLimit(g, "10.0", period, "500.0", txIndex, acct500_1, vaultAcct, true)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex, payerAcct, vaultAcct)
+	assert.Error(t, err)
+
+	_, err = MultiSig_SetSpendingLimit(g, "10.0", period, "500.0", txIndex, acct500_2, vaultAcct, false)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex, payerAcct, vaultAcct)
+	assert.NoError(t, err)
+
+	limit, err = GetSpendingLimit(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.00000000", limit.Limit.String())
+	assert.Equal(t, period, limit.Period)
+	assert.Equal(t, "500.00000000", limit.RequiredWeight.String())
+
+	remaining, err := GetRemainingSpendingLimit(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.00000000", remaining.String())
+
+	// A single 500 weight key can transfer within the limit
+	initFromBalance, err := util.GetBalance(g, vaultAcct)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_Transfer(g, "6.0", transferTo, txIndex+1, acct500_1, vaultAcct, true)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex+1, payerAcct, vaultAcct)
+	assert.NoError(t, err)
+
+	postFromBalance, err := util.GetBalance(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, "6.00000000", (initFromBalance - postFromBalance).String())
+
+	remaining, err = GetRemainingSpendingLimit(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, "4.00000000", remaining.String())
+
+	// but not beyond the remaining limit
+	_, err = MultiSig_Transfer(g, "6.0", transferTo, txIndex+2, acct500_1, vaultAcct, true)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex+2, payerAcct, vaultAcct)
+	assert.Error(t, err)
+
+	// The limit is available again once the period has passed
+	err = util.AdvanceBlocks(g, period, payerAcct)
+	assert.NoError(t, err)
+
+	remaining, err = GetRemainingSpendingLimit(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.00000000", remaining.String())
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex+2, payerAcct, vaultAcct)
+	assert.NoError(t, err)
+
+	// Amounts larger than the limit always require the full approval weight
+	_, err = MultiSig_Transfer(g, "15.5", transferTo, txIndex+3, acct500_1, vaultAcct, true)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex+3, payerAcct, vaultAcct)
+	assert.Error(t, err)
+
+	_, err = MultiSig_Transfer(g, "15.5", transferTo, txIndex+3, acct500_2, vaultAcct, false)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex+3, payerAcct, vaultAcct)
+	assert.NoError(t, err)
+
+	// Removing the limit
+	_, err = MultiSig_RemoveSpendingLimit(g, txIndex+4, acct1000, vaultAcct, true)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex+4, payerAcct, vaultAcct)
+	assert.NoError(t, err)
+
+	limit, err = GetSpendingLimit(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Nil(t, limit)
+}
+
+func TestTimelockedPayloadCannotExecuteEarly(t *testing.T) {
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := NewTestFixture(t, g)
+	transferTo := f.Payer
+	payerAcct := f.Payer
+	vaultAcct := f.Vault
+	acct1000, acct500_1, acct500_2 := f.Signers[0], f.Signers[1], f.Signers[2]
+	delay := uint64(10)
+
+	txIndex, err := util.GetTxIndex(g, vaultAcct)
+	assert.NoError(t, err)
+	txIndex = txIndex + 1
+
+	_, err = MultiSig_SetMethodDelay(g, "transfer", delay, txIndex, acct1000, vaultAcct, true)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex, payerAcct, vaultAcct)
+	assert.NoError(t, err)
+
+	d, err := util.GetMethodDelay(g, vaultAcct, "transfer")
+	assert.NoError(t, err)
+	assert.Equal(t, delay, d)
+
+	// The timelock has not started before the payload has enough weight
+	_, err = MultiSig_Transfer(g, "5.0", transferTo, txIndex+1, acct500_1, vaultAcct, true)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex+1, payerAcct, vaultAcct)
+	assert.Error(t, err)
+
+	_, started, err := util.GetTimelockRemaining(g, vaultAcct, txIndex+1)
+	assert.NoError(t, err)
+	assert.False(t, started)
+
+	// The first execution with enough weight starts the timelock without transferring
+	_, err = MultiSig_Transfer(g, "5.0", transferTo, txIndex+1, acct500_2, vaultAcct, false)
+	assert.NoError(t, err)
+
+	initFromBalance, err := util.GetBalance(g, vaultAcct)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex+1, payerAcct, vaultAcct)
+	assert.NoError(t, err)
+
+	postFromBalance, err := util.GetBalance(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, initFromBalance, postFromBalance)
+
+	remaining, started, err := util.GetTimelockRemaining(g, vaultAcct, txIndex+1)
+	assert.NoError(t, err)
+	assert.True(t, started)
+	assert.True(t, remaining > 0 && remaining <= delay)
+
+	// Executing before the timelock has passed fails
+	_, err = MultiSig_VaultExecuteTx(g, txIndex+1, payerAcct, vaultAcct)
+	assert.Error(t, err)
+
+	err = util.AdvanceBlocks(g, delay, payerAcct)
+	assert.NoError(t, err)
+
+	remaining, _, err = util.GetTimelockRemaining(g, vaultAcct, txIndex+1)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), remaining)
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex+1, payerAcct, vaultAcct)
+	assert.NoError(t, err)
+
+	postFromBalance, err = util.GetBalance(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, "5.00000000", (initFromBalance - postFromBalance).String())
+
+	// Removing the delay is timelocked by the delay itself
+	_, err = MultiSig_SetMethodDelay(g, "transfer", 0, txIndex+2, acct1000, vaultAcct, true)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex+2, payerAcct, vaultAcct)
+	assert.NoError(t, err)
+
+	d, err = util.GetMethodDelay(g, vaultAcct, "transfer")
+	assert.NoError(t, err)
+	assert.Equal(t, delay, d)
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex+2, payerAcct, vaultAcct)
+	assert.Error(t, err)
+
+	err = util.AdvanceBlocks(g, delay, payerAcct)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex+2, payerAcct, vaultAcct)
+	assert.NoError(t, err)
+
+	d, err = util.GetMethodDelay(g, vaultAcct, "transfer")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), d)
+}
+
+func TestTransfersOutsideRecipientAllowlist(t *testing.T) {
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := NewTestFixture(t, g)
+	payerAcct := f.Payer
+	vaultAcct := f.Vault
+	listedAcct := f.Payer
+	unlistedAcct := f.Vault
+	acct1000, acct500_1 := f.Signers[0], f.Signers[1]
+
+	txIndex, err := util.GetTxIndex(g, vaultAcct)
+	assert.NoError(t, err)
+	txIndex = txIndex + 1
+
+	_, err = MultiSig_AddRecipient(g, listedAcct, txIndex, acct1000, vaultAcct, true)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex, payerAcct, vaultAcct)
+	assert.NoError(t, err)
+
+	recipients, err := GetAllowedRecipients(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{util.GetAccountAddr(g, listedAcct)}, recipients)
+
+	// Transfers outside of the allowlist are rejected by default
+	_, err = MultiSig_Transfer(g, "1.0", unlistedAcct, txIndex+1, acct1000, vaultAcct, true)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex+1, payerAcct, vaultAcct)
+	assert.Error(t, err)
+
+	// and require the unlisted recipient weight once it is set
+	_, err = MultiSig_SetUnlistedRecipientWeight(g, "1500.0", txIndex+2, acct1000, vaultAcct, true)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex+2, payerAcct, vaultAcct)
+	assert.NoError(t, err)
+
+	weight, err := GetUnlistedRecipientWeight(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, "1500.00000000", weight.String())
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex+1, payerAcct, vaultAcct)
+	assert.Error(t, err)
+
+	_, err = MultiSig_Transfer(g, "1.0", unlistedAcct, txIndex+1, acct500_1, vaultAcct, false)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex+1, payerAcct, vaultAcct)
+	assert.NoError(t, err)
+
+	// Transfers within the allowlist only need the full approval weight
+	initToBalance, err := util.GetBalance(g, listedAcct)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_Transfer(g, "1.0", listedAcct, txIndex+3, acct1000, vaultAcct, true)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex+3, payerAcct, vaultAcct)
+	assert.NoError(t, err)
+
+	postToBalance, err := util.GetBalance(g, listedAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.00000000", (postToBalance - initToBalance).String())
+
+	// Withdrawals require the unlisted recipient weight
+	_, err = MultiSig_Withdraw(g, "1.0", txIndex+4, acct1000, vaultAcct, true)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex+4, payerAcct, vaultAcct)
+	assert.Error(t, err)
+
+	// Removing the last address keeps the allowlist enabled
+	_, err = MultiSig_RemoveRecipient(g, listedAcct, txIndex+5, acct1000, vaultAcct, true)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex+5, payerAcct, vaultAcct)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_SetUnlistedRecipientWeight(g, "0.0", txIndex+6, acct1000, vaultAcct, true)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex+6, payerAcct, vaultAcct)
+	assert.NoError(t, err)
+
+	recipients, err = GetAllowedRecipients(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Empty(t, recipients)
+
+	enabled, err := IsAllowlistEnabled(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.True(t, enabled)
+
+	_, err = MultiSig_Transfer(g, "1.0", listedAcct, txIndex+7, acct1000, vaultAcct, true)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex+7, payerAcct, vaultAcct)
+	assert.Error(t, err)
+
+	// until it is disabled
+	_, err = MultiSig_DisableAllowlist(g, txIndex+8, acct1000, vaultAcct, true)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex+8, payerAcct, vaultAcct)
+	assert.NoError(t, err)
+
+	enabled, err = IsAllowlistEnabled(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.False(t, enabled)
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex+7, payerAcct, vaultAcct)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex+4, payerAcct, vaultAcct)
+	assert.NoError(t, err)
+}
+
+func TestSignatureForAnotherVaultCannotBeReplayed(t *testing.T) {
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := NewTestFixture(t, g)
+	vaultAcct := f.Vault
+	acct1000 := f.Signers[0]
+
+	// Another vault with the same signer keys
+	otherVaultAcct := f.Vault + "-other"
+	err := util.NewAccount(g, otherVaultAcct)
+	assert.NoError(t, err)
+	_, err = AddVaultWithKeys(g, otherVaultAcct, f.Signers, DefaultWeights)
+	assert.NoError(t, err)
+
+	txIndex, err := util.GetTxIndex(g, vaultAcct)
+	assert.NoError(t, err)
+	txIndex = txIndex + 1
+
+	// A signature for the same payload on the other vault
+	method := "transfer"
+	amount, err := cadence.NewUFix64("1.0")
+	assert.NoError(t, err)
+	toAddr := cadence.BytesToAddress(g.Accounts[f.Payer].Address.Bytes())
+	signable, err := util.GetSignableDataFromScript(g, otherVaultAcct, txIndex, method, amount, toAddr)
+	assert.NoError(t, err)
+
+	sig, err := util.SignPayloadOffline(g, signable, acct1000)
+	assert.NoError(t, err)
+
+	args := []cadence.Value{amount, toAddr}
+	_, err = util.MultiSig_VaultNewPayload(g, sig, txIndex, method, args, acct1000, vaultAcct, "0.0")
+	assert.Error(t, err)
+
+	postTxIndex, err := util.GetTxIndex(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, txIndex-1, postTxIndex)
+}
+
+func TestEncoderMatchesContractSignableData(t *testing.T) {
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := NewTestFixture(t, g)
+	vaultAcct := f.Vault
+
+	domain, err := util.GetSignableDomain(g, vaultAcct)
+	assert.NoError(t, err)
+
+	amount, err := cadence.NewUFix64("12.5")
+	assert.NoError(t, err)
+	fix64, err := cadence.NewFix64("-1.5")
+	assert.NoError(t, err)
+	ownerAddr := cadence.BytesToAddress(g.Accounts["owner"].Address.Bytes())
+	args := []cadence.Value{
+		amount,
+		ownerAddr,
+		cadence.NewString("removeKey"),
+		cadence.UInt64(3),
+		cadence.UInt8(1),
+		cadence.NewBool(true),
+		cadence.NewInt(-129),
+		cadence.NewInt(128),
+		cadence.NewInt64(-2),
+		cadence.NewUInt32(7),
+		fix64,
+		cadence.NewArray([]cadence.Value{cadence.UInt8(1), cadence.UInt8(255)}),
+		cadence.NewArray([]cadence.Value{cadence.NewString("a"), cadence.NewString("bc")}),
+		cadence.NewArray([]cadence.Value{ownerAddr}),
+		cadence.NewArray([]cadence.Value{}),
+		cadence.NewOptional(cadence.NewString("a")),
+		cadence.NewOptional(nil),
+		cadence.NewOptional(cadence.NewArray([]cadence.Value{cadence.UInt8(1)})),
+	}
+
+	fromScript, err := util.GetSignableDataFromScript(g, vaultAcct, 7, "transfer", args...)
+	assert.NoError(t, err)
+
+	fromEncoder, err := encoder.Encode(domain, 7, "transfer", args...)
+	assert.NoError(t, err)
+	assert.Equal(t, fromScript, fromEncoder)
+
+	// Unsupported types are rejected by both
+	path := cadence.Path{Domain: "storage", Identifier: "vault"}
+	_, err = util.GetSignableDataFromScript(g, vaultAcct, 7, "transfer", path)
+	assert.Error(t, err)
+
+	_, err = encoder.Encode(domain, 7, "transfer", path)
+	assert.ErrorIs(t, err, encoder.ErrPathNotSupported)
+
+	// and payloads with Path args are rejected before their signature is verified
+	txIndex, err := util.GetTxIndex(g, vaultAcct)
+	assert.NoError(t, err)
+	_, err = util.MultiSig_VaultNewPayload(g, "00", txIndex+1, "transfer", []cadence.Value{cadence.NewArray([]cadence.Value{path})}, f.Signers[0], vaultAcct, "0.0")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Path arguments are not supported")
+}
+
+func TestExecuteTransferSignedWithKeystoreAndRemoteSigners(t *testing.T) {
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := NewTestFixture(t, g)
+	transferTo := f.Payer
+	payerAcct := f.Payer
+	vaultAcct := f.Vault
+	acct500_1, acct500_2 := f.Signers[1], f.Signers[2]
+
+	// The keys of the signers are kept out of flow.json in practice
+	keystorePath := filepath.Join(t.TempDir(), "w-500-1.json")
+	err := signer.WriteKeystore(keystorePath, g.Accounts[acct500_1].PrivateKey, g.Accounts[acct500_1].HashAlgo, "passphrase", signer.LightScryptN)
+	assert.NoError(t, err)
+	keystoreSigner, err := signer.NewKeystoreSigner(keystorePath, "passphrase")
+	assert.NoError(t, err)
+
+	server := httptest.NewServer(signer.NewRemoteSignerHandler(util.GetSigner(g, acct500_2)))
+	defer server.Close()
+	remoteSigner, err := signer.NewRemoteSigner(server.URL, nil)
+	assert.NoError(t, err)
+
+	util.SetSigner(acct500_1, keystoreSigner)
+	util.SetSigner(acct500_2, remoteSigner)
+	defer util.SetSigner(acct500_1, nil)
+	defer util.SetSigner(acct500_2, nil)
+
+	txIndex, err := util.GetTxIndex(g, vaultAcct)
+	assert.NoError(t, err)
+	txIndex = txIndex + 1
+
+	initFromBalance, err := util.GetBalance(g, vaultAcct)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_Transfer(g, "1.0", transferTo, txIndex, acct500_1, vaultAcct, true)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_Transfer(g, "1.0", transferTo, txIndex, acct500_2, vaultAcct, false)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex, payerAcct, vaultAcct)
+	assert.NoError(t, err)
+
+	postFromBalance, err := util.GetBalance(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.00000000", (initFromBalance - postFromBalance).String())
+}
+
+func TestDepositAuthorizedBySignerIsPaidForByAnotherAccount(t *testing.T) {
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := NewTestFixture(t, g)
+	depositAmount := "2.00000000"
+	vaultAcct := f.Vault
+	acct1000 := f.Signers[0]
+
+	_, err := AddVaultWithKeys(g, acct1000, nil, nil)
+	assert.NoError(t, err)
+	err = f.Fund(acct1000, "100.0")
+	assert.NoError(t, err)
+
+	txIndex, err := util.GetTxIndex(g, vaultAcct)
+	assert.NoError(t, err)
+	txIndex = txIndex + 1
+
+	initSignerBalance, err := util.GetBalance(g, acct1000)
+	assert.NoError(t, err)
+	initVaultBalance, err := util.GetBalance(g, vaultAcct)
+	assert.NoError(t, err)
+
+	ufix64, err := cadence.NewUFix64(depositAmount)
+	assert.NoError(t, err)
+	signerAddr := cadence.BytesToAddress(g.Accounts[acct1000].Address.Bytes())
+	signable, err := util.GetSignableDataFromScript(g, vaultAcct, txIndex, "deposit", ufix64, signerAddr)
+	assert.NoError(t, err)
+	sig, err := util.SignPayloadOffline(g, signable, acct1000)
+	assert.NoError(t, err)
+
+	// The deposit is withdrawn from the authorizer, the payer only proposes and pays for the transaction
+	roles := util.TxRoles{Proposer: f.Payer, Payer: f.Payer, Authorizers: []string{acct1000}}
+	signerPubKey := signer.PublicKeyHex(util.GetSigner(g, acct1000))
+	_, err = util.SubmitNewPayload(g, roles, sig, txIndex, "deposit", []cadence.Value{ufix64, signerAddr}, signerPubKey, g.FindAddress(vaultAcct), util.DefaultVaultPaths.Signer, depositAmount)
+	assert.NoError(t, err)
+
+	_, err = MultiSig_VaultExecuteTx(g, txIndex, f.Payer, vaultAcct)
+	assert.NoError(t, err)
+
+	postSignerBalance, err := util.GetBalance(g, acct1000)
+	assert.NoError(t, err)
+	postVaultBalance, err := util.GetBalance(g, vaultAcct)
+	assert.NoError(t, err)
+	assert.Equal(t, depositAmount, (initSignerBalance - postSignerBalance).String())
+	assert.Equal(t, depositAmount, (postVaultBalance - initVaultBalance).String())
+}
+
+func TestNamedVaultsHaveTheirOwnSigners(t *testing.T) {
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := NewTestFixture(t, g)
+	c := client(g)
+	addr := g.Accounts[f.Vault].Address
+
+	// A cold vault next to the default one, signed by the 500 weight keys only
+	cold, err := util.NamedVaultPaths("cold")
+	assert.NoError(t, err)
+	_, err = AddNamedVault(g, f.Vault, cold, f.Signers[1:3], []string{"500.0", "500.0"})
+	assert.NoError(t, err)
+	_, err = c.AccountSignerTokenTransfer(util.AccountRoles("owner"), 50_0000_0000, addr, cold.Receiver)
+	assert.NoError(t, err)
+
+	vaults, err := GetVaults(g, f.Vault)
+	assert.NoError(t, err)
+	assert.Equal(t, []util.VaultPaths{util.DefaultVaultPaths, cold}, vaults)
+	coldKeys, err := c.GetStoreKeys(addr, cold.Signer)
+	assert.NoError(t, err)
+	assert.Len(t, coldKeys, 2)
+
+	amount, err := cadence.NewUFix64("10.0")
+	assert.NoError(t, err)
+	toAddr := cadence.BytesToAddress(g.Accounts[f.Payer].Address.Bytes())
+	args := []cadence.Value{amount, toAddr}
+	submit := func(signerAcct string, newPayload bool) error {
+		signable, err := util.GetSignableDataAt(g, f.Vault, cold.Signer, 1, "transfer", args...)
+		if err != nil {
+			return err
+		}
+		sig, err := util.SignPayloadOffline(g, signable, signerAcct)
+		if err != nil {
+			return err
+		}
+		pk := signer.PublicKeyHex(util.GetSigner(g, signerAcct))
+		if newPayload {
+			_, err = util.SubmitNewPayload(g, util.AccountRoles(signerAcct), sig, 1, "transfer", args, pk, g.FindAddress(f.Vault), cold.Signer, "0.0")
+		} else {
+			_, err = util.SubmitPayloadSignature(g, util.AccountRoles(signerAcct), sig, 1, pk, g.FindAddress(f.Vault), cold.Signer)
+		}
+		return err
+	}
+
+	// The key of the default vault with the full weight is not a key of the cold vault
+	assert.Error(t, submit(f.Signers[0], true))
+	assert.NoError(t, submit(f.Signers[1], true))
+	assert.NoError(t, submit(f.Signers[2], false))
+	_, err = c.ExecuteTx(util.AccountRoles(f.Payer), addr, cold.Signer, 1)
+	assert.NoError(t, err)
+
+	coldBalance, err := c.GetBalance(addr, cold.Balance)
+	assert.NoError(t, err)
+	assert.Equal(t, "40.00000000", coldBalance.String())
+	balance, err := util.GetBalance(g, f.Vault)
+	assert.NoError(t, err)
+	assert.Equal(t, "100.00000000", balance.String())
+	payerBalance, err := util.GetBalance(g, f.Payer)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.00000000", payerBalance.String())
+	txIndex, err := util.GetTxIndex(g, f.Vault)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), txIndex)
+
+	// Nothing stored or linked at the paths of a vault is replaced, and the names are unique
+	_, err = AddNamedVault(g, f.Vault, cold, f.Signers[:1], []string{"1000.0"})
+	assert.Error(t, err)
+	other, err := util.NamedVaultPaths("other")
+	assert.NoError(t, err)
+	other.Name = cold.Name
+	_, err = AddNamedVault(g, f.Vault, other, f.Signers[:1], []string{"1000.0"})
+	assert.Error(t, err)
+	coldKeys, err = c.GetStoreKeys(addr, cold.Signer)
+	assert.NoError(t, err)
+	assert.Len(t, coldKeys, 2)
+
+	_, err = util.NamedVaultPaths("with/slash")
+	assert.Error(t, err)
+}
+
+func TestMigrateVault(t *testing.T) {
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := NewTestFixture(t, g)
+	c := client(g)
+	addr := g.Accounts[f.Vault].Address
+
+	// A key with a policy, which is moved with the key
+	_, err := c.SetKeyPolicy(util.AccountRoles(f.Vault), util.DefaultVaultPaths.Storage,
+		signer.PublicKeyHex(util.GetSigner(g, f.Signers[3])), &[]string{"transfer"}, map[string]cadence.UFix64{})
+	assert.NoError(t, err)
+	keys, err := util.GetStoreKeys(g, f.Vault)
+	assert.NoError(t, err)
+
+	// The vault to migrate to has no keys
+	migrated, err := util.NamedVaultPaths("migrated")
+	assert.NoError(t, err)
+	_, err = AddNamedVault(g, f.Vault, migrated, nil, nil)
+	assert.NoError(t, err)
+	vaultId, err := c.GetVaultUUID(addr, migrated.Signer)
+	assert.NoError(t, err)
+
+	// A transfer is pending when the migration is approved
+	_, err = MultiSig_Transfer(g, "10.0", f.Payer, 1, f.Signers[1], f.Vault, true)
+	assert.NoError(t, err)
+	_, err = MultiSig_Migrate(g, vaultId, 2, f.Signers[0], f.Vault, true)
+	assert.NoError(t, err)
+
+	// The migration is not executed by executeTx, which cannot borrow the vault to migrate to
+	_, err = MultiSig_VaultExecuteTx(g, 2, f.Payer, f.Vault)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "executed by the owner of the vault with migrate")
+	}
+	// nor while a payload, which may hold tokens, is pending
+	_, err = MigrateVault(g, f.Vault, 2, util.DefaultVaultPaths, migrated)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "pending payloads must be executed or removed")
+	}
+	_, err = MultiSig_Transfer(g, "10.0", f.Payer, 1, f.Signers[2], f.Vault, false)
+	assert.NoError(t, err)
+	_, err = MultiSig_VaultExecuteTx(g, 1, f.Payer, f.Vault)
+	assert.NoError(t, err)
+
+	// nor to another vault than the one it was approved for
+	other, err := util.NamedVaultPaths("other")
+	assert.NoError(t, err)
+	_, err = AddNamedVault(g, f.Vault, other, nil, nil)
+	assert.NoError(t, err)
+	_, err = MigrateVault(g, f.Vault, 2, util.DefaultVaultPaths, other)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "migration is to another vault")
+	}
+
+	_, err = MigrateVault(g, f.Vault, 2, util.DefaultVaultPaths, migrated)
+	assert.NoError(t, err)
+
+	// No tokens are lost: the balance left after the transfer is in the new vault
+	balance, err := util.GetBalance(g, f.Vault)
+	assert.NoError(t, err)
+	assert.Equal(t, "0.00000000", balance.String())
+	migratedBalance, err := c.GetBalance(addr, migrated.Balance)
+	assert.NoError(t, err)
+	assert.Equal(t, "90.00000000", migratedBalance.String())
+	payerBalance, err := util.GetBalance(g, f.Payer)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.00000000", payerBalance.String())
+
+	// The keys and their policies are moved
+	oldKeys, err := util.GetStoreKeys(g, f.Vault)
+	assert.NoError(t, err)
+	assert.Empty(t, oldKeys)
+	migratedKeys, err := c.GetStoreKeys(addr, migrated.Signer)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, keys, migratedKeys)
+	pk := signer.PublicKeyHex(util.GetSigner(g, f.Signers[0]))
+	weight, err := c.GetKeyWeight(addr, migrated.Signer, pk)
+	assert.NoError(t, err)
+	assert.Equal(t, "1000.00000000", weight.String())
+	policy, err := c.GetKeyPolicy(addr, migrated.Signer, signer.PublicKeyHex(util.GetSigner(g, f.Signers[3])))
+	assert.NoError(t, err)
+	assert.Contains(t, fmt.Sprint(policy), "transfer")
+
+	// The default vault is kept, empty and without keys, so a vault cannot be created at its paths
+	_, err = AddVaultToAccount(g, f.Vault)
+	assert.Error(t, err)
+	balance, err = util.GetBalance(g, f.Vault)
+	assert.NoError(t, err)
+	assert.Equal(t, "0.00000000", balance.String())
+}
+
+func TestMigrateVaultKeepsRestrictions(t *testing.T) {
+	t.Parallel()
+	g := emu.GoWithTheFlow()
+	f := NewTestFixture(t, g)
+	c := client(g)
+	addr := g.Accounts[f.Vault].Address
+	acct1000, acct500_1 := f.Signers[0], f.Signers[1]
+	period := uint64(1000000)
+
+	txIndex, err := util.GetTxIndex(g, f.Vault)
+	assert.NoError(t, err)
+	txIndex = txIndex + 1
+
+	// A spending limit, partly spent, an allowlist, an unlisted recipient weight and a method delay
+	_, err = MultiSig_SetSpendingLimit(g, "10.0", period, "500.0", txIndex, acct1000, f.Vault, true)
+	assert.NoError(t, err)
+	_, err = MultiSig_VaultExecuteTx(g, txIndex, f.Payer, f.Vault)
+	assert.NoError(t, err)
+	_, err = MultiSig_Transfer(g, "6.0", f.Payer, txIndex+1, acct500_1, f.Vault, true)
+	assert.NoError(t, err)
+	_, err = MultiSig_VaultExecuteTx(g, txIndex+1, f.Payer, f.Vault)
+	assert.NoError(t, err)
+	_, err = MultiSig_AddRecipient(g, f.Payer, txIndex+2, acct1000, f.Vault, true)
+	assert.NoError(t, err)
+	_, err = MultiSig_VaultExecuteTx(g, txIndex+2, f.Payer, f.Vault)
+	assert.NoError(t, err)
+	_, err = MultiSig_SetUnlistedRecipientWeight(g, "1500.0", txIndex+3, acct1000, f.Vault, true)
+	assert.NoError(t, err)
+	_, err = MultiSig_VaultExecuteTx(g, txIndex+3, f.Payer, f.Vault)
+	assert.NoError(t, err)
+	_, err = MultiSig_SetMethodDelay(g, "transfer", 10, txIndex+4, acct1000, f.Vault, true)
+	assert.NoError(t, err)
+	_, err = MultiSig_VaultExecuteTx(g, txIndex+4, f.Payer, f.Vault)
+	assert.NoError(t, err)
+
+	migrated, err := util.NamedVaultPaths("migrated")
+	assert.NoError(t, err)
+	_, err = AddNamedVault(g, f.Vault, migrated, nil, nil)
+	assert.NoError(t, err)
+	vaultId, err := c.GetVaultUUID(addr, migrated.Signer)
+	assert.NoError(t, err)
+	_, err = MultiSig_Migrate(g, vaultId, txIndex+5, acct1000, f.Vault, true)
+	assert.NoError(t, err)
+	_, err = MigrateVault(g, f.Vault, txIndex+5, util.DefaultVaultPaths, migrated)
+	assert.NoError(t, err)
+
+	// The vault migrated to is restricted as the vault migrated from was
+	limit, err := c.GetSpendingLimit(addr, migrated.Signer)
+	assert.NoError(t, err)
+	assert.NotNil(t, limit.(cadence.Optional).Value)
+	remaining, err := c.GetRemainingSpendingLimit(addr, migrated.Signer)
+	assert.NoError(t, err)
+	assert.Equal(t, "4.00000000", remaining.String())
+	enabled, err := c.IsAllowlistEnabled(addr, migrated.Signer)
+	assert.NoError(t, err)
+	assert.True(t, enabled)
+	recipients, err := c.GetAllowedRecipients(addr, migrated.Signer)
+	assert.NoError(t, err)
+	assert.Equal(t, []flow.Address{g.Accounts[f.Payer].Address}, recipients)
+	weight, err := c.GetUnlistedRecipientWeight(addr, migrated.Signer)
+	assert.NoError(t, err)
+	assert.Equal(t, "1500.00000000", weight.String())
+	delay, err := c.GetMethodDelay(addr, migrated.Signer, "transfer")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), delay)
 }